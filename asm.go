@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cterence/chip8-go/internal/asm"
+	"github.com/urfave/cli/v3"
+)
+
+func asmCommand() *cli.Command {
+	var (
+		source string
+		output string
+	)
+
+	return &cli.Command{
+		Name:  "asm",
+		Usage: "assemble an octo source file into a rom",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "rom path (default: source path with a .ch8 extension)",
+				Destination: &output,
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:        "source",
+				UsageText:   "octo source path",
+				Destination: &source,
+			},
+		},
+		Action: func(_ context.Context, c *cli.Command) error {
+			if source == "" {
+				return cli.ShowSubcommandHelp(c)
+			}
+
+			src, err := os.ReadFile(source)
+			if err != nil {
+				return fmt.Errorf("failed to read source file: %w", err)
+			}
+
+			program, err := asm.Assemble(string(src), source)
+			if err != nil {
+				return err
+			}
+
+			if output == "" {
+				output = strings.TrimSuffix(source, ".8o") + ".ch8"
+			}
+
+			if err := os.WriteFile(output, program.ROM, 0644); err != nil {
+				return fmt.Errorf("failed to write rom file: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,349 @@
+//go:build js && wasm
+
+// Command chip8-wasm runs the interpreter in a browser page: the display is
+// drawn to a canvas, the sound is played with WebAudio and ROMs are loaded
+// with a file picker or the rom query parameter.
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"syscall/js"
+
+	"github.com/cterence/chip8-go/machine"
+)
+
+const (
+	// Frames run at once when the page was throttled, later ones are dropped
+	MAX_CATCH_UP_FRAMES = 4
+
+	VOLUME = 0.25
+	// Seconds of audio scheduled ahead of the audio clock
+	AUDIO_LATENCY = 0.05
+)
+
+// Same layout as the desktop frontends, by KeyboardEvent.code so that it does
+// not depend on the keyboard language
+var keyIDs = map[string]byte{
+	"Digit1": 0x1, "Digit2": 0x2, "Digit3": 0x3, "Digit4": 0xC,
+	"KeyQ": 0x4, "KeyW": 0x5, "KeyE": 0x6, "KeyR": 0xD,
+	"KeyA": 0x7, "KeyS": 0x8, "KeyD": 0x9, "KeyF": 0xE,
+	"KeyZ": 0xA, "KeyX": 0x0, "KeyC": 0xB, "KeyV": 0xF,
+}
+
+type page struct {
+	document js.Value
+	canvas   js.Value
+	context  js.Value
+	image    js.Value
+	pixels   js.Value
+	status   js.Value
+
+	mode    machine.CompatibilityMode
+	machine *machine.Machine
+	audio   *webAudio
+	rgba    []byte
+
+	onFrame  js.Func
+	lastTime float64
+	elapsed  float64
+}
+
+func main() {
+	document := js.Global().Get("document")
+	canvas := document.Call("getElementById", "screen")
+
+	p := &page{
+		document: document,
+		canvas:   canvas,
+		context:  canvas.Call("getContext", "2d"),
+		status:   document.Call("getElementById", "status"),
+		audio:    &webAudio{},
+		rgba:     make([]byte, machine.WIDTH*machine.HEIGHT*4),
+	}
+
+	canvas.Set("width", machine.WIDTH)
+	canvas.Set("height", machine.HEIGHT)
+
+	p.image = p.context.Call("createImageData", machine.WIDTH, machine.HEIGHT)
+	p.pixels = js.Global().Get("Uint8Array").New(len(p.rgba))
+
+	params := js.Global().Get("URLSearchParams").New(js.Global().Get("location").Get("search"))
+
+	if mode := params.Call("get", "mode"); !mode.IsNull() {
+		var err error
+
+		p.mode, err = machine.ParseCompatibilityMode(mode.String())
+		if err != nil {
+			p.setStatus(err.Error())
+		}
+	}
+
+	document.Call("addEventListener", "keydown", js.FuncOf(p.keyDown))
+	document.Call("addEventListener", "keyup", js.FuncOf(p.keyUp))
+
+	picker := document.Call("getElementById", "rom")
+	picker.Call("addEventListener", "change", js.FuncOf(func(js.Value, []js.Value) any {
+		if files := picker.Get("files"); files.Length() > 0 {
+			p.setStatus("loading " + files.Index(0).Get("name").String())
+			readBytes(files.Index(0).Call("arrayBuffer"), p.load)
+		}
+
+		return nil
+	}))
+
+	if rom := params.Call("get", "rom"); !rom.IsNull() {
+		p.setStatus("loading " + rom.String())
+
+		var fetched js.Func
+
+		fetched = js.FuncOf(func(_ js.Value, args []js.Value) any {
+			defer fetched.Release()
+
+			if !args[0].Get("ok").Bool() {
+				p.setStatus(fmt.Sprintf("failed to fetch rom: %s", args[0].Get("statusText").String()))
+
+				return nil
+			}
+
+			readBytes(args[0].Call("arrayBuffer"), p.load)
+
+			return nil
+		})
+
+		js.Global().Call("fetch", rom).Call("then", fetched)
+	}
+
+	p.onFrame = js.FuncOf(p.animationFrame)
+	js.Global().Call("requestAnimationFrame", p.onFrame)
+
+	select {}
+}
+
+func (p *page) load(rom []byte) {
+	m := machine.New(machine.WithCompatibilityMode(p.mode))
+
+	if err := m.LoadROM(rom); err != nil {
+		p.setStatus(err.Error())
+
+		return
+	}
+
+	p.machine = m
+	p.elapsed = 0
+
+	p.setStatus(fmt.Sprintf("%d bytes loaded, P to pause, Space to reset", len(rom)))
+}
+
+// animationFrame runs the 60 Hz frames due since the previous call, whatever
+// the refresh rate of the screen.
+func (p *page) animationFrame(_ js.Value, args []js.Value) any {
+	js.Global().Call("requestAnimationFrame", p.onFrame)
+
+	now := args[0].Float() / 1000
+
+	if p.lastTime > 0 {
+		p.elapsed += now - p.lastTime
+	}
+
+	p.lastTime = now
+
+	if p.machine == nil {
+		return nil
+	}
+
+	frames := int(p.elapsed * machine.FPS)
+	p.elapsed -= float64(frames) / machine.FPS
+
+	defer func() {
+		// Out of bounds memory accesses panic, stop the ROM instead of the page
+		if r := recover(); r != nil {
+			p.machine = nil
+			p.setStatus(fmt.Sprintf("runtime error: %v", r))
+		}
+	}()
+
+	for range min(frames, MAX_CATCH_UP_FRAMES) {
+		err := p.machine.RunFrame()
+		p.audio.Write(p.machine.AudioSamples())
+
+		// Freeze on the faulting instruction
+		if err != nil {
+			p.machine.SetPaused(true)
+			p.setStatus(fmt.Sprintf("%v, Space to reset", err))
+
+			break
+		}
+	}
+
+	if frames > 0 {
+		js.CopyBytesToJS(p.pixels, p.RGBA())
+		p.image.Get("data").Call("set", p.pixels)
+		p.context.Call("putImageData", p.image, 0, 0)
+	}
+
+	return nil
+}
+
+func (p *page) keyDown(_ js.Value, args []js.Value) any {
+	event := args[0]
+
+	// Browsers only play sound after a user gesture
+	p.audio.start()
+
+	if p.machine == nil || event.Get("target").Get("tagName").String() == "INPUT" {
+		return nil
+	}
+
+	code := event.Get("code").String()
+
+	if key, ok := keyIDs[code]; ok {
+		p.machine.SetKey(key, true)
+		event.Call("preventDefault")
+
+		return nil
+	}
+
+	if event.Get("repeat").Bool() {
+		return nil
+	}
+
+	switch code {
+	case "Space":
+		p.machine.Reset()
+		event.Call("preventDefault")
+	case "KeyP":
+		p.machine.SetPaused(!p.machine.Paused())
+
+		if p.machine.Paused() {
+			p.setStatus("paused")
+		} else {
+			p.setStatus("")
+		}
+	}
+
+	return nil
+}
+
+func (p *page) keyUp(_ js.Value, args []js.Value) any {
+	if p.machine == nil {
+		return nil
+	}
+
+	if key, ok := keyIDs[args[0].Get("code").String()]; ok {
+		p.machine.SetKey(key, false)
+	}
+
+	return nil
+}
+
+// RGBA returns the framebuffer as RGBA pixels.
+func (p *page) RGBA() []byte {
+	img := p.machine.Framebuffer()
+
+	for i, c := range img.Pix {
+		r, g, b, a := img.Palette[c].RGBA()
+		p.rgba[i*4] = byte(r >> 8)
+		p.rgba[i*4+1] = byte(g >> 8)
+		p.rgba[i*4+2] = byte(b >> 8)
+		p.rgba[i*4+3] = byte(a >> 8)
+	}
+
+	return p.rgba
+}
+
+func (p *page) setStatus(status string) {
+	if status != "" {
+		log.Println(status)
+	}
+
+	p.status.Set("textContent", status)
+}
+
+// readBytes calls then with the content of the ArrayBuffer promise.
+func readBytes(promise js.Value, then func([]byte)) {
+	var read js.Func
+
+	read = js.FuncOf(func(_ js.Value, args []js.Value) any {
+		defer read.Release()
+
+		data := js.Global().Get("Uint8Array").New(args[0])
+		b := make([]byte, data.Length())
+		js.CopyBytesToGo(b, data)
+
+		then(b)
+
+		return nil
+	})
+
+	promise.Call("then", read)
+}
+
+// webAudio schedules the samples of each timer tick one after the other on
+// the audio clock. Silent ticks are skipped.
+type webAudio struct {
+	context js.Value
+	next    float64
+	buf     []byte
+}
+
+func (w *webAudio) start() {
+	if !w.context.IsUndefined() {
+		return
+	}
+
+	// Not available outside browsers
+	if ctor := js.Global().Get("AudioContext"); !ctor.IsUndefined() {
+		w.context = ctor.New()
+	}
+}
+
+func (w *webAudio) Write(samples []byte) (int, error) {
+	if w.context.IsUndefined() || silent(samples) {
+		return len(samples), nil
+	}
+
+	w.buf = float32Samples(w.buf[:0], samples)
+
+	data := js.Global().Get("Uint8Array").New(len(w.buf))
+	js.CopyBytesToJS(data, w.buf)
+
+	buffer := w.context.Call("createBuffer", 1, len(samples), machine.SAMPLE_RATE)
+	buffer.Call("getChannelData", 0).Call("set", js.Global().Get("Float32Array").New(data.Get("buffer")))
+
+	source := w.context.Call("createBufferSource")
+	source.Set("buffer", buffer)
+	source.Call("connect", w.context.Get("destination"))
+
+	now := w.context.Get("currentTime").Float()
+	if w.next < now {
+		w.next = now + AUDIO_LATENCY
+	}
+
+	source.Call("start", w.next)
+	w.next += float64(len(samples)) / machine.SAMPLE_RATE
+
+	return len(samples), nil
+}
+
+// float32Samples appends the unsigned 8-bit samples to dst as little-endian
+// float32, the format of WebAudio buffers.
+func float32Samples(dst, samples []byte) []byte {
+	for _, s := range samples {
+		f := math.Float32bits(VOLUME * (float32(s) - machine.SAMPLE_SILENCE) / machine.SAMPLE_SILENCE)
+		dst = append(dst, byte(f), byte(f>>8), byte(f>>16), byte(f>>24))
+	}
+
+	return dst
+}
+
+func silent(samples []byte) bool {
+	for _, s := range samples {
+		if s != machine.SAMPLE_SILENCE {
+			return false
+		}
+	}
+
+	return true
+}
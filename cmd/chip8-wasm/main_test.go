@@ -0,0 +1,128 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"syscall/js"
+	"testing"
+
+	"github.com/cterence/chip8-go/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+var beepROM = []byte{
+	0x60, 0x00, // V0 = 0
+	0xF0, 0x29, // I = font sprite of V0
+	0xD0, 0x05, // draw at (V0, V0)
+	0x61, 0x05, // V1 = 5
+	0xF1, 0x18, // sound timer = V1
+	0x12, 0x0A, // loop
+}
+
+func newPage(t *testing.T, rom []byte) *page {
+	t.Helper()
+
+	p := &page{
+		// With the display wait quirk
+		mode:   machine.CM_CHIP8,
+		status: js.Global().Get("Object").New(),
+		audio:  &webAudio{},
+		rgba:   make([]byte, machine.WIDTH*machine.HEIGHT*4),
+	}
+
+	p.load(rom)
+	assert.NotNil(t, p.machine)
+
+	return p
+}
+
+// keyEvent returns the fields of a KeyboardEvent read by the page.
+func keyEvent(code string) js.Value {
+	target := js.Global().Get("Object").New()
+	target.Set("tagName", "BODY")
+
+	event := js.Global().Get("Object").New()
+	event.Set("code", code)
+	event.Set("repeat", false)
+	event.Set("target", target)
+	event.Set("preventDefault", js.FuncOf(func(js.Value, []js.Value) any { return nil }))
+
+	return event
+}
+
+func TestRGBA(t *testing.T) {
+	p := newPage(t, beepROM)
+
+	// DXYN waits for the vertical blank
+	assert.NoError(t, p.machine.RunFrame())
+	assert.NoError(t, p.machine.RunFrame())
+
+	palette := p.machine.Framebuffer().Palette
+	pixel := func(x, y int) []byte {
+		i := (y*machine.WIDTH + x) * 4
+
+		return p.RGBA()[i : i+4]
+	}
+
+	rgba := func(i int) []byte {
+		r, g, b, a := palette[i].RGBA()
+
+		return []byte{byte(r >> 8), byte(g >> 8), byte(b >> 8), byte(a >> 8)}
+	}
+
+	// The top row of 0 is 4 low resolution pixels wide, doubled
+	assert.Equal(t, rgba(1), pixel(0, 0))
+	assert.Equal(t, rgba(1), pixel(7, 1))
+	assert.Equal(t, rgba(0), pixel(8, 0))
+}
+
+func TestKeys(t *testing.T) {
+	p := newPage(t, []byte{
+		0xF0, 0x0A, // V0 = next key
+		0x12, 0x02, // loop
+	})
+
+	assert.NoError(t, p.machine.RunFrame())
+	p.keyDown(js.Undefined(), []js.Value{keyEvent("KeyC")})
+	assert.NoError(t, p.machine.RunFrame())
+	p.keyUp(js.Undefined(), []js.Value{keyEvent("KeyC")})
+	assert.NoError(t, p.machine.RunFrame())
+
+	assert.Equal(t, byte(0xB), p.machine.Registers().V[0])
+
+	p.keyDown(js.Undefined(), []js.Value{keyEvent("KeyP")})
+	assert.True(t, p.machine.Paused())
+	assert.Equal(t, "paused", p.status.Get("textContent").String())
+}
+
+func TestAudio(t *testing.T) {
+	p := newPage(t, beepROM)
+
+	assert.NoError(t, p.machine.RunFrame())
+	assert.NoError(t, p.machine.RunFrame())
+
+	samples := p.machine.AudioSamples()
+	assert.Len(t, samples, 2*machine.SAMPLES_PER_FRAME)
+
+	// The sound timer is set during the second frame
+	assert.True(t, silent(samples[:machine.SAMPLES_PER_FRAME]))
+	assert.False(t, silent(samples[machine.SAMPLES_PER_FRAME:]))
+
+	floats := float32Samples(nil, []byte{machine.SAMPLE_SILENCE, 0xFF, 0x00})
+	assert.Len(t, floats, 3*4)
+
+	sample := func(i int) float32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(floats[i*4:]))
+	}
+
+	assert.Zero(t, sample(0))
+	assert.InDelta(t, VOLUME, sample(1), 0.01)
+	assert.InDelta(t, -VOLUME, sample(2), 0.01)
+
+	// Without an AudioContext, outside browsers, samples are dropped
+	n, err := p.audio.Write(samples)
+	assert.NoError(t, err)
+	assert.Equal(t, len(samples), n)
+}
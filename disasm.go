@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cterence/chip8-go/internal/disasm"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/urfave/cli/v3"
+)
+
+func disasmCommand() *cli.Command {
+	var (
+		rom               string
+		compatibilityMode = lib.CM_CHIP8
+	)
+
+	return &cli.Command{
+		Name:  "disasm",
+		Usage: "disassemble a rom, tracing its control flow from 0x200",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "compatibility-mode",
+				Aliases: []string{"m"},
+				Usage:   "platform whose quirks are used to decode instructions (chip8, super, xo)",
+				Value:   "chip8",
+				Action: func(_ context.Context, _ *cli.Command, mode string) error {
+					var err error
+
+					compatibilityMode, err = lib.ParseCompatibilityMode(mode)
+
+					return err
+				},
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:        "rom",
+				UsageText:   "rom path",
+				Destination: &rom,
+			},
+		},
+		Action: func(_ context.Context, c *cli.Command) error {
+			if rom == "" {
+				return cli.ShowSubcommandHelp(c)
+			}
+
+			romBytes, err := os.ReadFile(rom)
+			if err != nil {
+				return fmt.Errorf("failed to read rom file: %w", err)
+			}
+
+			_, err = disasm.Disassemble(romBytes, compatibilityMode).WriteTo(os.Stdout)
+
+			return err
+		},
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cterence/chip8-go/internal/disasm"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/cterence/chip8-go/internal/romdb"
+	"github.com/urfave/cli/v3"
+)
+
+func infoCommand() *cli.Command {
+	var rom, romDBFile string
+
+	return &cli.Command{
+		Name:  "info",
+		Usage: "identify a rom in the rom database and guess its platform from its instructions",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "rom-db",
+				Usage:       "look the rom up in this programs.json of the CHIP-8 database instead of the embedded one, none to disable",
+				Destination: &romDBFile,
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:        "rom",
+				UsageText:   "rom path",
+				Destination: &rom,
+			},
+		},
+		Action: func(_ context.Context, c *cli.Command) error {
+			if rom == "" {
+				return cli.ShowSubcommandHelp(c)
+			}
+
+			romBytes, err := os.ReadFile(rom)
+			if err != nil {
+				return fmt.Errorf("failed to read rom file: %w", err)
+			}
+
+			db := romdb.Embedded()
+
+			switch romDBFile {
+			case "":
+			case "none":
+				db = nil
+			default:
+				db, err = romdb.Load(romDBFile)
+				if err != nil {
+					return err
+				}
+			}
+
+			report := disasm.Analyze(romBytes)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+			fmt.Fprintf(w, "size:\t%d bytes\n", len(romBytes))
+			fmt.Fprintf(w, "sha1:\t%x\n", sha1.Sum(romBytes))
+
+			if db == nil {
+				fmt.Fprintf(w, "database:\tdisabled\n")
+			} else if entry, ok := db.Lookup(romBytes); ok {
+				fmt.Fprintf(w, "database:\t%s, %s\n", entry.Title, formatPlatform(entry.Platform, entry.Mode))
+			} else {
+				fmt.Fprintf(w, "database:\tnot found\n")
+			}
+
+			fmt.Fprintf(w, "analysis:\t%s, %.0f%% confidence\n", report.Mode, report.Confidence*100)
+
+			if !report.Conclusive() {
+				fmt.Fprintf(w, "\tno SUPER-CHIP or XO-CHIP instruction reached, the mode is detected at runtime\n")
+			}
+
+			fmt.Fprintf(w, "instructions:\t%d reached, %d unreached of a later platform\n", report.Instructions, report.Unreached)
+
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			for _, e := range report.Evidence {
+				fmt.Printf("    %s  %s  %s\n", lib.FormatHex(e.Addr, 3), lib.FormatHex(e.Instruction.Opcode, 4), e.Instruction)
+			}
+
+			return nil
+		},
+	}
+}
+
+func formatPlatform(platform string, mode lib.CompatibilityMode) string {
+	if platform == "" {
+		return "unsupported platform"
+	}
+
+	return fmt.Sprintf("%s (%s)", platform, mode)
+}
@@ -0,0 +1,1176 @@
+package asm
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+)
+
+// Program is an assembled ROM, loaded at 0x200, with the source map linking
+// its addresses to the source lines they were assembled from.
+type Program struct {
+	ROM      []byte
+	FileName string
+	Labels   map[string]uint16
+	Lines    map[uint16]int
+}
+
+type token struct {
+	text string
+	line int
+}
+
+type macro struct {
+	params []string
+	body   []token
+}
+
+// fixup is an address operand referencing a label that was not defined yet.
+type fixup struct {
+	addr  int
+	label string
+	long  bool
+	tok   token
+}
+
+type blockKind uint8
+
+const (
+	BK_LOOP blockKind = iota
+	BK_IF
+	BK_ELSE
+)
+
+// block is an open loop or if/else, with the jumps to patch to its end.
+type block struct {
+	kind  blockKind
+	start int
+	jumps []int
+	tok   token
+}
+
+type assembler struct {
+	fileName string
+	tokens   []token
+	pos      int
+
+	rom  [1 << 16]byte
+	here int
+	end  int
+
+	labels  map[string]uint16
+	lines   map[uint16]int
+	consts  map[string]float64
+	aliases map[string]byte
+	macros  map[string]*macro
+	fixups  []fixup
+	blocks  []block
+
+	expansions int
+}
+
+const (
+	MAX_MACRO_EXPANSIONS = 1 << 16
+)
+
+// Assemble builds a ROM from Octo source. fileName is only used in error
+// messages.
+func Assemble(src, fileName string) (*Program, error) {
+	a := &assembler{
+		fileName: fileName,
+		tokens:   tokenize(src),
+		here:     int(memory.PROGRAM_RAM_START),
+		end:      int(memory.PROGRAM_RAM_START),
+		labels:   make(map[string]uint16),
+		lines:    make(map[uint16]int),
+		consts:   make(map[string]float64),
+		aliases:  make(map[string]byte),
+		macros:   make(map[string]*macro),
+	}
+
+	for a.pos < len(a.tokens) {
+		if err := a.statement(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(a.blocks) > 0 {
+		b := a.blocks[len(a.blocks)-1]
+
+		return nil, a.errorf(b.tok, "unterminated %s", b.tok.text)
+	}
+
+	for _, f := range a.fixups {
+		addr, ok := a.labels[f.label]
+		if !ok {
+			return nil, a.errorf(f.tok, "undefined label %q", f.label)
+		}
+
+		if err := a.patchAddr(f.addr, int(addr), f.long, f.tok); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Program{
+		ROM:      slices.Clone(a.rom[memory.PROGRAM_RAM_START:a.end]),
+		FileName: fileName,
+		Labels:   a.labels,
+		Lines:    a.lines,
+	}, nil
+}
+
+// Position returns the file:line location addr was assembled from.
+func (p *Program) Position(addr uint16) (string, bool) {
+	line, ok := p.Lines[addr]
+	if !ok {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s:%d", filepath.Base(p.FileName), line), true
+}
+
+// Lookup resolves a label or a file:line location to the first address
+// assembled from that line, or from the next line with code.
+func (p *Program) Lookup(location string) (uint16, bool) {
+	if addr, ok := p.Labels[location]; ok {
+		return addr, true
+	}
+
+	file, lineStr, ok := strings.Cut(location, ":")
+	if !ok || (file != p.FileName && file != filepath.Base(p.FileName)) {
+		return 0, false
+	}
+
+	line, err := strconv.Atoi(lineStr)
+	if err != nil {
+		return 0, false
+	}
+
+	found, bestLine, best := false, 0, uint16(0)
+
+	for addr, l := range p.Lines {
+		if l < line {
+			continue
+		}
+
+		if !found || l < bestLine || (l == bestLine && addr < best) {
+			found, bestLine, best = true, l, addr
+		}
+	}
+
+	return best, found
+}
+
+func tokenize(src string) []token {
+	var tokens []token
+
+	for i, line := range strings.Split(src, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+
+		for _, f := range strings.Fields(line) {
+			tokens = append(tokens, token{text: f, line: i + 1})
+		}
+	}
+
+	return tokens
+}
+
+func (a *assembler) errorf(tok token, format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", a.fileName, tok.line, fmt.Sprintf(format, args...))
+}
+
+func (a *assembler) next() (token, error) {
+	if a.pos >= len(a.tokens) {
+		last := token{}
+		if len(a.tokens) > 0 {
+			last = a.tokens[len(a.tokens)-1]
+		}
+
+		return last, a.errorf(last, "unexpected end of file")
+	}
+
+	tok := a.tokens[a.pos]
+	a.pos++
+
+	return tok, nil
+}
+
+func (a *assembler) peek() string {
+	if a.pos < len(a.tokens) {
+		return a.tokens[a.pos].text
+	}
+
+	return ""
+}
+
+func (a *assembler) expect(text string) error {
+	tok, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if tok.text != text {
+		return a.errorf(tok, "expected %q, got %q", text, tok.text)
+	}
+
+	return nil
+}
+
+// mark records the source line of the instruction or data at the current
+// address.
+func (a *assembler) mark(tok token) {
+	a.lines[uint16(a.here)] = tok.line
+}
+
+func (a *assembler) emitByte(tok token, b byte) error {
+	if a.here < int(memory.PROGRAM_RAM_START) || a.here >= len(a.rom) {
+		return a.errorf(tok, "address 0x%X is outside of program memory", a.here)
+	}
+
+	a.rom[a.here] = b
+	a.here++
+	a.end = max(a.end, a.here)
+
+	return nil
+}
+
+func (a *assembler) emitWord(tok token, w uint16) error {
+	if err := a.emitByte(tok, byte(w>>8)); err != nil {
+		return err
+	}
+
+	return a.emitByte(tok, byte(w))
+}
+
+func (a *assembler) emit(tok token, op cpu.Op, x, y byte, imm uint16) error {
+	word, err := cpu.Encode(op, x, y, imm)
+	if err != nil {
+		return a.errorf(tok, "%v", err)
+	}
+
+	a.mark(tok)
+
+	return a.emitWord(tok, word)
+}
+
+// emitAddr emits op with an address operand, which may reference a label
+// defined later.
+func (a *assembler) emitAddr(tok token, op cpu.Op, x byte) error {
+	target, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	at := a.here
+
+	if err := a.emit(tok, op, x, 0, 0); err != nil {
+		return err
+	}
+
+	long := op == cpu.OP_LD_I_LONG
+	if long {
+		if err := a.emitWord(tok, 0); err != nil {
+			return err
+		}
+
+		at += 2
+	}
+
+	if v, ok := a.value(target.text); ok {
+		return a.patchAddr(at, v, long, target)
+	}
+
+	if !isIdentifier(target.text) {
+		return a.errorf(target, "invalid address %q", target.text)
+	}
+
+	a.fixups = append(a.fixups, fixup{addr: at, label: target.text, long: long, tok: target})
+
+	return nil
+}
+
+func (a *assembler) patchAddr(at, addr int, long bool, tok token) error {
+	if long {
+		if addr < 0 || addr > math.MaxUint16 {
+			return a.errorf(tok, "address 0x%X out of range", addr)
+		}
+
+		a.rom[at] = byte(addr >> 8)
+		a.rom[at+1] = byte(addr)
+
+		return nil
+	}
+
+	if addr < 0 || addr > int(cpu.ADDR_MASK) {
+		return a.errorf(tok, "address 0x%X out of range, use i := long", addr)
+	}
+
+	a.rom[at] |= byte(addr >> 8)
+	a.rom[at+1] = byte(addr)
+
+	return nil
+}
+
+// value resolves a number, constant or defined label.
+func (a *assembler) value(text string) (int, bool) {
+	if v, ok := a.consts[text]; ok {
+		return int(math.Floor(v)), true
+	}
+
+	if v, ok := a.labels[text]; ok {
+		return int(v), true
+	}
+
+	return parseNumber(text)
+}
+
+func parseNumber(text string) (int, bool) {
+	v, err := strconv.ParseInt(text, 0, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return int(v), true
+}
+
+func (a *assembler) byteOperand() (byte, error) {
+	tok, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := a.value(tok.text)
+	if !ok {
+		return 0, a.errorf(tok, "expected a number, got %q", tok.text)
+	}
+
+	if v < -128 || v > 255 {
+		return 0, a.errorf(tok, "value %d does not fit in a byte", v)
+	}
+
+	return byte(v), nil
+}
+
+func (a *assembler) nibbleOperand() (byte, error) {
+	tok := a.tokens[min(a.pos, len(a.tokens)-1)]
+
+	v, err := a.byteOperand()
+	if err != nil {
+		return 0, err
+	}
+
+	if v > 0xF {
+		return 0, a.errorf(tok, "value %d does not fit in a nibble", v)
+	}
+
+	return v, nil
+}
+
+func (a *assembler) register(text string) (byte, bool) {
+	if r, ok := a.aliases[text]; ok {
+		return r, true
+	}
+
+	if len(text) == 2 && (text[0] == 'v' || text[0] == 'V') {
+		if r, err := strconv.ParseUint(text[1:], 16, 4); err == nil {
+			return byte(r), true
+		}
+	}
+
+	return 0, false
+}
+
+func (a *assembler) registerOperand() (byte, error) {
+	tok, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+
+	r, ok := a.register(tok.text)
+	if !ok {
+		return 0, a.errorf(tok, "expected a register, got %q", tok.text)
+	}
+
+	return r, nil
+}
+
+func isIdentifier(text string) bool {
+	if text == "" || (text[0] >= '0' && text[0] <= '9') {
+		return false
+	}
+
+	for _, c := range text {
+		if !(c == '_' || c == '-' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a *assembler) defineName(tok token) error {
+	if !isIdentifier(tok.text) {
+		return a.errorf(tok, "invalid name %q", tok.text)
+	}
+
+	if _, ok := a.register(tok.text); ok {
+		return a.errorf(tok, "%q is a register", tok.text)
+	}
+
+	if _, ok := a.labels[tok.text]; ok {
+		return a.errorf(tok, "%q is already defined", tok.text)
+	}
+
+	if _, ok := a.consts[tok.text]; ok {
+		return a.errorf(tok, "%q is already defined", tok.text)
+	}
+
+	return nil
+}
+
+func (a *assembler) defineLabel(tok token, addr int) error {
+	if err := a.defineName(tok); err != nil {
+		return err
+	}
+
+	a.labels[tok.text] = uint16(addr)
+
+	return nil
+}
+
+func (a *assembler) statement() error {
+	tok, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if m, ok := a.macros[tok.text]; ok {
+		return a.expandMacro(tok, m)
+	}
+
+	if r, ok := a.register(tok.text); ok {
+		return a.registerStatement(tok, r)
+	}
+
+	switch tok.text {
+	case ":":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+
+		return a.defineLabel(name, a.here)
+	case ":next":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+
+		return a.defineLabel(name, a.here+1)
+	case ":const":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+
+		if err := a.defineName(name); err != nil {
+			return err
+		}
+
+		valueTok, err := a.next()
+		if err != nil {
+			return err
+		}
+
+		v, ok := a.value(valueTok.text)
+		if !ok {
+			return a.errorf(valueTok, "expected a number, got %q", valueTok.text)
+		}
+
+		a.consts[name.text] = float64(v)
+	case ":calc":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+
+		if err := a.defineName(name); err != nil {
+			return err
+		}
+
+		v, err := a.calc()
+		if err != nil {
+			return err
+		}
+
+		a.consts[name.text] = v
+	case ":alias":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+
+		if !isIdentifier(name.text) {
+			return a.errorf(name, "invalid name %q", name.text)
+		}
+
+		r, err := a.registerOperand()
+		if err != nil {
+			return err
+		}
+
+		a.aliases[name.text] = r
+	case ":macro":
+		return a.defineMacro()
+	case ":org":
+		v, err := a.addressOperand()
+		if err != nil {
+			return err
+		}
+
+		a.here = v
+	case ":byte":
+		if a.peek() == "{" {
+			v, err := a.calc()
+			if err != nil {
+				return err
+			}
+
+			a.mark(tok)
+
+			return a.emitByte(tok, byte(int(math.Floor(v))))
+		}
+
+		b, err := a.byteOperand()
+		if err != nil {
+			return err
+		}
+
+		a.mark(tok)
+
+		return a.emitByte(tok, b)
+	case ":call":
+		return a.emitAddr(tok, cpu.OP_CALL, 0)
+	case "clear":
+		return a.emit(tok, cpu.OP_CLS, 0, 0, 0)
+	case "return", ";":
+		return a.emit(tok, cpu.OP_RET, 0, 0, 0)
+	case "exit":
+		return a.emit(tok, cpu.OP_EXIT, 0, 0, 0)
+	case "lores":
+		return a.emit(tok, cpu.OP_LORES, 0, 0, 0)
+	case "hires":
+		return a.emit(tok, cpu.OP_HIRES, 0, 0, 0)
+	case "scroll-left":
+		return a.emit(tok, cpu.OP_SCL, 0, 0, 0)
+	case "scroll-right":
+		return a.emit(tok, cpu.OP_SCR, 0, 0, 0)
+	case "scroll-down", "scroll-up":
+		n, err := a.nibbleOperand()
+		if err != nil {
+			return err
+		}
+
+		op := cpu.OP_SCD
+		if tok.text == "scroll-up" {
+			op = cpu.OP_SCU
+		}
+
+		return a.emit(tok, op, 0, 0, uint16(n))
+	case "audio":
+		return a.emit(tok, cpu.OP_LDP, 0, 0, 0)
+	case "plane":
+		n, err := a.nibbleOperand()
+		if err != nil {
+			return err
+		}
+
+		return a.emit(tok, cpu.OP_SFB, n, 0, 0)
+	case "jump":
+		return a.emitAddr(tok, cpu.OP_JP, 0)
+	case "jump0":
+		return a.emitAddr(tok, cpu.OP_JP_V0, 0)
+	case "sprite":
+		x, err := a.registerOperand()
+		if err != nil {
+			return err
+		}
+
+		y, err := a.registerOperand()
+		if err != nil {
+			return err
+		}
+
+		n, err := a.nibbleOperand()
+		if err != nil {
+			return err
+		}
+
+		return a.emit(tok, cpu.OP_DRW, x, y, uint16(n))
+	case "bcd", "saveflags", "loadflags":
+		x, err := a.registerOperand()
+		if err != nil {
+			return err
+		}
+
+		return a.emit(tok, map[string]cpu.Op{"bcd": cpu.OP_LD_B_VX, "saveflags": cpu.OP_SF, "loadflags": cpu.OP_LF}[tok.text], x, 0, 0)
+	case "save", "load":
+		return a.memoryStatement(tok)
+	case "delay", "buzzer", "pitch":
+		if err := a.expect(":="); err != nil {
+			return err
+		}
+
+		x, err := a.registerOperand()
+		if err != nil {
+			return err
+		}
+
+		return a.emit(tok, map[string]cpu.Op{"delay": cpu.OP_LD_DT_VX, "buzzer": cpu.OP_LD_ST_VX, "pitch": cpu.OP_PITCH}[tok.text], x, 0, 0)
+	case "i":
+		return a.indexStatement(tok)
+	case "if":
+		return a.ifStatement(tok)
+	case "else":
+		return a.elseStatement(tok)
+	case "end":
+		return a.endStatement(tok)
+	case "loop":
+		a.blocks = append(a.blocks, block{kind: BK_LOOP, start: a.here, tok: tok})
+	case "while":
+		return a.whileStatement(tok)
+	case "again":
+		return a.againStatement(tok)
+	case ":proto":
+		_, err := a.next()
+
+		return err
+	default:
+		if v, ok := a.value(tok.text); ok {
+			if _, isLabel := a.labels[tok.text]; !isLabel {
+				if v < -128 || v > 255 {
+					return a.errorf(tok, "value %d does not fit in a byte", v)
+				}
+
+				a.mark(tok)
+
+				return a.emitByte(tok, byte(v))
+			}
+		}
+
+		if !isIdentifier(tok.text) || strings.HasPrefix(tok.text, ":") {
+			return a.errorf(tok, "unexpected %q", tok.text)
+		}
+
+		// A bare label calls the subroutine
+		a.pos--
+
+		return a.emitAddr(tok, cpu.OP_CALL, 0)
+	}
+
+	return nil
+}
+
+func (a *assembler) addressOperand() (int, error) {
+	tok, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := a.value(tok.text)
+	if !ok {
+		return 0, a.errorf(tok, "expected an address, got %q", tok.text)
+	}
+
+	return v, nil
+}
+
+func (a *assembler) registerStatement(tok token, x byte) error {
+	opTok, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	rhs, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if y, ok := a.register(rhs.text); ok {
+		op, ok := map[string]cpu.Op{
+			":=":  cpu.OP_LD_VX_VY,
+			"|=":  cpu.OP_OR,
+			"&=":  cpu.OP_AND,
+			"^=":  cpu.OP_XOR,
+			"+=":  cpu.OP_ADD_VX_VY,
+			"-=":  cpu.OP_SUB,
+			"=-":  cpu.OP_SUBN,
+			">>=": cpu.OP_SHR,
+			"<<=": cpu.OP_SHL,
+		}[opTok.text]
+		if !ok {
+			return a.errorf(opTok, "unexpected %q", opTok.text)
+		}
+
+		return a.emit(tok, op, x, y, 0)
+	}
+
+	switch opTok.text + " " + rhs.text {
+	case ":= delay":
+		return a.emit(tok, cpu.OP_LD_VX_DT, x, 0, 0)
+	case ":= key":
+		return a.emit(tok, cpu.OP_LD_VX_K, x, 0, 0)
+	case ":= random":
+		n, err := a.byteOperand()
+		if err != nil {
+			return err
+		}
+
+		return a.emit(tok, cpu.OP_RND, x, 0, uint16(n))
+	}
+
+	a.pos--
+
+	n, err := a.byteOperand()
+	if err != nil {
+		return err
+	}
+
+	switch opTok.text {
+	case ":=":
+		return a.emit(tok, cpu.OP_LD_VX_NN, x, 0, uint16(n))
+	case "+=":
+		return a.emit(tok, cpu.OP_ADD_VX_NN, x, 0, uint16(n))
+	case "-=":
+		return a.emit(tok, cpu.OP_ADD_VX_NN, x, 0, uint16(-n))
+	}
+
+	return a.errorf(opTok, "unexpected %q", opTok.text)
+}
+
+func (a *assembler) indexStatement(tok token) error {
+	opTok, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if opTok.text == "+=" {
+		x, err := a.registerOperand()
+		if err != nil {
+			return err
+		}
+
+		return a.emit(tok, cpu.OP_ADD_I_VX, x, 0, 0)
+	}
+
+	if opTok.text != ":=" {
+		return a.errorf(opTok, "unexpected %q", opTok.text)
+	}
+
+	switch a.peek() {
+	case "hex", "bighex":
+		kind, _ := a.next()
+
+		x, err := a.registerOperand()
+		if err != nil {
+			return err
+		}
+
+		op := cpu.OP_LD_F_VX
+		if kind.text == "bighex" {
+			op = cpu.OP_LD_HF_VX
+		}
+
+		return a.emit(tok, op, x, 0, 0)
+	case "long":
+		a.pos++
+
+		return a.emitAddr(tok, cpu.OP_LD_I_LONG, 0)
+	}
+
+	return a.emitAddr(tok, cpu.OP_LD_I, 0)
+}
+
+func (a *assembler) memoryStatement(tok token) error {
+	x, err := a.registerOperand()
+	if err != nil {
+		return err
+	}
+
+	if a.peek() == "-" {
+		a.pos++
+
+		y, err := a.registerOperand()
+		if err != nil {
+			return err
+		}
+
+		op := cpu.OP_SFM
+		if tok.text == "load" {
+			op = cpu.OP_LFM
+		}
+
+		return a.emit(tok, op, x, y, 0)
+	}
+
+	op := cpu.OP_LD_MEM_VX
+	if tok.text == "load" {
+		op = cpu.OP_LD_VX_MEM
+	}
+
+	return a.emit(tok, op, x, 0, 0)
+}
+
+// skipWhen parses a condition and emits the instructions skipping the next
+// one when the condition evaluates to want. Comparisons other than equality
+// use VF.
+func (a *assembler) skipWhen(tok token, want bool) error {
+	x, err := a.registerOperand()
+	if err != nil {
+		return err
+	}
+
+	opTok, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	switch opTok.text {
+	case "key", "-key":
+		op := cpu.OP_SKP
+		if (opTok.text == "key") != want {
+			op = cpu.OP_SKNP
+		}
+
+		return a.emit(tok, op, x, 0, 0)
+	case "==", "!=":
+		// The skip instructions test equality
+		if opTok.text == "!=" {
+			want = !want
+		}
+
+		rhs, err := a.next()
+		if err != nil {
+			return err
+		}
+
+		if y, ok := a.register(rhs.text); ok {
+			op := cpu.OP_SE_VX_VY
+			if !want {
+				op = cpu.OP_SNE_VX_VY
+			}
+
+			return a.emit(tok, op, x, y, 0)
+		}
+
+		a.pos--
+
+		n, err := a.byteOperand()
+		if err != nil {
+			return err
+		}
+
+		op := cpu.OP_SE_VX_NN
+		if !want {
+			op = cpu.OP_SNE_VX_NN
+		}
+
+		return a.emit(tok, op, x, 0, uint16(n))
+	case "<", ">", "<=", ">=":
+		return a.compare(tok, x, opTok.text, want)
+	}
+
+	return a.errorf(opTok, "unexpected %q in condition", opTok.text)
+}
+
+// compare computes the borrow flag of a subtraction in VF, which is 1 when
+// VX >= rhs for < and >=, and when rhs >= VX for > and <=, then skips on its
+// value.
+func (a *assembler) compare(tok token, x byte, cmp string, want bool) error {
+	rhs, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	y, isReg := a.register(rhs.text)
+	xFirst := cmp == "<" || cmp == ">="
+
+	switch {
+	case isReg:
+		first, second := x, y
+		if !xFirst {
+			first, second = y, x
+		}
+
+		if err := a.emit(tok, cpu.OP_LD_VX_VY, 0xF, first, 0); err != nil {
+			return err
+		}
+
+		err = a.emit(tok, cpu.OP_SUB, 0xF, second, 0)
+	default:
+		a.pos--
+
+		n, err := a.byteOperand()
+		if err != nil {
+			return err
+		}
+
+		if err := a.emit(tok, cpu.OP_LD_VX_NN, 0xF, 0, uint16(n)); err != nil {
+			return err
+		}
+
+		// VF = VX - n or VF = n - VX
+		op := cpu.OP_SUBN
+		if !xFirst {
+			op = cpu.OP_SUB
+		}
+
+		err = a.emit(tok, op, 0xF, x, 0)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// The condition holds when VF is 0 for < and >, and 1 for <= and >=
+	flag := uint16(0)
+	if cmp == "<=" || cmp == ">=" {
+		flag = 1
+	}
+
+	op := cpu.OP_SE_VX_NN
+	if !want {
+		op = cpu.OP_SNE_VX_NN
+	}
+
+	return a.emit(tok, op, 0xF, 0, flag)
+}
+
+func (a *assembler) ifStatement(tok token) error {
+	end := a.pos
+	for end < len(a.tokens) && a.tokens[end].text != "then" && a.tokens[end].text != "begin" {
+		end++
+	}
+
+	if end == len(a.tokens) {
+		return a.errorf(tok, "expected then or begin after if")
+	}
+
+	if a.tokens[end].text == "then" {
+		// Skip the next statement when the condition is false
+		if err := a.skipWhen(tok, false); err != nil {
+			return err
+		}
+
+		return a.expect("then")
+	}
+
+	// Skip the jump to the else branch when the condition is true
+	if err := a.skipWhen(tok, true); err != nil {
+		return err
+	}
+
+	if err := a.expect("begin"); err != nil {
+		return err
+	}
+
+	jump := a.here
+
+	if err := a.emit(tok, cpu.OP_JP, 0, 0, 0); err != nil {
+		return err
+	}
+
+	a.blocks = append(a.blocks, block{kind: BK_IF, jumps: []int{jump}, tok: tok})
+
+	return nil
+}
+
+func (a *assembler) elseStatement(tok token) error {
+	if len(a.blocks) == 0 || a.blocks[len(a.blocks)-1].kind != BK_IF {
+		return a.errorf(tok, "else without if")
+	}
+
+	b := &a.blocks[len(a.blocks)-1]
+	jump := a.here
+
+	if err := a.emit(tok, cpu.OP_JP, 0, 0, 0); err != nil {
+		return err
+	}
+
+	if err := a.patchJumps(b.jumps, tok); err != nil {
+		return err
+	}
+
+	b.kind = BK_ELSE
+	b.jumps = []int{jump}
+
+	return nil
+}
+
+func (a *assembler) endStatement(tok token) error {
+	if len(a.blocks) == 0 || a.blocks[len(a.blocks)-1].kind == BK_LOOP {
+		return a.errorf(tok, "end without if")
+	}
+
+	b := a.blocks[len(a.blocks)-1]
+	a.blocks = a.blocks[:len(a.blocks)-1]
+
+	return a.patchJumps(b.jumps, tok)
+}
+
+func (a *assembler) innermostLoop() *block {
+	for i := len(a.blocks) - 1; i >= 0; i-- {
+		if a.blocks[i].kind == BK_LOOP {
+			return &a.blocks[i]
+		}
+	}
+
+	return nil
+}
+
+func (a *assembler) whileStatement(tok token) error {
+	loop := a.innermostLoop()
+	if loop == nil {
+		return a.errorf(tok, "while outside of loop")
+	}
+
+	// Skip the jump out of the loop when the condition is true
+	if err := a.skipWhen(tok, true); err != nil {
+		return err
+	}
+
+	loop.jumps = append(loop.jumps, a.here)
+
+	return a.emit(tok, cpu.OP_JP, 0, 0, 0)
+}
+
+func (a *assembler) againStatement(tok token) error {
+	if len(a.blocks) == 0 || a.blocks[len(a.blocks)-1].kind != BK_LOOP {
+		return a.errorf(tok, "again without loop")
+	}
+
+	b := a.blocks[len(a.blocks)-1]
+	a.blocks = a.blocks[:len(a.blocks)-1]
+
+	if err := a.emit(tok, cpu.OP_JP, 0, 0, 0); err != nil {
+		return err
+	}
+
+	if err := a.patchAddr(a.here-2, b.start, false, tok); err != nil {
+		return err
+	}
+
+	return a.patchJumps(b.jumps, tok)
+}
+
+func (a *assembler) patchJumps(jumps []int, tok token) error {
+	for _, j := range jumps {
+		if err := a.patchAddr(j, a.here, false, tok); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *assembler) defineMacro() error {
+	name, err := a.next()
+	if err != nil {
+		return err
+	}
+
+	if !isIdentifier(name.text) {
+		return a.errorf(name, "invalid macro name %q", name.text)
+	}
+
+	m := &macro{}
+
+	for {
+		param, err := a.next()
+		if err != nil {
+			return err
+		}
+
+		if param.text == "{" {
+			break
+		}
+
+		m.params = append(m.params, param.text)
+	}
+
+	body, err := a.braced(name)
+	if err != nil {
+		return err
+	}
+
+	m.body = body
+	a.macros[name.text] = m
+
+	return nil
+}
+
+// braced returns the tokens up to the closing brace matching an already
+// consumed opening brace.
+func (a *assembler) braced(start token) ([]token, error) {
+	var body []token
+
+	for depth := 1; ; {
+		if a.pos >= len(a.tokens) {
+			return nil, a.errorf(start, "missing closing brace")
+		}
+
+		tok := a.tokens[a.pos]
+		a.pos++
+
+		switch tok.text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+
+		if depth == 0 {
+			return body, nil
+		}
+
+		body = append(body, tok)
+	}
+}
+
+func (a *assembler) expandMacro(tok token, m *macro) error {
+	a.expansions++
+	if a.expansions > MAX_MACRO_EXPANSIONS {
+		return a.errorf(tok, "too many macro expansions, is %s recursive?", tok.text)
+	}
+
+	args := make(map[string]string, len(m.params))
+
+	for _, p := range m.params {
+		arg, err := a.next()
+		if err != nil {
+			return err
+		}
+
+		args[p] = arg.text
+	}
+
+	expanded := make([]token, len(m.body))
+
+	for i, t := range m.body {
+		if arg, ok := args[t.text]; ok {
+			t.text = arg
+		}
+
+		expanded[i] = t
+	}
+
+	a.tokens = slices.Insert(a.tokens, a.pos, expanded...)
+
+	return nil
+}
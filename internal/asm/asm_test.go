@@ -0,0 +1,138 @@
+package asm_test
+
+import (
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/asm"
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssemble(t *testing.T) {
+	t.Run("Instructions", func(t *testing.T) {
+		p, err := asm.Assemble(`
+			: main
+				clear
+				v1 := 0x12  v1 += v2  v3 -= 1  v4 := random 0xF0
+				i := sprite  i := long sprite  i += v5  i := hex v6
+				sprite v0 v1 5
+				save v7  load v2 - v4
+				delay := v1  v2 := key
+				if v1 key then return
+				jump main
+			: sprite
+				0x3C :byte { 1 << 4 }
+		`, "test.8o")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{
+			0x00, 0xE0,
+			0x61, 0x12, 0x81, 0x24, 0x73, 0xFF, 0xC4, 0xF0,
+			0xA2, 0x24, 0xF0, 0x00, 0x02, 0x24, 0xF5, 0x1E, 0xF6, 0x29,
+			0xD0, 0x15,
+			0xF7, 0x55, 0x52, 0x43,
+			0xF1, 0x15, 0xF2, 0x0A,
+			0xE1, 0xA1, 0x00, 0xEE,
+			0x12, 0x00,
+			0x3C, 0x10,
+		}, p.ROM)
+		assert.Equal(t, uint16(0x224), p.Labels["sprite"])
+	})
+
+	t.Run("ControlFlow", func(t *testing.T) {
+		p, err := asm.Assemble(`
+			:alias counter v0
+			:const LIMIT 5
+			loop
+				counter += 1
+				while counter != LIMIT
+				if counter > 2 begin
+					v1 := 1
+				else
+					v1 := 2
+				end
+			again
+		`, "test.8o")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{
+			0x70, 0x01, // 200
+			0x40, 0x05, 0x12, 0x16, // 202 skip the exit when counter != 5
+			0x6F, 0x02, 0x8F, 0x05, 0x3F, 0x00, 0x12, 0x12, // 206 VF := 2 - counter
+			0x61, 0x01, 0x12, 0x14, // 20E
+			0x61, 0x02, // 212
+			0x12, 0x00, // 214
+		}, p.ROM)
+	})
+
+	t.Run("MacroAndCalc", func(t *testing.T) {
+		p, err := asm.Assemble(`
+			:calc size { 2 * 3 + 1 }
+			:macro set reg value { reg := value }
+			set v3 size
+			:org 0x210
+			:next target v0 := 0
+		`, "test.8o")
+		assert.NoError(t, err)
+		assert.Equal(t, byte(0x08), p.ROM[1], "operators are evaluated right to left")
+		assert.Equal(t, uint16(0x211), p.Labels["target"])
+		assert.Len(t, p.ROM, 0x12)
+	})
+
+	t.Run("SourceMap", func(t *testing.T) {
+		p, err := asm.Assemble(": main\n\tclear\n\n# comment\n\tv0 := 1 v1 := 2\n: data 0x12\n", "games/test.8o")
+		assert.NoError(t, err)
+
+		pos, ok := p.Position(0x204)
+		assert.True(t, ok)
+		assert.Equal(t, "test.8o:5", pos)
+
+		pos, _ = p.Position(0x206)
+		assert.Equal(t, "test.8o:6", pos)
+
+		for location, expected := range map[string]uint16{
+			"main":            0x200,
+			"data":            0x206,
+			"test.8o:3":       0x202,
+			"games/test.8o:5": 0x202,
+			"test.8o:2":       0x200,
+		} {
+			addr, ok := p.Lookup(location)
+			assert.True(t, ok, location)
+			assert.Equal(t, expected, addr, location)
+		}
+
+		_, ok = p.Lookup("test.8o:7")
+		assert.False(t, ok)
+		_, ok = p.Lookup("other.8o:2")
+		assert.False(t, ok)
+	})
+
+	t.Run("EncodeMatchesDisassemble", func(t *testing.T) {
+		for op := cpu.OP_CLS; op <= cpu.OP_LF; op++ {
+			word, err := cpu.Encode(op, 0, 0, 0)
+			if assert.NoError(t, err) {
+				assert.Equal(t, op, cpu.Disassemble(word, lib.CM_NONE).Op)
+			}
+		}
+
+		_, err := cpu.Encode(cpu.OP_JP, 1, 0, 0x200)
+		assert.Error(t, err)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for src, msg := range map[string]string{
+			"jump missing":      `test.8o:1: undefined label "missing"`,
+			"v0 := 0x100":       `test.8o:1: value 256 does not fit in a byte`,
+			"loop\nv0 += 1":     `test.8o:1: unterminated loop`,
+			": a : a":           `test.8o:1: "a" is already defined`,
+			"\n\nv0 := 1 end":   `test.8o:3: end without if`,
+			"i := 0x1000 ":      `test.8o:1: address 0x1000 out of range, use i := long`,
+			"sprite v0 v1 0x10": `test.8o:1: value 16 does not fit in a nibble`,
+			":calc x { 1 + y }": `test.8o:1: unknown name "y" in expression`,
+			":macro m { m }\nm": `test.8o:1: too many macro expansions, is m recursive?`,
+		} {
+			_, err := asm.Assemble(src, "test.8o")
+			assert.EqualError(t, err, msg, src)
+		}
+	})
+}
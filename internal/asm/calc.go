@@ -0,0 +1,182 @@
+package asm
+
+import (
+	"math"
+)
+
+var calcUnary = map[string]func(float64) float64{
+	"-":     func(v float64) float64 { return -v },
+	"~":     func(v float64) float64 { return float64(^int(v)) },
+	"!":     func(v float64) float64 { return boolFloat(v == 0) },
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"exp":   math.Exp,
+	"log":   math.Log,
+	"abs":   math.Abs,
+	"sqrt":  math.Sqrt,
+	"sign":  func(v float64) float64 { return float64(boolFloat(v > 0) - boolFloat(v < 0)) },
+	"ceil":  math.Ceil,
+	"floor": math.Floor,
+}
+
+var calcBinary = map[string]func(a, b float64) float64{
+	"+":   func(a, b float64) float64 { return a + b },
+	"-":   func(a, b float64) float64 { return a - b },
+	"*":   func(a, b float64) float64 { return a * b },
+	"/":   func(a, b float64) float64 { return a / b },
+	"%":   func(a, b float64) float64 { return float64(int(a) % int(b)) },
+	"&":   func(a, b float64) float64 { return float64(int(a) & int(b)) },
+	"|":   func(a, b float64) float64 { return float64(int(a) | int(b)) },
+	"^":   func(a, b float64) float64 { return float64(int(a) ^ int(b)) },
+	"<<":  func(a, b float64) float64 { return float64(int(a) << int(b)) },
+	">>":  func(a, b float64) float64 { return float64(int(a) >> int(b)) },
+	"pow": math.Pow,
+	"min": math.Min,
+	"max": math.Max,
+	"<":   func(a, b float64) float64 { return boolFloat(a < b) },
+	"<=":  func(a, b float64) float64 { return boolFloat(a <= b) },
+	">":   func(a, b float64) float64 { return boolFloat(a > b) },
+	">=":  func(a, b float64) float64 { return boolFloat(a >= b) },
+	"==":  func(a, b float64) float64 { return boolFloat(a == b) },
+	"!=":  func(a, b float64) float64 { return boolFloat(a != b) },
+}
+
+// calc evaluates a braced compile time expression. Like Octo, operators have
+// no precedence and are evaluated right to left: 2 * 3 + 1 is 8.
+func (a *assembler) calc() (float64, error) {
+	open, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+
+	if open.text != "{" {
+		return 0, a.errorf(open, "expected \"{\", got %q", open.text)
+	}
+
+	tokens, err := a.braced(open)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(tokens) == 0 {
+		return 0, a.errorf(open, "empty expression")
+	}
+
+	e := &calcExpr{a: a, tokens: tokens}
+
+	v, err := e.expr()
+	if err != nil {
+		return 0, err
+	}
+
+	if e.pos < len(tokens) {
+		return 0, a.errorf(tokens[e.pos], "unexpected %q in expression", tokens[e.pos].text)
+	}
+
+	return v, nil
+}
+
+type calcExpr struct {
+	a      *assembler
+	tokens []token
+	pos    int
+}
+
+func (e *calcExpr) expr() (float64, error) {
+	left, err := e.term()
+	if err != nil {
+		return 0, err
+	}
+
+	if e.pos >= len(e.tokens) {
+		return left, nil
+	}
+
+	op, ok := calcBinary[e.tokens[e.pos].text]
+	if !ok {
+		return left, nil
+	}
+
+	e.pos++
+
+	right, err := e.expr()
+	if err != nil {
+		return 0, err
+	}
+
+	return op(left, right), nil
+}
+
+func (e *calcExpr) term() (float64, error) {
+	if e.pos >= len(e.tokens) {
+		return 0, e.a.errorf(e.tokens[len(e.tokens)-1], "unexpected end of expression")
+	}
+
+	tok := e.tokens[e.pos]
+	e.pos++
+
+	if tok.text == "(" {
+		v, err := e.expr()
+		if err != nil {
+			return 0, err
+		}
+
+		if e.pos >= len(e.tokens) || e.tokens[e.pos].text != ")" {
+			return 0, e.a.errorf(tok, "missing closing parenthesis")
+		}
+
+		e.pos++
+
+		return v, nil
+	}
+
+	if op, ok := calcUnary[tok.text]; ok {
+		v, err := e.term()
+		if err != nil {
+			return 0, err
+		}
+
+		return op(v), nil
+	}
+
+	if tok.text == "@" {
+		addr, err := e.term()
+		if err != nil {
+			return 0, err
+		}
+
+		if addr < 0 || int(addr) >= len(e.a.rom) {
+			return 0, e.a.errorf(tok, "address %v out of range", addr)
+		}
+
+		return float64(e.a.rom[int(addr)]), nil
+	}
+
+	switch tok.text {
+	case "HERE":
+		return float64(e.a.here), nil
+	case "PI":
+		return math.Pi, nil
+	case "E":
+		return math.E, nil
+	}
+
+	if v, ok := e.a.consts[tok.text]; ok {
+		return v, nil
+	}
+
+	if v, ok := e.a.value(tok.text); ok {
+		return float64(v), nil
+	}
+
+	return 0, e.a.errorf(tok, "unknown name %q in expression", tok.text)
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -1,100 +1,265 @@
 package chip8
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math/rand/v2"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/Zyko0/go-sdl3/bin/binsdl"
 	"github.com/Zyko0/go-sdl3/sdl"
 	"github.com/cterence/chip8-go/internal/chip8/components/apu"
-	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
 	"github.com/cterence/chip8-go/internal/chip8/components/debugger"
-	"github.com/cterence/chip8-go/internal/chip8/components/memory"
-	"github.com/cterence/chip8-go/internal/chip8/components/timer"
+	"github.com/cterence/chip8-go/internal/chip8/components/frontend"
+	"github.com/cterence/chip8-go/internal/chip8/components/recorder"
+	"github.com/cterence/chip8-go/internal/chip8/components/rewind"
+	"github.com/cterence/chip8-go/internal/chip8/components/terminal"
 	"github.com/cterence/chip8-go/internal/chip8/components/ui"
+	"github.com/cterence/chip8-go/internal/disasm"
+	"github.com/cterence/chip8-go/internal/hardware"
 	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/cterence/chip8-go/internal/movie"
+	"github.com/cterence/chip8-go/internal/romdb"
+	"github.com/cterence/chip8-go/internal/trace"
+	"github.com/cterence/chip8-go/internal/wav"
 )
 
+// Chip8 runs a hardware.Machine in real time with a frontend, and adds the
+// debugger, rewind, recordings and traces on top of it.
 type Chip8 struct {
-	cpu      *cpu.CPU
-	mem      *memory.Memory
+	machine  *hardware.Machine
+	hw       *hardware.Components
+	frontend frontend.Frontend
 	ui       *ui.UI
-	timer    *timer.Timer
 	debugger *debugger.Debugger
-	apu      *apu.APU
-
-	cpuOptions []cpu.Option
-	uiOptions  []ui.Option
-	apuOptions []apu.Option
-
-	currentCPUTPS float32
-	cpuTicks      int
-	paused        bool
-	lastFrame     time.Time
-	lastTimerTick time.Time
-	lastCPUTick   time.Time
+	rewind   *rewind.Buffer
+	recorder *recorder.Recorder
+
+	rewindBuf bytes.Buffer
+	tracer    *trace.Writer
+	audioOut  *wav.Writer
+
+	inputRecorder *movie.Writer
+	inputMovie    *movie.Movie
+	movieEvent    int
+	// Settings given as options that differ from the replayed movie
+	movieMismatch error
+
+	machineOptions  []hardware.Option
+	romDatabase     *romdb.Database
+	staticAnalysis  bool
+	uiOptions       []ui.Option
+	terminalOptions []terminal.Option
+	debuggerOptions []debugger.Option
+
+	paused    bool
+	fault     error
+	rewinding bool
+	frames    int
+	nextFrame time.Time
 
 	// Options
 	debug              bool
 	romBytes           []byte
+	compatibilityMode  lib.CompatibilityMode
+	quirkOverrides     []lib.QuirkOverride
+	ipf                int
+	legacyRand         bool
+	sourceMap          debugger.SourceMap
 	romFileName        string
 	headless           bool
+	frontendType       FrontendType
+	faultPolicy        FaultPolicy
 	tickLimit          int
 	exitAfterTickLimit bool
 	screenshot         bool
-	testFlag           byte
+	nativeScreenshot   bool
 	speed              float32
+	stateFile          string
+	rewindSeconds      int
+	traceFile          string
+	traceFormat        trace.Format
+	recordFile         string
+	audioOutFile       string
+	recordInputFile    string
+	seed               uint64
+	seeded             bool
+	scale              int
 }
 
 const (
-	CPU_TPS   float32 = 550
-	TIMER_TPS float32 = 60
-	UI_FPS    float32 = 60
+	// Timers, display and input are updated once per frame
+	FPS float32 = 60
+
+	// Take a rewind snapshot every REWIND_FRAME_INTERVAL UI frames
+	REWIND_FRAME_INTERVAL = 2
+
+	// Below it, the static analysis result is ignored
+	MIN_ANALYSIS_CONFIDENCE = 0.5
+)
+
+type FrontendType uint8
+
+const (
+	FT_SDL FrontendType = iota
+	FT_TERMINAL
+)
+
+// FaultPolicy is what happens when an instruction cannot be executed.
+type FaultPolicy uint8
+
+const (
+	// Stop the run with the fault as error
+	FP_EXIT FaultPolicy = iota
+	// Pause on the faulting instruction, for the debugger
+	FP_PAUSE
+	// Log the fault and skip the instruction
+	FP_IGNORE
 )
 
 type Option func(*Chip8)
 
+func ParseFrontendType(s string) (FrontendType, error) {
+	switch s {
+	case "sdl":
+		return FT_SDL, nil
+	case "terminal":
+		return FT_TERMINAL, nil
+	default:
+		return 0, fmt.Errorf("unknown frontend: %s", s)
+	}
+}
+
+func ParseFaultPolicy(s string) (FaultPolicy, error) {
+	switch s {
+	case "exit":
+		return FP_EXIT, nil
+	case "pause":
+		return FP_PAUSE, nil
+	case "ignore":
+		return FP_IGNORE, nil
+	default:
+		return 0, fmt.Errorf("unknown fault policy: %s", s)
+	}
+}
+
 func New(romBytes []byte, options ...Option) *Chip8 {
 	c8 := &Chip8{
-		romBytes: romBytes,
+		romBytes:       romBytes,
+		romDatabase:    romdb.Embedded(),
+		staticAnalysis: true,
 	}
 
 	for _, o := range options {
 		o(c8)
 	}
 
-	mem := memory.New()
-	ui := ui.New(c8.uiOptions...)
-	apu := apu.New(c8.apuOptions...)
-	t := timer.New(apu)
-	cpu := cpu.New(mem, ui, t, apu, c8.cpuOptions...)
-	debugger := debugger.New(cpu, mem)
+	if c8.inputMovie != nil {
+		c8.movieMismatch = c8.applyMovieHeader(c8.inputMovie.Header)
+	}
+
+	hooks := frontend.Hooks{
+		ResetChip8:        c8.reset,
+		IsChip8Paused:     func() bool { return c8.paused },
+		TogglePauseChip8:  c8.togglePause,
+		TickChip8:         c8.tick,
+		SaveStateChip8:    c8.saveStateSlot,
+		LoadStateChip8:    c8.loadStateSlot,
+		RewindChip8:       c8.setRewinding,
+		ToggleRecordChip8: c8.toggleRecording,
+		KeyChangedChip8:   c8.keyChanged,
+		TakeOverChip8:     c8.takeOver,
+		Chip8Fault:        func() error { return c8.fault },
+	}
+
+	if c8.romDatabase != nil {
+		if entry, ok := c8.romDatabase.Lookup(romBytes); ok {
+			c8.applyROMEntry(entry)
+			hooks.ProgramTitle = entry.Title
+		}
+	}
+
+	if c8.compatibilityMode == lib.CM_NONE && c8.staticAnalysis && c8.inputMovie == nil {
+		c8.applyAnalysis(disasm.Analyze(romBytes))
+	}
+
+	c8.machineOptions = append(c8.machineOptions,
+		hardware.WithCompatibilityMode(c8.compatibilityMode),
+		hardware.WithQuirkOverrides(c8.quirkOverrides),
+		hardware.WithIPF(c8.ipf),
+		hardware.WithLegacyRand(c8.legacyRand),
+	)
+
+	if c8.inputMovie != nil {
+		c8.seed, c8.seeded = c8.inputMovie.Seed, true
+	} else if c8.recordInputFile != "" && !c8.seeded {
+		// Movies can only be replayed with the same random sequence
+		c8.seed, c8.seeded = rand.Uint64(), true
+	}
+
+	if c8.seeded {
+		c8.machineOptions = append(c8.machineOptions, hardware.WithSeed(c8.seed))
+	}
+
+	// 00FD hands control to the debugger like a breakpoint
+	c8.machine = hardware.New(append(c8.machineOptions, hardware.WithExitHandler(c8.togglePause))...)
+	c8.hw = c8.machine.Components()
+
+	debuggerOptions := append([]debugger.Option{debugger.WithSourceMap(c8.sourceMap)}, c8.debuggerOptions...)
+
+	if !c8.headless {
+		switch c8.frontendType {
+		case FT_SDL:
+			c8.ui = ui.New(c8.hw.Display, c8.uiOptions...)
+			c8.ui.Hooks = hooks
+			c8.frontend = c8.ui
+		case FT_TERMINAL:
+			// The terminal owns stdin and stdout, the debugger prompt is shown under the display
+			term := terminal.New(c8.hw.Display, c8.terminalOptions...)
+			term.Hooks = hooks
+			debuggerOptions = append(debuggerOptions, debugger.WithInput(term.Commands()), debugger.WithOutput(term))
+			c8.frontend = term
+		}
+	}
 
-	c8.mem = mem
-	c8.cpu = cpu
-	c8.ui = ui
-	c8.timer = t
-	c8.debugger = debugger
-	c8.apu = apu
+	c8.debugger = debugger.New(c8.hw.CPU, c8.hw.Memory, c8.hw.Timer, debuggerOptions...)
 
-	c8.ui.ResetChip8 = c8.init
-	c8.ui.IsChip8Paused = func() bool { return c8.paused }
-	c8.ui.TogglePauseChip8 = c8.togglePause
-	c8.ui.TickChip8 = c8.tick
-	c8.cpu.SetCurrentTPS = c8.SetCurrentCPUTPS
+	if c8.rewindSeconds > 0 && !c8.headless {
+		c8.rewind = rewind.New(c8.rewindSeconds * int(FPS) / REWIND_FRAME_INTERVAL)
+	}
 
 	return c8
 }
 
+// WithCompatibilityMode forces a compatibility mode, ignoring the platform and
+// quirks of the ROM database.
 func WithCompatibilityMode(mode lib.CompatibilityMode) Option {
 	return func(c *Chip8) {
-		c.cpuOptions = append(c.cpuOptions, cpu.WithCompatibilityMode(mode))
-		c.uiOptions = append(c.uiOptions, ui.WithCompatibilityMode(mode))
+		c.compatibilityMode = mode
+	}
+}
+
+// WithQuirkOverrides changes quirks on top of the compatibility mode or the
+// ROM database ones.
+func WithQuirkOverrides(overrides []lib.QuirkOverride) Option {
+	return func(c *Chip8) {
+		c.quirkOverrides = overrides
+	}
+}
+
+// WithROMDatabase looks the ROM up in db instead of the embedded database,
+// nil disables the lookup.
+func WithROMDatabase(db *romdb.Database) Option {
+	return func(c *Chip8) {
+		c.romDatabase = db
 	}
 }
 
@@ -128,15 +293,53 @@ func WithScreenshot(screenshot bool) Option {
 	}
 }
 
+func WithNativeScreenshot(native bool) Option {
+	return func(c *Chip8) {
+		c.nativeScreenshot = native
+	}
+}
+
 func WithRomFileName(romFileName string) Option {
 	return func(c *Chip8) {
 		c.romFileName = romFileName
-		c.cpuOptions = append(c.cpuOptions, cpu.WithRomFileName(romFileName))
+		c.machineOptions = append(c.machineOptions, hardware.WithRomFileName(romFileName))
+	}
+}
+
+// WithSourceMap links the ROM to the source it was assembled from, for the
+// debugger.
+func WithSourceMap(sourceMap debugger.SourceMap) Option {
+	return func(c *Chip8) {
+		c.sourceMap = sourceMap
+	}
+}
+
+// WithTrace records the machine state after every instruction to a file.
+func WithTrace(traceFile string, format trace.Format) Option {
+	return func(c *Chip8) {
+		c.traceFile = traceFile
+		c.traceFormat = format
+	}
+}
+
+// WithAudioOut writes the audio output to a WAV file, even when it is not
+// played.
+func WithAudioOut(audioOutFile string) Option {
+	return func(c *Chip8) {
+		c.audioOutFile = audioOutFile
+	}
+}
+
+// WithRecord records the display to an animated GIF from the start.
+func WithRecord(recordFile string) Option {
+	return func(c *Chip8) {
+		c.recordFile = recordFile
 	}
 }
 
 func WithScale(scale int) Option {
 	return func(c *Chip8) {
+		c.scale = scale
 		c.uiOptions = append(c.uiOptions, ui.WithScale(scale))
 	}
 }
@@ -147,38 +350,168 @@ func WithSpeed(speed float32) Option {
 	}
 }
 
+// WithStaticAnalysis picks the compatibility mode from the instructions of
+// the ROM when it is neither forced nor found in the ROM database. Without
+// it, the mode is detected at runtime.
+func WithStaticAnalysis(staticAnalysis bool) Option {
+	return func(c *Chip8) {
+		c.staticAnalysis = staticAnalysis
+	}
+}
+
+// WithIPF sets the number of instructions executed per frame, overriding the
+// compatibility mode and ROM database default when greater than 0.
+func WithIPF(ipf int) Option {
+	return func(c *Chip8) {
+		c.ipf = ipf
+	}
+}
+
+// WithSeed seeds the random number generator used by CXNN, making runs
+// reproducible.
+func WithSeed(seed uint64) Option {
+	return func(c *Chip8) {
+		c.seed = seed
+		c.seeded = true
+	}
+}
+
+// WithRecordInput records the keypad to an input movie. A random seed is
+// chosen and stored in the movie when none is set.
+func WithRecordInput(recordInputFile string) Option {
+	return func(c *Chip8) {
+		c.recordInputFile = recordInputFile
+	}
+}
+
+// WithPlayInput replays the keypad of an input movie, using its seed and
+// machine settings. The keyboard is ignored until the take over key is pressed
+// or the movie ends.
+func WithPlayInput(m *movie.Movie) Option {
+	return func(c *Chip8) {
+		c.inputMovie = m
+	}
+}
+
+func WithLegacyRand(legacyRand bool) Option {
+	return func(c *Chip8) {
+		c.legacyRand = legacyRand
+	}
+}
+
 func WithHeadless(headless bool) Option {
 	return func(c *Chip8) {
 		c.headless = headless
 	}
 }
 
+// WithFrontend selects how the display is presented and the keyboard read
+// when not headless.
+func WithFrontend(frontendType FrontendType) Option {
+	return func(c *Chip8) {
+		c.frontendType = frontendType
+	}
+}
+
+// WithKeyTimeout sets how long the terminal frontend holds a key after the
+// terminal last sent it.
+func WithKeyTimeout(timeout time.Duration) Option {
+	return func(c *Chip8) {
+		c.terminalOptions = append(c.terminalOptions, terminal.WithKeyTimeout(timeout))
+	}
+}
+
+// WithDebuggerInput reads the debugger commands from in instead of stdin,
+// with the SDL frontend or headless.
+func WithDebuggerInput(in io.Reader) Option {
+	return func(c *Chip8) {
+		c.debuggerOptions = append(c.debuggerOptions, debugger.WithInput(in))
+	}
+}
+
+// WithFaultPolicy sets what happens when an instruction cannot be executed,
+// the run stops by default.
+func WithFaultPolicy(policy FaultPolicy) Option {
+	return func(c *Chip8) {
+		c.faultPolicy = policy
+	}
+}
+
 func WithTestFlag(testFlag byte) Option {
 	return func(c *Chip8) {
-		c.testFlag = testFlag
+		c.machineOptions = append(c.machineOptions, hardware.WithTestFlag(testFlag))
 	}
 }
 
 func WithAudioDisabled(audioDisabled bool) Option {
 	return func(c *Chip8) {
-		c.apuOptions = append(c.apuOptions, apu.WithAudioDisabled(audioDisabled))
+		c.uiOptions = append(c.uiOptions, ui.WithAudioDisabled(audioDisabled))
 	}
 }
 
-func (c8 *Chip8) SetCurrentCPUTPS(tps float32) {
-	c8.currentCPUTPS = tps
+func WithLoadState(stateFile string) Option {
+	return func(c *Chip8) {
+		c.stateFile = stateFile
+	}
 }
 
-func (c8 *Chip8) GetCPUPeriod() time.Duration {
-	return time.Second / time.Duration(c8.currentCPUTPS*c8.speed)
+func WithRewindSeconds(seconds int) Option {
+	return func(c *Chip8) {
+		c.rewindSeconds = seconds
+	}
 }
 
-func (c8 *Chip8) GetTimerPeriod() time.Duration {
-	return time.Second / time.Duration(TIMER_TPS*c8.speed)
+// applyROMEntry uses the settings the ROM database has for the ROM, unless
+// set by options: a forced compatibility mode also replaces the database
+// quirks, quirk overrides are applied after them. A replayed movie keeps the
+// mode, quirks and IPF it was recorded with.
+func (c8 *Chip8) applyROMEntry(entry romdb.Entry) {
+	log.Printf("ROM database: %s", entry.Title)
+
+	if c8.inputMovie == nil {
+		if c8.compatibilityMode == lib.CM_NONE && entry.Mode != lib.CM_NONE {
+			log.Printf("ROM database: platform %s", entry.Platform)
+
+			c8.compatibilityMode = entry.Mode
+			c8.quirkOverrides = slices.Concat(entry.Quirks, c8.quirkOverrides)
+		}
+
+		if c8.ipf == 0 {
+			c8.ipf = entry.IPF
+		}
+	}
+
+	if entry.Palette != nil {
+		c8.machineOptions = append(c8.machineOptions, hardware.WithPalette(entry.Palette))
+	}
+
+	if entry.KeyMap != nil {
+		c8.uiOptions = append(c8.uiOptions, ui.WithKeyMap(entry.KeyMap))
+		c8.terminalOptions = append(c8.terminalOptions, terminal.WithKeyMap(entry.KeyMap))
+	}
+}
+
+// applyAnalysis forces the mode found by the static analysis when it is based
+// on SUPER-CHIP or XO-CHIP instructions and confident enough, leaving it to
+// runtime detection otherwise: such instructions may be hidden behind a
+// computed jump, and a forced mode is never upgraded.
+func (c8 *Chip8) applyAnalysis(report disasm.Report) {
+	log.Printf("static analysis: %s, %.0f%% confidence", report.Mode, report.Confidence*100)
+
+	if report.Conclusive() && report.Confidence >= MIN_ANALYSIS_CONFIDENCE {
+		c8.compatibilityMode = report.Mode
+	}
+}
+
+func (c8 *Chip8) GetFramePeriod() time.Duration {
+	return time.Duration(float32(time.Second) / (FPS * c8.speed))
 }
 
-func (c8 *Chip8) GetUIPeriod() time.Duration {
-	return time.Second / time.Duration(UI_FPS)
+// CompatibilityMode returns the mode the run starts in, forced or found in
+// the ROM database or by the static analysis. CM_NONE means it is detected at
+// runtime.
+func (c8 *Chip8) CompatibilityMode() lib.CompatibilityMode {
+	return c8.compatibilityMode
 }
 
 func (c8 *Chip8) Run(ctx context.Context) error {
@@ -187,125 +520,403 @@ func (c8 *Chip8) Run(ctx context.Context) error {
 
 	trapSigInt(cancel)
 
-	if !c8.headless {
+	if c8.ui != nil {
 		defer binsdl.Load().Unload()
 		defer sdl.Quit()
-		defer c8.ui.Destroy()
+	}
 
-		if c8.screenshot {
-			defer c8.ui.Screenshot(c8.romFileName)
-		}
+	if c8.frontend != nil {
+		defer c8.frontend.Destroy()
+	}
+
+	if c8.screenshot {
+		defer c8.saveScreenshot()
 	}
 
 	if err := c8.init(); err != nil {
 		return fmt.Errorf("failed to init chip8: %w", err)
 	}
 
+	if c8.stateFile != "" {
+		if err := c8.loadStateFile(c8.stateFile); err != nil {
+			return fmt.Errorf("failed to load state: %w", err)
+		}
+	}
+
+	if c8.traceFile != "" {
+		closeTrace, err := c8.openTrace()
+		if err != nil {
+			return err
+		}
+
+		defer closeTrace()
+	}
+
+	if c8.inputMovie != nil && c8.inputMovie.ROMHash != movie.HashROM(c8.romBytes) {
+		return fmt.Errorf("input movie was recorded with a different rom")
+	}
+
+	if c8.movieMismatch != nil {
+		return c8.movieMismatch
+	}
+
+	if c8.recordInputFile != "" {
+		closeRecording, err := c8.openInputRecording()
+		if err != nil {
+			return err
+		}
+
+		defer closeRecording()
+	}
+
+	if c8.audioOutFile != "" {
+		closeAudioOut, err := c8.openAudioOut()
+		if err != nil {
+			return err
+		}
+
+		defer closeAudioOut()
+	}
+
+	if c8.recordFile != "" {
+		c8.recorder = recorder.New(c8.scale)
+	}
+
+	defer c8.stopRecording()
+
 	for {
 		select {
 		case <-rCtx.Done():
 			return nil
 		default:
-			if !c8.paused {
-				if err := c8.tick(); err != nil {
+			if err := c8.frame(cancel); err != nil {
+				return err
+			}
+
+			if c8.frontend != nil {
+				if err := c8.frontend.HandleEvents(); errors.Is(err, frontend.ErrQuit) {
+					return nil
+				} else if err != nil {
 					return err
 				}
+			}
 
-				c8.handleTickLimitReached(cancel)
+			// Headless runs are not throttled unless waiting for debugger commands
+			if !c8.headless || c8.paused {
+				c8.waitNextFrame()
 			}
+		}
+	}
+}
 
-			if !c8.headless {
-				if time.Since(c8.lastFrame) >= c8.GetUIPeriod() {
-					c8.lastFrame = time.Now()
+// frame runs one 60 Hz frame: the instructions for this frame, then one timer
+// tick, then the display update.
+func (c8 *Chip8) frame(cancel context.CancelFunc) error {
+	c8.frames++
 
-					err := c8.ui.Update()
-					if err != nil {
-						return fmt.Errorf("failed to update UI: %w", err)
-					}
-				}
+	if err := c8.updateRewind(); err != nil {
+		return fmt.Errorf("failed to update rewind buffer: %w", err)
+	}
 
-				if err := c8.ui.HandleEvents(); err != nil {
-					return err
-				}
+	if c8.paused && c8.debugger.Poll() {
+		c8.paused = false
+	}
+
+	// Without a frontend, nothing else can resume the machine
+	if c8.paused && c8.frontend == nil && c8.debugger.Closed() {
+		log.Println("debugger input closed, exiting")
+
+		if c8.fault != nil {
+			return c8.fault
+		}
+
+		cancel()
+
+		return nil
+	}
+
+	if !c8.paused && !c8.rewinding {
+		for range c8.machine.IPF() {
+			if c8.paused || c8.machine.WaitingForVBlank() {
+				break
+			}
+
+			if err := c8.tick(); err != nil {
+				return err
 			}
+
+			if c8.handleTickLimitReached(cancel) {
+				break
+			}
+
+			if c8.debugger.ShouldBreak() {
+				c8.setPaused(true)
+			}
+		}
+
+		c8.machine.EndFrame()
+		c8.outputAudio()
+	}
+
+	if c8.frontend != nil {
+		if err := c8.frontend.Update(); err != nil {
+			return fmt.Errorf("failed to update UI: %w", err)
 		}
 	}
+
+	if c8.recorder != nil {
+		c8.recorder.Add(c8.machine.Framebuffer())
+	}
+
+	return nil
 }
 
-func (c8 *Chip8) loadROM() {
-	l := len(c8.romBytes)
-	lib.Assert(l <= int(memory.PROGRAM_RAM_SIZE), fmt.Errorf("rom file size %d is bigger than chip8 program ram %d", l, memory.PROGRAM_RAM_SIZE))
+func (c8 *Chip8) waitNextFrame() {
+	c8.nextFrame = c8.nextFrame.Add(c8.GetFramePeriod())
 
-	for i, b := range c8.romBytes {
-		a := uint16(i) + memory.PROGRAM_RAM_START
-		c8.mem.Write(a, b)
+	if d := time.Until(c8.nextFrame); d > 0 {
+		time.Sleep(d)
+	} else {
+		// Running late, don't try to catch up on missed frames
+		c8.nextFrame = time.Now()
 	}
 }
 
 func (c8 *Chip8) init() error {
 	c8.paused = false
-	c8.cpuTicks = 0
-	c8.lastTimerTick = time.Now()
-	c8.lastCPUTick = time.Now()
-	c8.lastFrame = time.Now()
-
-	c8.mem.Init()
-	c8.cpu.Init()
-	c8.timer.Init()
+	c8.fault = nil
+	c8.rewinding = false
+	c8.frames = 0
+	c8.nextFrame = time.Now()
 
-	if err := c8.apu.Init(); err != nil {
-		return fmt.Errorf("failed to init timer: %w", err)
+	if err := c8.machine.LoadROM(c8.romBytes); err != nil {
+		return err
 	}
 
-	if !c8.headless {
-		if err := c8.ui.Init(); err != nil {
+	if c8.frontend != nil {
+		if err := c8.frontend.Init(); err != nil {
 			return fmt.Errorf("failed to init UI: %w", err)
 		}
 	}
 
-	if c8.testFlag != 0 {
-		c8.mem.Write(0x1FF, c8.testFlag)
+	if c8.rewind != nil {
+		c8.rewind.Reset()
 	}
 
-	c8.loadROM()
-
 	return nil
 }
 
 func (c8 *Chip8) tick() error {
-	if time.Since(c8.lastCPUTick) >= c8.GetCPUPeriod() {
-		c8.lastCPUTick = time.Now()
-		c8.cpu.Tick()
+	c8.playInput()
 
-		if c8.debug {
-			log.Println(c8.debugger.DebugLog())
-		}
+	var pc, opcode uint16
+
+	if c8.tracer != nil {
+		pc, opcode = c8.hw.CPU.PC(), c8.hw.CPU.Opcode()
+	}
+
+	if err := c8.machine.Step(); err != nil {
+		return c8.handleFault(err)
+	}
 
-		c8.cpuTicks++
+	if c8.debug {
+		log.Println(c8.debugger.DebugLog())
 	}
 
-	if time.Since(c8.lastTimerTick) >= c8.GetTimerPeriod() {
-		c8.lastTimerTick = time.Now()
-		c8.timer.Tick()
+	if c8.tracer != nil {
+		if err := c8.tracer.Write(c8.traceRecord(pc, opcode)); err != nil {
+			return fmt.Errorf("failed to write trace: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func (c8 *Chip8) handleTickLimitReached(cancel context.CancelFunc) {
-	if c8.tickLimit > 0 && c8.cpuTicks == c8.tickLimit {
-		log.Printf("tick limit reached: %d", c8.tickLimit)
+// handleFault applies the fault policy to an instruction that could not be
+// executed.
+func (c8 *Chip8) handleFault(err error) error {
+	switch c8.faultPolicy {
+	case FP_PAUSE:
+		log.Printf("fault: %v", err)
+
+		c8.setPaused(true)
+		c8.fault = err
+	case FP_IGNORE:
+		log.Printf("fault ignored: %v", err)
+
+		c8.machine.Skip()
+	default:
+		return err
+	}
+
+	return nil
+}
 
-		if c8.exitAfterTickLimit {
-			cancel()
+func (c8 *Chip8) openTrace() (func(), error) {
+	f, err := os.Create(c8.traceFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace file: %w", err)
+	}
+
+	c8.tracer, err = trace.NewWriter(f, c8.traceFormat)
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	return func() {
+		if err := c8.tracer.Flush(); err != nil {
+			log.Printf("failed to write trace: %v", err)
 		}
 
+		f.Close()
+	}, nil
+}
+
+func (c8 *Chip8) openAudioOut() (func(), error) {
+	f, err := os.Create(c8.audioOutFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audio output file: %w", err)
+	}
+
+	w, err := wav.NewWriter(f, apu.SAMPLE_RATE)
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	c8.audioOut = w
+
+	return func() {
+		c8.audioOut = nil
+
+		if err := w.Close(); err != nil {
+			log.Printf("failed to write audio output: %v", err)
+		}
+
+		f.Close()
+	}, nil
+}
+
+// outputAudio plays the samples of the last frame and writes them to the
+// audio output file.
+func (c8 *Chip8) outputAudio() {
+	samples := c8.machine.AudioSamples()
+
+	if c8.audioOut != nil {
+		if _, err := c8.audioOut.Write(samples); err != nil {
+			log.Printf("failed to write audio output: %v", err)
+		}
+	}
+
+	if c8.ui != nil {
+		c8.ui.PlayAudio(samples)
+	}
+}
+
+// traceRecord returns the state after executing the instruction at pc.
+func (c8 *Chip8) traceRecord(pc, opcode uint16) trace.Record {
+	regs := c8.machine.Registers()
+
+	return trace.Record{
+		Tick:      uint64(c8.machine.Ticks()),
+		PC:        pc,
+		Opcode:    opcode,
+		I:         regs.I,
+		SP:        regs.SP,
+		Delay:     regs.Delay,
+		Sound:     regs.Sound,
+		Registers: regs.V,
+	}
+}
+
+func (c8 *Chip8) handleTickLimitReached(cancel context.CancelFunc) bool {
+	if c8.tickLimit == 0 || c8.machine.Ticks() != c8.tickLimit {
+		return false
+	}
+
+	log.Printf("tick limit reached: %d", c8.tickLimit)
+
+	if c8.exitAfterTickLimit {
+		cancel()
+
 		c8.paused = true
+
+		return true
+	}
+
+	c8.setPaused(true)
+
+	return true
+}
+
+func (c8 *Chip8) setRewinding(rewinding bool) {
+	if rewinding && !c8.canTravelInTime() {
+		log.Println("rewind is disabled while recording or replaying input")
+
+		return
+	}
+
+	c8.rewinding = rewinding && c8.rewind != nil
+}
+
+// updateRewind records a snapshot every REWIND_FRAME_INTERVAL frames, or steps
+// one snapshot back in time while rewinding.
+func (c8 *Chip8) updateRewind() error {
+	if c8.rewind == nil {
+		return nil
+	}
+
+	if c8.rewinding {
+		snapshot, ok := c8.rewind.Pop()
+		if !ok {
+			return nil
+		}
+
+		// Stay on the oldest snapshot instead of emptying the buffer
+		if c8.rewind.Len() == 0 {
+			c8.rewind.Push(snapshot)
+		}
+
+		return c8.LoadState(bytes.NewReader(snapshot))
+	}
+
+	if c8.paused || c8.frames%REWIND_FRAME_INTERVAL != 0 {
+		return nil
 	}
+
+	c8.rewindBuf.Reset()
+
+	if err := c8.SaveState(&c8.rewindBuf); err != nil {
+		return err
+	}
+
+	c8.rewind.Push(c8.rewindBuf.Bytes())
+
+	return nil
 }
 
 func (c8 *Chip8) togglePause() {
-	c8.paused = !c8.paused
+	c8.setPaused(!c8.paused)
+}
+
+// setPaused pauses or resumes the machine, handing control to the debugger
+// prompt while paused.
+func (c8 *Chip8) setPaused(paused bool) {
+	if paused == c8.paused {
+		return
+	}
+
+	c8.paused = paused
+	c8.fault = nil
+
+	if paused {
+		c8.debugger.Enter()
+	} else {
+		c8.debugger.Leave()
+	}
 }
 
 func trapSigInt(cancel context.CancelFunc) {
@@ -2,19 +2,18 @@ package apu
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"math"
 
-	"github.com/Zyko0/go-sdl3/sdl"
 	"github.com/cterence/chip8-go/internal/lib"
 )
 
+// APU generates the XO-CHIP audio pattern samples, one timer tick at a time.
+// Playing them is up to the frontend.
 type APU struct {
-	CompatibilityMode lib.CompatibilityMode
-	audioDisabled     bool
+	out io.Writer
 
-	device       sdl.AudioDeviceID
-	audioStream  *sdl.AudioStream
 	pattern      [16]byte
 	sampleRate   int32
 	playbackRate float64
@@ -24,10 +23,21 @@ type APU struct {
 const (
 	TPS                 = 60
 	PATTERN_BUFFER_BITS = 128
+	SAMPLE_RATE         = 44100
+
+	SAMPLE_LOW     = 64
+	SAMPLE_HIGH    = 192
+	SAMPLE_SILENCE = 128
 )
 
 type Option func(*APU)
 
+type State struct {
+	Pattern      [16]byte
+	PlaybackRate float64
+	Phase        float64
+}
+
 func New(options ...Option) *APU {
 	a := &APU{}
 
@@ -38,11 +48,7 @@ func New(options ...Option) *APU {
 	return a
 }
 
-func (a *APU) Init() error {
-	if a.audioDisabled {
-		return nil
-	}
-
+func (a *APU) Init() {
 	// Beep pattern
 	a.pattern = [16]byte{
 		0xF0, 0x0, 0x0, 0x0,
@@ -51,53 +57,46 @@ func (a *APU) Init() error {
 		0xF0, 0x0, 0x0, 0x0,
 	}
 	a.playbackRate = 4000
-	a.sampleRate = 44100
+	a.sampleRate = SAMPLE_RATE
 	a.phase = 0
+}
 
-	spec := &sdl.AudioSpec{
-		Freq:     a.sampleRate,
-		Format:   sdl.AUDIO_U8,
-		Channels: 1,
-	}
+// SetOutput sends the generated samples to w, one timer tick of samples per
+// call to PlaySound or PlaySilence.
+func (a *APU) SetOutput(w io.Writer) {
+	a.out = w
+}
 
-	err := sdl.Init(sdl.INIT_AUDIO)
-	if err != nil {
-		return fmt.Errorf("failed to init sdl audio: %w", err)
+func (a *APU) PlaySound() {
+	if a.out == nil {
+		return
 	}
 
-	a.device, err = sdl.AUDIO_DEVICE_DEFAULT_PLAYBACK.OpenAudioDevice(spec)
-	if err != nil {
-		return fmt.Errorf("failed to get default playback audio device: %w", err)
-	}
+	a.write(a.generateSound())
+}
 
-	if a.audioStream == nil {
-		a.audioStream, err = sdl.CreateAudioStream(spec, spec)
-		if err != nil {
-			return fmt.Errorf("failed to create audio stream: %w", err)
-		}
+// PlaySilence outputs one timer tick of silence.
+func (a *APU) PlaySilence() {
+	if a.out == nil {
+		return
 	}
 
-	if a.audioStream.Device() == 0 {
-		if err := a.device.BindAudioStream(a.audioStream); err != nil {
-			return fmt.Errorf("failed to bind audio stream to device: %w", err)
-		}
+	silence := make([]byte, a.sampleRate/TPS)
+	for i := range silence {
+		silence[i] = SAMPLE_SILENCE
 	}
 
-	return nil
+	a.write(silence)
 }
 
-func WithAudioDisabled(audioDisabled bool) Option {
-	return func(a *APU) {
-		a.audioDisabled = audioDisabled
-	}
-}
-
-func (a *APU) PlaySound() {
-	if a.audioDisabled {
+func (a *APU) write(samples []byte) {
+	if a.out == nil {
 		return
 	}
 
-	a.playPatternBuffer()
+	if _, err := a.out.Write(samples); err != nil {
+		log.Printf("failed to write audio output: %v", err)
+	}
 }
 
 func (a *APU) FillPatternBuffer(bytes [16]byte) {
@@ -113,19 +112,32 @@ func (a *APU) ResetPhase() {
 	a.phase = 0
 }
 
-func (a *APU) playPatternBuffer() {
-	available, err := a.audioStream.Available()
-	if err != nil {
-		log.Printf("failed to get available audio stream: %v", err)
+func (a *APU) SaveState() State {
+	return State{
+		Pattern:      a.pattern,
+		PlaybackRate: a.playbackRate,
+		Phase:        a.phase,
 	}
+}
 
-	sound := a.generateSound()
+// Validate checks that the state can be loaded without breaking the sound
+// generation.
+func (s State) Validate() error {
+	if math.IsNaN(s.PlaybackRate) || math.IsInf(s.PlaybackRate, 0) || s.PlaybackRate < 0 {
+		return fmt.Errorf("invalid playback rate: %v", s.PlaybackRate)
+	}
 
-	if available < int32(len(sound)) {
-		if err := a.audioStream.PutData(sound); err != nil {
-			log.Printf("failed to put data to audio stream: %v", err)
-		}
+	if math.IsNaN(s.Phase) || s.Phase < 0 || s.Phase >= PATTERN_BUFFER_BITS {
+		return fmt.Errorf("invalid phase: %v", s.Phase)
 	}
+
+	return nil
+}
+
+func (a *APU) LoadState(s State) {
+	a.pattern = s.Pattern
+	a.playbackRate = s.PlaybackRate
+	a.phase = s.Phase
 }
 
 func (a *APU) generateSound() []byte {
@@ -140,9 +152,9 @@ func (a *APU) generateSound() []byte {
 		b := lib.Bit(a.pattern[byteIdx], bitIdx)
 
 		if b == 1 {
-			sound[i] = 192
+			sound[i] = SAMPLE_HIGH
 		} else {
-			sound[i] = 64
+			sound[i] = SAMPLE_LOW
 		}
 
 		a.phase += step
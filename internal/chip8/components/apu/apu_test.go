@@ -0,0 +1,34 @@
+package apu_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/apu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutput(t *testing.T) {
+	record := func() []byte {
+		var out bytes.Buffer
+
+		a := apu.New()
+		a.Init()
+		a.SetOutput(&out)
+
+		a.PlaySound()
+		a.PlaySilence()
+		a.SetPlaybackRate(100)
+		a.PlaySound()
+
+		return out.Bytes()
+	}
+
+	samples := record()
+	tick := apu.SAMPLE_RATE / apu.TPS
+
+	assert.Len(t, samples, 3*tick)
+	assert.Equal(t, []byte{apu.SAMPLE_HIGH, apu.SAMPLE_HIGH}, samples[:2])
+	assert.Equal(t, bytes.Repeat([]byte{apu.SAMPLE_SILENCE}, tick), samples[tick:2*tick])
+	assert.Equal(t, samples, record())
+}
@@ -1,20 +1,20 @@
 package cpu
 
 import (
+	"encoding"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math"
-	"math/rand"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
-	"github.com/cterence/chip8-go/internal/chip8/components/apu"
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/chip8/components/frontend"
 	"github.com/cterence/chip8-go/internal/chip8/components/memory"
 	"github.com/cterence/chip8-go/internal/chip8/components/timer"
-	"github.com/cterence/chip8-go/internal/chip8/components/ui"
 	"github.com/cterence/chip8-go/internal/lib"
 )
 
@@ -23,10 +23,11 @@ type register struct {
 }
 
 type CPU struct {
-	mem   *memory.Memory
-	ui    *ui.UI
-	timer *timer.Timer
-	apu   *apu.APU
+	mem     *memory.Memory
+	display frontend.Display
+	keypad  frontend.Keypad
+	timer   *timer.Timer
+	sound   frontend.Sound
 
 	reg   [REGISTER_COUNT]register
 	pc    uint16
@@ -38,10 +39,19 @@ type CPU struct {
 	pressedKey              *byte
 	forcedCompatibilityMode bool
 	compatibilityMode       lib.CompatibilityMode
+	quirks                  lib.Quirks
+	quirkOverrides          []lib.QuirkOverride
+	waitingForVBlank        bool
+	lastPC                  uint16
+	randSource              RandSource
+	rand                    *rand.Rand
+	initialRandState        []byte
+	legacyRand              bool
 	ticks                   int
 	debugInfo               debugInfo
 
-	SetCurrentTPS func(float32)
+	SetCurrentIPF    func(int)
+	TogglePauseChip8 func()
 }
 
 type regStorage struct {
@@ -50,30 +60,82 @@ type regStorage struct {
 
 type Option func(*CPU)
 
+// RandSource is a random source whose state can be stored in snapshots.
+type RandSource interface {
+	rand.Source
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+type State struct {
+	Registers         [REGISTER_COUNT]byte
+	PC                uint16
+	I                 uint16
+	Stack             [STACK_SIZE]uint16
+	SP                uint8
+	PressedKey        byte
+	WaitingForKey     bool
+	CompatibilityMode lib.CompatibilityMode
+	WaitingForVBlank  bool
+	Ticks             int64
+	RandState         [RAND_STATE_SIZE]byte
+	RandStateLen      uint8
+}
+
+// debugInfo keeps the last instruction decoded, its mnemonic is only formatted
+// when the debugger asks for it.
 type debugInfo struct {
-	inst string
+	inst Instruction
+}
+
+// mnemonic returns the last instruction formatted, or nothing before the
+// first one, whose Size is never 0 once decoded.
+func (d debugInfo) mnemonic() string {
+	if d.inst.Size == 0 {
+		return ""
+	}
+
+	return d.inst.String()
 }
 
 const (
-	REGISTER_COUNT     byte   = 16
-	STACK_SIZE         byte   = 16
-	ADDR_MASK          uint16 = 0xFFF
-	TPS                       = 660
-	TARGET_TICK_PERIOD        = time.Second / TPS
+	REGISTER_COUNT byte   = 16
+	STACK_SIZE     byte   = 16
+	ADDR_MASK      uint16 = 0xFFF
+
+	// Maximum size of a marshaled RandSource
+	RAND_STATE_SIZE = 64
+
+	// Default instructions per frame of each compatibility mode
+	IPF_CHIP8     = 8    // ~500 instructions per second
+	IPF_SUPERCHIP = 12   // ~700 instructions per second
+	IPF_XOCHIP    = 1000 // XO-CHIP programs expect a fast interpreter
 )
 
-func New(mem *memory.Memory, ui *ui.UI, t *timer.Timer, apu *apu.APU, options ...Option) *CPU {
+func New(mem *memory.Memory, d frontend.Display, k frontend.Keypad, t *timer.Timer, s frontend.Sound, options ...Option) *CPU {
 	c := &CPU{
-		mem:   mem,
-		ui:    ui,
-		timer: t,
-		apu:   apu,
+		mem:     mem,
+		display: d,
+		keypad:  k,
+		timer:   t,
+		sound:   s,
 	}
 
 	for _, o := range options {
 		o(c)
 	}
 
+	if c.randSource == nil {
+		c.randSource = rand.NewPCG(rand.Uint64(), rand.Uint64())
+	}
+
+	c.rand = rand.New(c.randSource)
+
+	initialRandState, err := c.randSource.MarshalBinary()
+	lib.Assert(err == nil && len(initialRandState) <= RAND_STATE_SIZE, fmt.Errorf("random source state must be at most %d bytes", RAND_STATE_SIZE))
+
+	c.initialRandState = initialRandState
+
 	return c
 }
 
@@ -84,6 +146,28 @@ func WithCompatibilityMode(mode lib.CompatibilityMode) Option {
 	}
 }
 
+func WithQuirkOverrides(overrides []lib.QuirkOverride) Option {
+	return func(c *CPU) {
+		c.quirkOverrides = overrides
+	}
+}
+
+// WithRandSource sets the random source used by CXNN. Runs using sources
+// created with the same seed are reproducible.
+func WithRandSource(src RandSource) Option {
+	return func(c *CPU) {
+		c.randSource = src
+	}
+}
+
+// WithLegacyRand reproduces the historical CXNN distribution, which never
+// yields 0xFF.
+func WithLegacyRand(legacyRand bool) Option {
+	return func(c *CPU) {
+		c.legacyRand = legacyRand
+	}
+}
+
 func WithRomFileName(romFileName string) Option {
 	return func(c *CPU) {
 		c.romFileName = romFileName
@@ -103,26 +187,197 @@ func (c *CPU) Init() {
 	c.pc = memory.PROGRAM_RAM_START
 	c.sp = 0
 	c.pressedKey = nil
+	c.waitingForVBlank = false
 	c.updateCompatibilityMode(c.compatibilityMode)
+
+	// Restart the random sequence so that a reset run is reproducible
+	err := c.randSource.UnmarshalBinary(c.initialRandState)
+	lib.Assert(err == nil, fmt.Errorf("failed to reset random source: %w", err))
+
 	c.ticks = 0
 }
 
-func (c *CPU) Tick() {
-	inst := c.decodeInstruction()
-	c.execute(inst)
+// Tick executes the instruction at PC. A *Fault is returned, and nothing is
+// executed, when the instruction is invalid.
+func (c *CPU) Tick() error {
+	pc := c.pc
+
+	word, err := c.decodeInstruction()
+	if err != nil {
+		return &Fault{Err: err, PC: pc}
+	}
+
+	if err := c.execute(word); err != nil {
+		return &Fault{Err: err, PC: pc, Opcode: word}
+	}
+
+	c.lastPC = pc
 	c.ticks++
+
+	return nil
+}
+
+// Skip moves PC past the instruction at PC without executing it, to carry on
+// after a fault.
+func (c *CPU) Skip() {
+	word, _ := c.decodeInstruction()
+	c.pc += Disassemble(word, c.compatibilityMode).Size
+}
+
+func (c *CPU) PC() uint16 {
+	return c.pc
+}
+
+func (c *CPU) SetPC(pc uint16) {
+	c.pc = pc
+}
+
+func (c *CPU) I() uint16 {
+	return c.i
+}
+
+func (c *CPU) SetI(i uint16) {
+	c.i = i
+}
+
+func (c *CPU) SP() uint8 {
+	return c.sp
+}
+
+func (c *CPU) Register(reg byte) byte {
+	return c.readReg(reg)
+}
+
+func (c *CPU) SetRegister(reg byte, v byte) {
+	c.writeReg(reg, v)
+}
+
+// Stack returns the return addresses currently pushed, oldest first.
+func (c *CPU) Stack() []uint16 {
+	return c.stack[:c.sp]
+}
+
+func (c *CPU) Ticks() int {
+	return c.ticks
+}
+
+// LastPC returns the address of the last executed instruction.
+func (c *CPU) LastPC() uint16 {
+	return c.lastPC
+}
+
+// LastInstruction returns the mnemonic of the last executed instruction.
+func (c *CPU) LastInstruction() string {
+	return c.debugInfo.mnemonic()
+}
+
+// Opcode returns the instruction at PC, which runs on the next tick, or 0
+// when PC is out of bounds.
+func (c *CPU) Opcode() uint16 {
+	word, _ := c.decodeInstruction()
+
+	return word
+}
+
+// WaitingForVBlank reports whether the CPU is stalled after a draw until the
+// next vertical blank (display wait quirk).
+func (c *CPU) WaitingForVBlank() bool {
+	return c.waitingForVBlank
+}
+
+// VBlank signals the 60 Hz vertical blank, releasing a CPU stalled by DXYN.
+func (c *CPU) VBlank() {
+	c.waitingForVBlank = false
+}
+
+func (c *CPU) SaveState() State {
+	s := State{
+		PC:                c.pc,
+		I:                 c.i,
+		Stack:             c.stack,
+		SP:                c.sp,
+		CompatibilityMode: c.compatibilityMode,
+		WaitingForVBlank:  c.waitingForVBlank,
+		Ticks:             int64(c.ticks),
+	}
+
+	for r, v := range c.reg {
+		s.Registers[r] = v.value
+	}
+
+	if c.pressedKey != nil {
+		s.PressedKey = *c.pressedKey
+		s.WaitingForKey = true
+	}
+
+	randState, err := c.randSource.MarshalBinary()
+	lib.Assert(err == nil && len(randState) <= RAND_STATE_SIZE, fmt.Errorf("random source state must be at most %d bytes", RAND_STATE_SIZE))
+
+	s.RandStateLen = uint8(copy(s.RandState[:], randState))
+
+	return s
+}
+
+// Validate checks that the state can be loaded without breaking the CPU
+// invariants.
+func (s State) Validate() error {
+	if s.RandStateLen > RAND_STATE_SIZE {
+		return fmt.Errorf("invalid random source state length: %d", s.RandStateLen)
+	}
+
+	if s.SP > STACK_SIZE {
+		return fmt.Errorf("stack pointer %d beyond stack size %d", s.SP, STACK_SIZE)
+	}
+
+	if s.CompatibilityMode > lib.CM_XOCHIP {
+		return fmt.Errorf("unknown compatibility mode: %d", s.CompatibilityMode)
+	}
+
+	return nil
+}
+
+// LoadState restores a state, the CPU is left untouched when it is invalid.
+func (c *CPU) LoadState(s State) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.randSource.UnmarshalBinary(s.RandState[:s.RandStateLen]); err != nil {
+		return fmt.Errorf("failed to restore random source: %w", err)
+	}
+
+	for r, v := range s.Registers {
+		c.writeReg(byte(r), v)
+	}
+
+	c.pc = s.PC
+	c.i = s.I
+	c.stack = s.Stack
+	c.sp = s.SP
+	c.ticks = int(s.Ticks)
+	c.waitingForVBlank = s.WaitingForVBlank
+	c.pressedKey = nil
+
+	if s.WaitingForKey {
+		key := s.PressedKey
+		c.pressedKey = &key
+	}
+
+	c.applyCompatibilityMode(s.CompatibilityMode)
+
+	return nil
 }
 
 func (c *CPU) DebugInfo() string {
 	var debugInfo strings.Builder
 
-	debugInfo.WriteString(fmt.Sprintf("OP: %-13s ", c.debugInfo.inst))
+	debugInfo.WriteString(fmt.Sprintf("OP: %-13s ", c.debugInfo.mnemonic()))
 	debugInfo.WriteString(fmt.Sprintf("TK: %-5d ", c.ticks))
 	debugInfo.WriteString("PC:" + lib.FormatHex(c.pc, 4) + " ")
 	debugInfo.WriteString("SP:" + lib.FormatHex(c.sp, 2) + " ")
 	debugInfo.WriteString("I:" + lib.FormatHex(c.i, 4) + " ")
-	debugInfo.WriteString("ST:" + lib.FormatHex(c.stack[c.sp], 4) + " ")
-	debugInfo.WriteString("MEM:" + lib.FormatHex(c.mem.Read(c.pc), 2) + lib.FormatHex(c.mem.Read(c.pc+1), 2) + " ")
+	debugInfo.WriteString("ST:" + lib.FormatHex(c.top(), 4) + " ")
+	debugInfo.WriteString("MEM:" + lib.FormatHex(c.Opcode(), 4) + " ")
 
 	for r, v := range c.reg {
 		rs, vs := lib.FormatHex(byte(r), 1), lib.FormatHex(v.value, 2)
@@ -136,29 +391,45 @@ func (c *CPU) DebugInfo() string {
 	return debugInfo.String()
 }
 
-func (c *CPU) readReg(reg byte) byte {
-	lib.Assert(reg < REGISTER_COUNT, fmt.Errorf("illegal read to register V%s", lib.FormatHex(reg, 2)))
-	v := c.reg[reg].value
+// top returns the last pushed return address, 0 when the stack is empty.
+func (c *CPU) top() uint16 {
+	if c.sp == 0 {
+		return 0
+	}
 
-	return v
+	return c.stack[c.sp-1]
 }
 
-func (c *CPU) writeReg(reg byte, v byte) {
-	lib.Assert(reg < REGISTER_COUNT, fmt.Errorf("illegal write to register V%s", lib.FormatHex(reg, 2)))
+// readReg and writeReg take the register from an opcode nibble, which always
+// indexes the register file.
+func (c *CPU) readReg(reg byte) byte {
+	return c.reg[reg].value
+}
 
+func (c *CPU) writeReg(reg byte, v byte) {
 	c.reg[reg].value = v
 }
 
-func (c *CPU) pushStack(v uint16) {
+func (c *CPU) pushStack(v uint16) error {
+	if c.sp >= STACK_SIZE {
+		return ErrStackOverflow
+	}
+
 	c.stack[c.sp] = v
 	c.sp++
+
+	return nil
 }
 
-func (c *CPU) popStack() uint16 {
+func (c *CPU) popStack() (uint16, error) {
+	if c.sp == 0 {
+		return 0, ErrStackUnderflow
+	}
+
 	c.sp--
 	v := c.stack[c.sp]
 
-	return v
+	return v, nil
 }
 
 func (c *CPU) updateCompatibilityMode(mode lib.CompatibilityMode) {
@@ -171,541 +442,469 @@ func (c *CPU) updateCompatibilityMode(mode lib.CompatibilityMode) {
 		return
 	}
 
+	c.applyCompatibilityMode(mode)
+}
+
+func (c *CPU) applyCompatibilityMode(mode lib.CompatibilityMode) {
 	c.compatibilityMode = mode
-	c.apu.CompatibilityMode = mode
+	c.quirks = lib.DefaultQuirks(mode).With(c.quirkOverrides)
 	c.timer.CompatibilityMode = mode
+	c.display.SetQuirks(c.quirks)
 
 	switch mode {
 	case lib.CM_CHIP8, lib.CM_NONE:
-		c.SetCurrentTPS(500)
+		c.SetCurrentIPF(IPF_CHIP8)
 	case lib.CM_SUPERCHIP:
-		c.SetCurrentTPS(700)
+		c.SetCurrentIPF(IPF_SUPERCHIP)
 	case lib.CM_XOCHIP:
-		c.SetCurrentTPS(math.MaxFloat32) // Unlimited CPU ticks
-	}
-}
-
-func (c *CPU) decodeInstruction() uint16 {
-	lib.Assert(c.pc < memory.PROGRAM_RAM_END, fmt.Errorf("illegal program counter position: 0x%03X", c.pc))
-
-	hi := uint16(c.mem.Read(c.pc)) << lib.BYTE_SIZE
-	lo := uint16(c.mem.Read(c.pc + 1))
-
-	return hi | lo
-}
-
-func (c *CPU) execute(inst uint16) {
-	implemented := true
-
-	hi := byte(inst >> uint16(lib.BYTE_SIZE))
-	lo := byte(inst)
-
-	lo0, lo1, hi0, hi1 := lo&0xF, (lo>>4)&0xF, hi&0xF, (hi>>4)&0xF
-
-	switch hi1 {
-	case 0x0:
-		switch hi0 {
-		case 0x0:
-			switch lo1 {
-			case 0xE:
-				switch lo0 {
-				case 0x0:
-					c.debugInfo.inst = "CLS"
-					c.ui.Reset()
-				case 0xE:
-					c.debugInfo.inst = "RET"
-					c.pc = c.popStack()
-				default:
-					implemented = false
-				}
-			case 0xC:
-				c.updateCompatibilityMode(lib.CM_SUPERCHIP)
-				c.debugInfo.inst = "SCD " + lib.FormatHex(lo0, 1)
-				c.ui.Scroll(ui.SD_DOWN, int(lo0))
-			case 0xD:
-				c.updateCompatibilityMode(lib.CM_XOCHIP)
-				c.debugInfo.inst = "SCU " + lib.FormatHex(lo0, 1)
-				c.ui.Scroll(ui.SD_UP, int(lo0))
-			case 0xF:
-				switch lo0 {
-				case 0xB:
-					c.updateCompatibilityMode(lib.CM_SUPERCHIP)
-					c.debugInfo.inst = "SCR 4"
-					c.ui.Scroll(ui.SD_RIGHT, 4)
-				case 0xC:
-					c.updateCompatibilityMode(lib.CM_SUPERCHIP)
-					c.debugInfo.inst = "SCL 4"
-					c.ui.Scroll(ui.SD_LEFT, 4)
-				case 0xD:
-					c.debugInfo.inst = "EXIT"
-
-					log.Println("exit called, pausing instead")
-					c.ui.TogglePauseChip8()
-				case 0xE:
-					c.updateCompatibilityMode(lib.CM_SUPERCHIP)
-					c.debugInfo.inst = "LORES"
-					c.ui.ToggleHiRes(false)
-				case 0xF:
-					c.updateCompatibilityMode(lib.CM_SUPERCHIP)
-					c.debugInfo.inst = "HIRES"
-					c.ui.ToggleHiRes(true)
-				default:
-					implemented = false
-				}
-			default:
-				implemented = false
-			}
-		default:
-			implemented = false
-		}
-	case 0x1:
-		v := inst & ADDR_MASK
-		c.debugInfo.inst = "JP " + lib.FormatHex(v, 3)
+		c.SetCurrentIPF(IPF_XOCHIP)
+	}
+}
+
+func (c *CPU) decodeInstruction() (uint16, error) {
+	return c.fetch(c.pc)
+}
 
-		c.pc = v
+// fetch returns the 2 bytes word at a, which must fit in memory.
+func (c *CPU) fetch(a uint16) (uint16, error) {
+	if a >= memory.RAM_SIZE-1 {
+		return 0, ErrPCOutOfBounds
+	}
 
-		return
-	case 0x2:
-		v := inst & ADDR_MASK
-		c.debugInfo.inst = "CALL " + lib.FormatHex(v, 3)
-		c.pushStack(c.pc)
-		c.pc = v
+	hi := uint16(c.mem.Peek(a)) << lib.BYTE_SIZE
+	lo := uint16(c.mem.Peek(a + 1))
 
-		return
-	case 0x3:
-		c.debugInfo.inst = "SE V" + lib.FormatHex(hi0, 1) + ", " + lib.FormatHex(lo, 2)
+	return hi | lo, nil
+}
 
-		if c.readReg(hi0) == lo {
-			c.pc += 2
-			if c.decodeInstruction() == 0xF000 {
-				c.pc += 2
-			}
+// checkMemory returns ErrMemoryOutOfBounds when the length bytes from I do not
+// all fit in memory, instead of wrapping around to address 0.
+func (c *CPU) checkMemory(length int) error {
+	if int(c.i)+length > memory.RAM_SIZE {
+		return ErrMemoryOutOfBounds
+	}
+
+	return nil
+}
+
+// execute runs an instruction. Errors are returned before any side effect.
+func (c *CPU) execute(word uint16) error {
+	inst := Disassemble(word, c.compatibilityMode)
+	x, y, n, nn, nnn := inst.X(), inst.Y(), inst.N(), inst.NN(), inst.NNN()
+
+	if inst.Op == OP_UNKNOWN {
+		return ErrUnknownOpcode
+	}
+
+	switch inst.Op {
+	case OP_CLS:
+		c.display.Reset()
+	case OP_RET:
+		pc, err := c.popStack()
+		if err != nil {
+			return err
 		}
-	case 0x4:
-		c.debugInfo.inst = "SNE V" + lib.FormatHex(hi0, 1) + ", " + lib.FormatHex(lo, 2)
 
-		if c.readReg(hi0) != lo {
-			c.pc += 2
-			if c.decodeInstruction() == 0xF000 {
-				c.pc += 2
-			}
+		c.pc = pc
+	case OP_SCD:
+		c.updateCompatibilityMode(lib.CM_SUPERCHIP)
+		c.display.Scroll(display.SD_DOWN, int(n))
+	case OP_SCU:
+		c.updateCompatibilityMode(lib.CM_XOCHIP)
+		c.display.Scroll(display.SD_UP, int(n))
+	case OP_SCR:
+		c.updateCompatibilityMode(lib.CM_SUPERCHIP)
+		c.display.Scroll(display.SD_RIGHT, 4)
+	case OP_SCL:
+		c.updateCompatibilityMode(lib.CM_SUPERCHIP)
+		c.display.Scroll(display.SD_LEFT, 4)
+	case OP_EXIT:
+		log.Println("exit called, pausing instead")
+		c.TogglePauseChip8()
+	case OP_LORES:
+		c.updateCompatibilityMode(lib.CM_SUPERCHIP)
+		c.display.ToggleHiRes(false)
+	case OP_HIRES:
+		c.updateCompatibilityMode(lib.CM_SUPERCHIP)
+		c.display.ToggleHiRes(true)
+	case OP_JP:
+		c.debugInfo.inst = inst
+		c.pc = nnn
+
+		return nil
+	case OP_CALL:
+		if err := c.pushStack(c.pc); err != nil {
+			return err
 		}
-	case 0x5:
-		switch lo0 {
-		case 0x2:
-			c.debugInfo.inst = "SFM V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1)
-			c.updateCompatibilityMode(lib.CM_XOCHIP)
-
-			regCount := byte(math.Abs(float64(hi0)-float64(lo1))) + 1
-
-			if hi0 < lo1 {
-				for i := range regCount {
-					c.mem.Write(c.i+uint16(i), c.readReg(hi0+i))
-				}
-			} else {
-				for i := range regCount {
-					c.mem.Write(c.i+uint16(i), c.readReg(hi0-i))
-				}
-			}
-		case 0x3:
-			c.debugInfo.inst = "LFM V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1)
-			c.updateCompatibilityMode(lib.CM_XOCHIP)
-
-			regCount := byte(math.Abs(float64(hi0)-float64(lo1))) + 1
-
-			if hi0 < lo1 {
-				for i := range regCount {
-					c.writeReg(hi0+i, c.mem.Read(c.i+uint16(i)))
-				}
-			} else {
-				for i := range regCount {
-					c.writeReg(hi0-i, c.mem.Read(c.i+uint16(i)))
-				}
-			}
-		default:
-			c.debugInfo.inst = "SE V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1)
-
-			if c.readReg(hi0) == c.readReg(lo1) {
-				c.pc += 2
-				if c.decodeInstruction() == 0xF000 {
-					c.pc += 2
-				}
-			}
+
+		c.debugInfo.inst = inst
+		c.pc = nnn
+
+		return nil
+	case OP_SE_VX_NN:
+		c.skipIf(c.readReg(x) == nn)
+	case OP_SNE_VX_NN:
+		c.skipIf(c.readReg(x) != nn)
+	case OP_SFM:
+		regCount := byte(math.Abs(float64(x)-float64(y))) + 1
+
+		if err := c.checkMemory(int(regCount)); err != nil {
+			return err
 		}
-	case 0x6:
-		c.debugInfo.inst = "LD V" + lib.FormatHex(hi0, 1) + ", " + lib.FormatHex(lo, 2)
-		c.writeReg(hi0, lo)
-	case 0x7:
-		c.debugInfo.inst = "ADD V" + lib.FormatHex(hi0, 1) + ", " + lib.FormatHex(lo, 2)
-		c.writeReg(hi0, c.readReg(hi0)+lo)
-	case 0x8:
-		switch lo0 {
-		case 0x0:
-			c.debugInfo.inst = "LD V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1)
-			c.writeReg(hi0, c.readReg(lo1))
-		case 0x1:
-			c.debugInfo.inst = "OR V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1)
-			c.writeReg(hi0, c.readReg(hi0)|c.readReg(lo1))
-
-			if c.compatibilityMode == lib.CM_CHIP8 {
-				c.writeReg(0xF, 0)
-			}
-		case 0x2:
-			c.debugInfo.inst = "AND V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1)
-			c.writeReg(hi0, c.readReg(hi0)&c.readReg(lo1))
 
-			if c.compatibilityMode == lib.CM_CHIP8 {
-				c.writeReg(0xF, 0)
-			}
-		case 0x3:
-			c.debugInfo.inst = "XOR V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1)
-			c.writeReg(hi0, c.readReg(hi0)^c.readReg(lo1))
+		c.updateCompatibilityMode(lib.CM_XOCHIP)
 
-			if c.compatibilityMode == lib.CM_CHIP8 {
-				c.writeReg(0xF, 0)
+		if x < y {
+			for i := range regCount {
+				c.mem.Write(c.i+uint16(i), c.readReg(x+i))
 			}
-		case 0x4:
-			c.debugInfo.inst = "ADD V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1)
-			a, b := c.readReg(hi0), c.readReg(lo1)
-			v := uint16(a) + uint16(b)
+		} else {
+			for i := range regCount {
+				c.mem.Write(c.i+uint16(i), c.readReg(x-i))
+			}
+		}
+	case OP_LFM:
+		regCount := byte(math.Abs(float64(x)-float64(y))) + 1
 
-			c.writeReg(hi0, byte(v))
+		if err := c.checkMemory(int(regCount)); err != nil {
+			return err
+		}
 
-			if v > 0xFF {
-				c.writeReg(0xF, 1)
-			} else {
-				c.writeReg(0xF, 0)
+		c.updateCompatibilityMode(lib.CM_XOCHIP)
+
+		if x < y {
+			for i := range regCount {
+				c.writeReg(x+i, c.mem.Read(c.i+uint16(i)))
+			}
+		} else {
+			for i := range regCount {
+				c.writeReg(x-i, c.mem.Read(c.i+uint16(i)))
 			}
-		case 0x5:
-			c.debugInfo.inst = "SUB V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1) + ""
-			a, b := c.readReg(hi0), c.readReg(lo1)
-			v := a - b
+		}
+	case OP_SE_VX_VY:
+		c.skipIf(c.readReg(x) == c.readReg(y))
+	case OP_LD_VX_NN:
+		c.writeReg(x, nn)
+	case OP_ADD_VX_NN:
+		c.writeReg(x, c.readReg(x)+nn)
+	case OP_LD_VX_VY:
+		c.writeReg(x, c.readReg(y))
+	case OP_OR:
+		c.writeReg(x, c.readReg(x)|c.readReg(y))
+
+		if c.quirks.VFReset {
+			c.writeReg(0xF, 0)
+		}
+	case OP_AND:
+		c.writeReg(x, c.readReg(x)&c.readReg(y))
 
-			c.writeReg(hi0, v)
+		if c.quirks.VFReset {
+			c.writeReg(0xF, 0)
+		}
+	case OP_XOR:
+		c.writeReg(x, c.readReg(x)^c.readReg(y))
 
-			if a >= b {
-				c.writeReg(0xF, 1)
-			} else {
-				c.writeReg(0xF, 0)
-			}
-		case 0x6:
-			c.debugInfo.inst = "SHR V" + lib.FormatHex(hi0, 1) + " {, V" + lib.FormatHex(lo1, 1) + "}"
+		if c.quirks.VFReset {
+			c.writeReg(0xF, 0)
+		}
+	case OP_ADD_VX_VY:
+		a, b := c.readReg(x), c.readReg(y)
+		v := uint16(a) + uint16(b)
 
-			var v byte
+		c.writeReg(x, byte(v))
 
-			switch c.compatibilityMode {
-			case lib.CM_CHIP8, lib.CM_XOCHIP:
-				v = c.readReg(lo1)
-			default:
-				v = c.readReg(hi0)
-			}
+		if v > 0xFF {
+			c.writeReg(0xF, 1)
+		} else {
+			c.writeReg(0xF, 0)
+		}
+	case OP_SUB:
+		a, b := c.readReg(x), c.readReg(y)
+		v := a - b
 
-			c.writeReg(hi0, v>>1)
-			c.writeReg(0xF, lib.Bit(v, 0))
-		case 0x7:
-			c.debugInfo.inst = "SUBN V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1) + ""
-			v := c.readReg(lo1) - c.readReg(hi0)
-			c.writeReg(hi0, v)
-
-			if c.readReg(hi0) > c.readReg(lo1) {
-				c.writeReg(0xF, 0)
-			} else {
-				c.writeReg(0xF, 1)
-			}
-		case 0xE:
-			c.debugInfo.inst = "SHL V" + lib.FormatHex(hi0, 1) + " {, V" + lib.FormatHex(lo1, 1) + "}"
+		c.writeReg(x, v)
 
-			var v byte
+		if a >= b {
+			c.writeReg(0xF, 1)
+		} else {
+			c.writeReg(0xF, 0)
+		}
+	case OP_SHR:
+		v := c.readReg(x)
 
-			switch c.compatibilityMode {
-			case lib.CM_CHIP8, lib.CM_XOCHIP:
-				v = c.readReg(lo1)
-			default:
-				v = c.readReg(hi0)
-			}
+		if c.quirks.ShiftVY {
+			v = c.readReg(y)
+		}
+
+		c.writeReg(x, v>>1)
+		c.writeReg(0xF, lib.Bit(v, 0))
+	case OP_SUBN:
+		v := c.readReg(y) - c.readReg(x)
+		c.writeReg(x, v)
 
-			c.writeReg(hi0, v<<1)
-			c.writeReg(0xF, lib.Bit(v, 7))
-		default:
-			implemented = false
+		if c.readReg(x) > c.readReg(y) {
+			c.writeReg(0xF, 0)
+		} else {
+			c.writeReg(0xF, 1)
 		}
-	case 0x9:
-		c.debugInfo.inst = "SNE V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1) + ""
+	case OP_SHL:
+		v := c.readReg(x)
 
-		if c.readReg(hi0) != c.readReg(lo1) {
-			c.pc += 2
-			if c.decodeInstruction() == 0xF000 {
-				c.pc += 2
-			}
+		if c.quirks.ShiftVY {
+			v = c.readReg(y)
 		}
-	case 0xA:
-		v := inst & ADDR_MASK
-		c.debugInfo.inst = "LD I, " + lib.FormatHex(v, 3)
-		c.i = v
-	case 0xB:
-		v := inst & ADDR_MASK
 
-		switch c.compatibilityMode {
-		case lib.CM_CHIP8, lib.CM_XOCHIP:
-			c.debugInfo.inst = "JP V0, " + lib.FormatHex(v, 3)
-			c.pc = v + uint16(c.readReg(0))
-		default:
-			c.debugInfo.inst = "JP V" + lib.FormatHex(hi0, 1) + ", " + lib.FormatHex(v, 3)
-			c.pc = v + uint16(c.readReg(hi0))
+		c.writeReg(x, v<<1)
+		c.writeReg(0xF, lib.Bit(v, 7))
+	case OP_SNE_VX_VY:
+		c.skipIf(c.readReg(x) != c.readReg(y))
+	case OP_LD_I:
+		c.i = nnn
+	case OP_JP_V0:
+		inst.JumpVX = c.quirks.JumpVX
+		c.debugInfo.inst = inst
+
+		if inst.JumpVX {
+			c.pc = nnn + uint16(c.readReg(x))
+		} else {
+			c.pc = nnn + uint16(c.readReg(0))
 		}
 
-		return
-	case 0xC:
-		c.debugInfo.inst = "RND V" + lib.FormatHex(hi0, 1) + ", byte"
+		return nil
+	case OP_RND:
+		r := byte(c.rand.Uint32())
 
-		r := byte(rand.Intn(0xFF))
-		v := r & lo
-		c.writeReg(hi0, v)
-	case 0xD:
-		c.debugInfo.inst = "DRW V" + lib.FormatHex(hi0, 1) + ", V" + lib.FormatHex(lo1, 1) + ", " + lib.FormatHex(lo0, 1)
-		x := c.readReg(hi0)
-		y := c.readReg(lo1)
+		if c.legacyRand {
+			r = byte(c.rand.IntN(0xFF))
+		}
 
-		spriteLen := lo0
+		c.writeReg(x, r&nn)
+	case OP_DRW:
+		vx := c.readReg(x)
+		vy := c.readReg(y)
 
-		if lo0 == 0 {
-			c.updateCompatibilityMode(lib.CM_SUPERCHIP)
+		spriteLen := n
 
+		if n == 0 {
 			spriteLen = 2 * 16 // 2 col 16 rows
 		}
 
-		if c.ui.SelectedFrameBuffer == ui.SF_BOTH {
+		if c.display.SelectedFrameBuffer() == display.SF_BOTH {
 			spriteLen *= 2
 		}
 
+		if err := c.checkMemory(int(spriteLen)); err != nil {
+			return err
+		}
+
+		if n == 0 {
+			c.updateCompatibilityMode(lib.CM_SUPERCHIP)
+		}
+
 		sprite := make([]byte, spriteLen)
 
 		for i := range sprite {
 			sprite[i] = c.mem.Read(c.i + uint16(i))
 		}
 
-		if c.ui.DrawSprite(x, y, sprite) {
+		if c.display.DrawSprite(vx, vy, sprite) {
 			c.writeReg(0xF, 1)
 		} else {
 			c.writeReg(0xF, 0)
 		}
-	case 0xE:
-		switch lo {
-		case 0x9E:
-			c.debugInfo.inst = "SKP V" + lib.FormatHex(hi0, 1)
 
-			if c.ui.IsKeyPressed(c.readReg(hi0)) {
-				c.pc += 2
-				if c.decodeInstruction() == 0xF000 {
-					c.pc += 2
-				}
-			}
-		case 0xA1:
-			c.debugInfo.inst = "SKNP V" + lib.FormatHex(hi0, 1)
-
-			if !c.ui.IsKeyPressed(c.readReg(hi0)) {
-				c.pc += 2
-				if c.decodeInstruction() == 0xF000 {
-					c.pc += 2
-				}
-			}
-		default:
-			implemented = false
+		c.waitingForVBlank = c.quirks.DisplayWait
+	case OP_SKP:
+		c.skipIf(c.keypad.IsKeyPressed(c.readReg(x)))
+	case OP_SKNP:
+		c.skipIf(!c.keypad.IsKeyPressed(c.readReg(x)))
+	case OP_LD_I_LONG:
+		addr, err := c.fetch(c.pc + 2)
+		if err != nil {
+			return err
 		}
-	case 0xF:
-		switch lo {
-		case 0x00:
-			c.updateCompatibilityMode(lib.CM_XOCHIP)
-			c.pc += 2
-			addr := c.decodeInstruction()
 
-			c.debugInfo.inst = "LD I, " + lib.FormatHex(addr, 4)
+		c.updateCompatibilityMode(lib.CM_XOCHIP)
+		c.pc += 2
+		inst.Addr = addr
 
-			c.i = addr
-		case 0x01:
-			c.debugInfo.inst = "SFB " + lib.FormatHex(hi0, 1)
+		c.i = inst.Addr
+	case OP_SFB:
+		if err := c.display.SelectFrameBuffer(x); err != nil {
+			return err
+		}
 
-			c.updateCompatibilityMode(lib.CM_XOCHIP)
-			c.ui.SelectFrameBuffer(hi0)
-		case 0x02:
-			c.debugInfo.inst = "LDP"
+		c.updateCompatibilityMode(lib.CM_XOCHIP)
+	case OP_LDP:
+		var bytes [16]byte
 
-			var bytes [16]byte
+		if err := c.checkMemory(len(bytes)); err != nil {
+			return err
+		}
 
-			c.updateCompatibilityMode(lib.CM_XOCHIP)
+		c.updateCompatibilityMode(lib.CM_XOCHIP)
 
-			for i := range len(bytes) {
-				bytes[i] = c.mem.Read(c.i + uint16(i))
-			}
+		for i := range len(bytes) {
+			bytes[i] = c.mem.Read(c.i + uint16(i))
+		}
 
-			c.apu.FillPatternBuffer(bytes)
-		case 0x07:
-			c.debugInfo.inst = "LD V" + lib.FormatHex(hi0, 1) + ", DT"
-			c.writeReg(hi0, c.timer.GetDelay())
-		case 0x0A:
-			c.debugInfo.inst = "LD V" + lib.FormatHex(hi0, 1) + ", K"
+		c.sound.FillPatternBuffer(bytes)
+	case OP_LD_VX_DT:
+		c.writeReg(x, c.timer.GetDelay())
+	case OP_LD_VX_K:
+		c.debugInfo.inst = inst
 
-			if c.pressedKey == nil {
-				c.pressedKey = c.ui.GetPressedKey()
+		if c.pressedKey == nil {
+			c.pressedKey = c.keypad.GetPressedKey()
 
-				return
-			}
+			return nil
+		}
 
-			if c.ui.IsKeyPressed(*c.pressedKey) {
-				return
-			}
+		if c.keypad.IsKeyPressed(*c.pressedKey) {
+			return nil
+		}
 
-			c.writeReg(hi0, *c.pressedKey)
-			c.pressedKey = nil
-		case 0x15:
-			c.debugInfo.inst = "LD DT, V" + lib.FormatHex(hi0, 1)
-			c.timer.SetDelay(c.readReg(hi0))
-		case 0x18:
-			c.debugInfo.inst = "LD ST, V" + lib.FormatHex(hi0, 1)
-			c.timer.SetSound(c.readReg(hi0))
-		case 0x1E:
-			c.debugInfo.inst = "ADD I, V" + lib.FormatHex(hi0, 1)
-			c.i = c.i + uint16(c.readReg(hi0))
-		case 0x29:
-			c.debugInfo.inst = "LD F, V" + lib.FormatHex(hi0, 1)
-			digit := c.readReg(hi0)
-			c.i = uint16(digit * 5)
-		case 0x30:
-			c.debugInfo.inst = "LD HF, V" + lib.FormatHex(hi0, 1)
-			digit := c.readReg(hi0)
-			c.i = uint16(digit*10 + 80)
-		case 0x33:
-			c.debugInfo.inst = "LD B, V" + lib.FormatHex(hi0, 1)
-			v := fmt.Sprintf("%03d", c.readReg(hi0))
-			c.mem.Write(c.i, v[0]-'0')
-			c.mem.Write(c.i+1, v[1]-'0')
-			c.mem.Write(c.i+2, v[2]-'0')
-		case 0x3A:
-			c.debugInfo.inst = "SP, V" + lib.FormatHex(hi0, 1)
-
-			c.updateCompatibilityMode(lib.CM_XOCHIP)
-			c.apu.SetPlaybackRate(c.readReg(hi0))
-		case 0x55:
-			c.debugInfo.inst = "LD " + lib.FormatHex(c.i, 2) + ", V" + lib.FormatHex(hi0, 1)
-
-			i := c.i
-
-			for x := range hi0 + 1 {
-				c.mem.Write(i, c.readReg(x))
-				i++
-			}
+		c.writeReg(x, *c.pressedKey)
+		c.pressedKey = nil
+	case OP_LD_DT_VX:
+		c.timer.SetDelay(c.readReg(x))
+	case OP_LD_ST_VX:
+		c.timer.SetSound(c.readReg(x))
+	case OP_ADD_I_VX:
+		c.i = c.i + uint16(c.readReg(x))
+	case OP_LD_F_VX:
+		digit := c.readReg(x)
+		c.i = uint16(digit * 5)
+	case OP_LD_HF_VX:
+		digit := c.readReg(x)
+		c.i = uint16(digit*10 + 80)
+	case OP_LD_B_VX:
+		if err := c.checkMemory(3); err != nil {
+			return err
+		}
 
-			if c.compatibilityMode == lib.CM_CHIP8 {
-				c.i = i
-			}
-		case 0x65:
-			c.debugInfo.inst = "LD V" + lib.FormatHex(hi0, 1) + ", " + lib.FormatHex(c.i, 2)
+		v := fmt.Sprintf("%03d", c.readReg(x))
+		c.mem.Write(c.i, v[0]-'0')
+		c.mem.Write(c.i+1, v[1]-'0')
+		c.mem.Write(c.i+2, v[2]-'0')
+	case OP_PITCH:
+		c.updateCompatibilityMode(lib.CM_XOCHIP)
+		c.sound.SetPlaybackRate(c.readReg(x))
+	case OP_LD_MEM_VX:
+		if err := c.checkMemory(int(x) + 1); err != nil {
+			return err
+		}
 
-			i := c.i
+		i := c.i
 
-			for x := range hi0 + 1 {
-				c.writeReg(x, c.mem.Read(i))
-				i++
-			}
+		for r := range x + 1 {
+			c.mem.Write(i, c.readReg(r))
+			i++
+		}
 
-			if c.compatibilityMode == lib.CM_CHIP8 {
-				c.i = i
-			}
-		case 0x75:
-			c.debugInfo.inst = "SF V" + lib.FormatHex(hi0, 1)
-			c.updateCompatibilityMode(lib.CM_SUPERCHIP)
+		if c.quirks.MemoryIncrementI {
+			c.i = i
+		}
+	case OP_LD_VX_MEM:
+		if err := c.checkMemory(int(x) + 1); err != nil {
+			return err
+		}
 
-			homeDir, err := os.UserHomeDir()
-			if err != nil {
-				log.Println("failed to save flags: %w", err)
+		i := c.i
 
-				break
-			}
+		for r := range x + 1 {
+			c.writeReg(r, c.mem.Read(i))
+			i++
+		}
 
-			flagDir := filepath.Join(homeDir, ".local/share/chip8-go")
+		if c.quirks.MemoryIncrementI {
+			c.i = i
+		}
+	case OP_SF:
+		c.updateCompatibilityMode(lib.CM_SUPERCHIP)
 
-			err = os.MkdirAll(flagDir, 0755)
-			if err != nil {
-				log.Println("failed to save flags: %w", err)
+		flagDir, err := lib.DataDir()
+		if err != nil {
+			log.Println("failed to save flags: %w", err)
 
-				break
-			}
+			break
+		}
 
-			storage := regStorage{Registers: c.reg}
+		storage := regStorage{Registers: c.reg}
 
-			data, err := json.Marshal(storage)
-			if err != nil {
-				log.Println("failed to save flags: %w", err)
+		data, err := json.Marshal(storage)
+		if err != nil {
+			log.Println("failed to save flags: %w", err)
 
-				break
-			}
+			break
+		}
 
-			romFileBaseName, _ := strings.CutSuffix(filepath.Base(c.romFileName), ".ch8")
-			fileName := romFileBaseName + "-flags.json"
+		romFileBaseName, _ := strings.CutSuffix(filepath.Base(c.romFileName), ".ch8")
+		fileName := romFileBaseName + "-flags.json"
 
-			err = os.WriteFile(filepath.Join(flagDir, fileName), data, 0644)
-			if err != nil {
-				log.Println("failed to save flags: %w", err)
+		err = os.WriteFile(filepath.Join(flagDir, fileName), data, 0644)
+		if err != nil {
+			log.Println("failed to save flags: %w", err)
 
-				break
-			}
-		case 0x85:
-			c.debugInfo.inst = "LF V" + lib.FormatHex(hi0, 1)
-			c.updateCompatibilityMode(lib.CM_SUPERCHIP)
+			break
+		}
+	case OP_LF:
+		c.updateCompatibilityMode(lib.CM_SUPERCHIP)
 
-			homeDir, err := os.UserHomeDir()
-			if err != nil {
-				log.Println("failed to load flags: %w", err)
+		flagDir, err := lib.DataDir()
+		if err != nil {
+			log.Println("failed to load flags: %w", err)
 
-				break
-			}
+			break
+		}
 
-			flagDir := filepath.Join(homeDir, ".local/share/chip8-go")
+		romFileBaseName, _ := strings.CutSuffix(filepath.Base(c.romFileName), ".ch8")
+		fileName := romFileBaseName + "-flags.json"
 
-			err = os.MkdirAll(flagDir, 0755)
+		data, err := os.ReadFile(filepath.Join(flagDir, fileName))
+		if err != nil {
+			_, err = os.Create(filepath.Join(flagDir, fileName))
 			if err != nil {
-				log.Println("failed to save flags: %w", err)
+				log.Println("failed to create flags file: %w", err)
 
 				break
 			}
 
-			romFileBaseName, _ := strings.CutSuffix(filepath.Base(c.romFileName), ".ch8")
-			fileName := romFileBaseName + "-flags.json"
+			log.Println("failed to load flags: %w", err)
 
-			data, err := os.ReadFile(filepath.Join(flagDir, fileName))
-			if err != nil {
-				_, err = os.Create(filepath.Join(flagDir, fileName))
-				if err != nil {
-					log.Println("failed to create flags file: %w", err)
+			break
+		}
 
-					break
-				}
+		var storage regStorage
 
+		if len(data) > 0 {
+			err = json.Unmarshal(data, &storage)
+			if err != nil {
 				log.Println("failed to load flags: %w", err)
 
 				break
 			}
 
-			var storage regStorage
+			copy(c.reg[:], storage.Registers[:])
+		}
+	}
 
-			if len(data) > 0 {
-				err = json.Unmarshal(data, &storage)
-				if err != nil {
-					log.Println("failed to load flags: %w", err)
+	c.debugInfo.inst = inst
+	c.pc += 2
 
-					break
-				}
+	return nil
+}
 
-				copy(c.reg[:], storage.Registers[:])
-			}
-		default:
-			implemented = false
-		}
-	default:
-		implemented = false
+// skipIf skips the next instruction, which may be a 4 byte long load, when
+// the condition holds.
+func (c *CPU) skipIf(condition bool) {
+	if !condition {
+		return
 	}
 
 	c.pc += 2
 
-	lib.Assert(implemented, fmt.Errorf("unimplemented instruction: %04X", inst))
+	// Out of bounds, the fault is raised when executing the next instruction
+	word, _ := c.decodeInstruction()
+	c.pc += Disassemble(word, c.compatibilityMode).Size - 2
 }
@@ -0,0 +1,233 @@
+package cpu_test
+
+import (
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/chip8/components/frontend"
+	"github.com/cterence/chip8-go/internal/chip8/components/keypad"
+	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+	"github.com/cterence/chip8-go/internal/chip8/components/timer"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCPU(program []byte, options ...cpu.Option) (*cpu.CPU, *display.Display, *keypad.Keypad) {
+	mem := memory.New()
+	d := display.New()
+	k := keypad.New()
+	t := timer.New(frontend.NullSound{})
+	c := cpu.New(mem, d, k, t, frontend.NullSound{}, append([]cpu.Option{cpu.WithCompatibilityMode(lib.CM_CHIP8)}, options...)...)
+	c.SetCurrentIPF = func(int) {}
+
+	mem.Init()
+	d.Init()
+	k.Init()
+	t.Init()
+	c.Init()
+
+	for i, b := range program {
+		mem.Poke(memory.PROGRAM_RAM_START+uint16(i), b)
+	}
+
+	return c, d, k
+}
+
+func TestDraw(t *testing.T) {
+	c, d, _ := newCPU([]byte{
+		0x60, 0x01, // LD V0, 01
+		0xF0, 0x29, // LD F, V0
+		0xD1, 0x15, // DRW V1, V1, 5
+		0xD1, 0x15, // DRW V1, V1, 5
+	})
+
+	for range 3 {
+		c.Tick()
+	}
+
+	// Top row of the font sprite of 1 is 20, in low resolution
+	fb := d.FrameBuffers()
+	assert.Equal(t, byte(1), fb[0][4][0])
+	assert.Equal(t, byte(1), fb[0][5][1])
+	assert.Equal(t, byte(0), fb[0][6][0])
+	assert.Equal(t, byte(0), c.Register(0xF))
+
+	c.VBlank()
+	c.Tick()
+
+	assert.Equal(t, [2][display.WIDTH][display.HEIGHT]byte{}, d.FrameBuffers())
+	assert.Equal(t, byte(1), c.Register(0xF))
+}
+
+func TestQuirkPresets(t *testing.T) {
+	program := []byte{
+		0x60, 0x01, // LD V0, 01
+		0x61, 0x04, // LD V1, 04
+		0x80, 0x16, // SHR V0, V1
+		0xA3, 0x00, // LD I, 300
+		0xF1, 0x55, // LD [I], V1
+		0x62, 0x3F, // LD V2, 3F
+		0x63, 0x00, // LD V3, 00
+		0xF3, 0x29, // LD F, V3
+		0xD2, 0x35, // DRW V2, V3, 5
+	}
+
+	tests := []struct {
+		mode lib.CompatibilityMode
+		// V0 after the shift, 2 when VY is shifted
+		shifted byte
+		// I after FX55
+		i uint16
+		// Whether the sprite drawn on the last column wraps to the first one
+		wraps bool
+	}{
+		{mode: lib.CM_CHIP8, shifted: 2, i: 0x302},
+		{mode: lib.CM_SUPERCHIP, shifted: 0, i: 0x300},
+		{mode: lib.CM_XOCHIP, shifted: 2, i: 0x302, wraps: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			c, d, _ := newCPU(program, cpu.WithCompatibilityMode(tt.mode))
+
+			for range 5 {
+				assert.NoError(t, c.Tick())
+			}
+
+			assert.Equal(t, tt.shifted, c.Register(0))
+			assert.Equal(t, tt.i, c.I())
+
+			for range 4 {
+				assert.NoError(t, c.Tick())
+			}
+
+			fb := d.FrameBuffers()
+			assert.Equal(t, byte(1), fb[0][display.WIDTH-1][0])
+			assert.Equal(t, tt.wraps, fb[0][0][0] == 1)
+		})
+	}
+}
+
+func TestKeypad(t *testing.T) {
+	c, _, k := newCPU([]byte{
+		0xE0, 0x9E, // SKP V0
+		0x61, 0x01, // LD V1, 01
+		0xF2, 0x0A, // LD V2, K
+	})
+
+	k.SetKey(0x0, true)
+	c.Tick()
+	assert.Equal(t, uint16(0x204), c.PC())
+
+	// LD VX, K waits for a key to be pressed then released
+	c.Tick()
+	c.Tick()
+	assert.Equal(t, uint16(0x204), c.PC())
+
+	k.SetKey(0x0, false)
+	c.Tick()
+	assert.Equal(t, uint16(0x206), c.PC())
+	assert.Equal(t, byte(0x0), c.Register(0x2))
+	assert.Equal(t, byte(0x0), c.Register(0x1))
+}
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		ticks   int
+		err     error
+		pc      uint16
+	}{
+		{name: "unknown opcode", program: []byte{0x00, 0x00}, err: cpu.ErrUnknownOpcode, pc: 0x200},
+		{name: "stack overflow", program: []byte{0x22, 0x00}, ticks: int(cpu.STACK_SIZE), err: cpu.ErrStackOverflow, pc: 0x200},
+		{name: "stack underflow", program: []byte{0x00, 0xEE}, err: cpu.ErrStackUnderflow, pc: 0x200},
+		{name: "invalid framebuffer", program: []byte{0xF4, 0x01}, err: display.ErrInvalidFrameBuffer, pc: 0x200},
+		// I at 0xFFFF, the last address
+		{name: "sprite out of memory", program: []byte{0xF0, 0x00, 0xFF, 0xFF, 0xD0, 0x05}, ticks: 1, err: cpu.ErrMemoryOutOfBounds, pc: 0x204},
+		{name: "store out of memory", program: []byte{0xF0, 0x00, 0xFF, 0xFF, 0xF1, 0x55}, ticks: 1, err: cpu.ErrMemoryOutOfBounds, pc: 0x204},
+		{name: "load out of memory", program: []byte{0xF0, 0x00, 0xFF, 0xFF, 0xF1, 0x65}, ticks: 1, err: cpu.ErrMemoryOutOfBounds, pc: 0x204},
+		{name: "bcd out of memory", program: []byte{0xF0, 0x00, 0xFF, 0xFF, 0xF0, 0x33}, ticks: 1, err: cpu.ErrMemoryOutOfBounds, pc: 0x204},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, _ := newCPU(tt.program)
+
+			for range tt.ticks {
+				assert.NoError(t, c.Tick())
+			}
+
+			sp := c.SP()
+			err := c.Tick()
+
+			var fault *cpu.Fault
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.ErrorAs(t, err, &fault)
+			assert.Equal(t, tt.pc, fault.PC)
+
+			// Nothing was executed
+			assert.Equal(t, tt.pc, c.PC())
+			assert.Equal(t, sp, c.SP())
+			assert.Equal(t, tt.ticks, c.Ticks())
+		})
+	}
+}
+
+func TestPCOutOfBounds(t *testing.T) {
+	c, _, _ := newCPU(nil)
+
+	// The last word would end past the last address
+	c.SetPC(memory.RAM_SIZE - 1)
+
+	assert.ErrorIs(t, c.Tick(), cpu.ErrPCOutOfBounds)
+	assert.Equal(t, uint16(0), c.Opcode())
+
+	c.SetPC(memory.RAM_SIZE - 2)
+
+	assert.ErrorIs(t, c.Tick(), cpu.ErrUnknownOpcode)
+}
+
+func TestLastAddress(t *testing.T) {
+	c, _, _ := newCPU([]byte{
+		0xF0, 0x00, 0xFF, 0xFF, // LD I, FFFF
+		0x60, 0x42, // LD V0, 42
+		0xF0, 0x55, // LD [I], V0
+		0xD0, 0x01, // DRW V0, V0, 1
+	})
+
+	for range 4 {
+		assert.NoError(t, c.Tick())
+	}
+
+	assert.Equal(t, uint16(0x20A), c.PC())
+}
+
+func TestTickAllocs(t *testing.T) {
+	c, _, _ := newCPU([]byte{
+		0x70, 0x01, // ADD V0, 01
+		0x12, 0x00, // JP 200
+	})
+
+	assert.Empty(t, c.LastInstruction())
+	assert.NoError(t, c.Tick())
+	assert.Equal(t, "ADD V0, 01", c.LastInstruction())
+
+	// Mnemonics are only formatted for the debugger
+	assert.Zero(t, testing.AllocsPerRun(100, func() { _ = c.Tick() }))
+}
+
+func TestSkip(t *testing.T) {
+	c, _, _ := newCPU([]byte{
+		0x00, 0x00, // unknown
+		0x60, 0x01, // LD V0, 01
+	})
+
+	assert.Error(t, c.Tick())
+
+	c.Skip()
+	assert.NoError(t, c.Tick())
+	assert.Equal(t, byte(1), c.Register(0))
+}
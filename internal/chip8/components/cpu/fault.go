@@ -0,0 +1,32 @@
+package cpu
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+var (
+	ErrUnknownOpcode     = errors.New("unknown opcode")
+	ErrStackOverflow     = errors.New("stack overflow")
+	ErrStackUnderflow    = errors.New("stack underflow")
+	ErrPCOutOfBounds     = errors.New("program counter out of bounds")
+	ErrMemoryOutOfBounds = errors.New("memory access out of bounds")
+)
+
+// Fault is returned by Tick when the instruction at PC cannot be executed.
+// The machine is left as it was before the instruction, Skip moves past it.
+type Fault struct {
+	Err    error
+	PC     uint16
+	Opcode uint16
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("%v at 0x%s (opcode %s)", f.Err, lib.FormatHex(f.PC, 4), lib.FormatHex(f.Opcode, 4))
+}
+
+func (f *Fault) Unwrap() error {
+	return f.Err
+}
@@ -0,0 +1,285 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+type Op uint8
+
+const (
+	OP_UNKNOWN Op = iota
+	OP_CLS
+	OP_RET
+	OP_SCD
+	OP_SCU
+	OP_SCR
+	OP_SCL
+	OP_EXIT
+	OP_LORES
+	OP_HIRES
+	OP_JP
+	OP_CALL
+	OP_SE_VX_NN
+	OP_SNE_VX_NN
+	OP_SFM
+	OP_LFM
+	OP_SE_VX_VY
+	OP_LD_VX_NN
+	OP_ADD_VX_NN
+	OP_LD_VX_VY
+	OP_OR
+	OP_AND
+	OP_XOR
+	OP_ADD_VX_VY
+	OP_SUB
+	OP_SHR
+	OP_SUBN
+	OP_SHL
+	OP_SNE_VX_VY
+	OP_LD_I
+	OP_JP_V0
+	OP_RND
+	OP_DRW
+	OP_SKP
+	OP_SKNP
+	OP_LD_I_LONG
+	OP_SFB
+	OP_LDP
+	OP_LD_VX_DT
+	OP_LD_VX_K
+	OP_LD_DT_VX
+	OP_LD_ST_VX
+	OP_ADD_I_VX
+	OP_LD_F_VX
+	OP_LD_HF_VX
+	OP_LD_B_VX
+	OP_PITCH
+	OP_LD_MEM_VX
+	OP_LD_VX_MEM
+	OP_SF
+	OP_LF
+)
+
+// opcode describes how an instruction is matched (word&mask == pattern), the
+// first platform that introduced it and its mnemonic. The mnemonic format
+// replaces <x>, <y>, <n>, <nn>, <nnn> and <addr> (the second word of a long
+// instruction) with the operands.
+type opcode struct {
+	op       Op
+	mask     uint16
+	pattern  uint16
+	platform lib.CompatibilityMode
+	format   string
+}
+
+// Order matters: the first matching entry wins.
+var opcodes = []opcode{
+	{OP_CLS, 0xFFFF, 0x00E0, lib.CM_CHIP8, "CLS"},
+	{OP_RET, 0xFFFF, 0x00EE, lib.CM_CHIP8, "RET"},
+	{OP_SCD, 0xFFF0, 0x00C0, lib.CM_SUPERCHIP, "SCD <n>"},
+	{OP_SCU, 0xFFF0, 0x00D0, lib.CM_XOCHIP, "SCU <n>"},
+	{OP_SCR, 0xFFFF, 0x00FB, lib.CM_SUPERCHIP, "SCR 4"},
+	{OP_SCL, 0xFFFF, 0x00FC, lib.CM_SUPERCHIP, "SCL 4"},
+	{OP_EXIT, 0xFFFF, 0x00FD, lib.CM_SUPERCHIP, "EXIT"},
+	{OP_LORES, 0xFFFF, 0x00FE, lib.CM_SUPERCHIP, "LORES"},
+	{OP_HIRES, 0xFFFF, 0x00FF, lib.CM_SUPERCHIP, "HIRES"},
+	{OP_JP, 0xF000, 0x1000, lib.CM_CHIP8, "JP <nnn>"},
+	{OP_CALL, 0xF000, 0x2000, lib.CM_CHIP8, "CALL <nnn>"},
+	{OP_SE_VX_NN, 0xF000, 0x3000, lib.CM_CHIP8, "SE V<x>, <nn>"},
+	{OP_SNE_VX_NN, 0xF000, 0x4000, lib.CM_CHIP8, "SNE V<x>, <nn>"},
+	{OP_SFM, 0xF00F, 0x5002, lib.CM_XOCHIP, "SFM V<x>, V<y>"},
+	{OP_LFM, 0xF00F, 0x5003, lib.CM_XOCHIP, "LFM V<x>, V<y>"},
+	{OP_SE_VX_VY, 0xF000, 0x5000, lib.CM_CHIP8, "SE V<x>, V<y>"},
+	{OP_LD_VX_NN, 0xF000, 0x6000, lib.CM_CHIP8, "LD V<x>, <nn>"},
+	{OP_ADD_VX_NN, 0xF000, 0x7000, lib.CM_CHIP8, "ADD V<x>, <nn>"},
+	{OP_LD_VX_VY, 0xF00F, 0x8000, lib.CM_CHIP8, "LD V<x>, V<y>"},
+	{OP_OR, 0xF00F, 0x8001, lib.CM_CHIP8, "OR V<x>, V<y>"},
+	{OP_AND, 0xF00F, 0x8002, lib.CM_CHIP8, "AND V<x>, V<y>"},
+	{OP_XOR, 0xF00F, 0x8003, lib.CM_CHIP8, "XOR V<x>, V<y>"},
+	{OP_ADD_VX_VY, 0xF00F, 0x8004, lib.CM_CHIP8, "ADD V<x>, V<y>"},
+	{OP_SUB, 0xF00F, 0x8005, lib.CM_CHIP8, "SUB V<x>, V<y>"},
+	{OP_SHR, 0xF00F, 0x8006, lib.CM_CHIP8, "SHR V<x> {, V<y>}"},
+	{OP_SUBN, 0xF00F, 0x8007, lib.CM_CHIP8, "SUBN V<x>, V<y>"},
+	{OP_SHL, 0xF00F, 0x800E, lib.CM_CHIP8, "SHL V<x> {, V<y>}"},
+	{OP_SNE_VX_VY, 0xF000, 0x9000, lib.CM_CHIP8, "SNE V<x>, V<y>"},
+	{OP_LD_I, 0xF000, 0xA000, lib.CM_CHIP8, "LD I, <nnn>"},
+	{OP_JP_V0, 0xF000, 0xB000, lib.CM_CHIP8, "JP V0, <nnn>"},
+	{OP_RND, 0xF000, 0xC000, lib.CM_CHIP8, "RND V<x>, <nn>"},
+	{OP_DRW, 0xF000, 0xD000, lib.CM_CHIP8, "DRW V<x>, V<y>, <n>"},
+	{OP_SKP, 0xF0FF, 0xE09E, lib.CM_CHIP8, "SKP V<x>"},
+	{OP_SKNP, 0xF0FF, 0xE0A1, lib.CM_CHIP8, "SKNP V<x>"},
+	{OP_LD_I_LONG, 0xF0FF, 0xF000, lib.CM_XOCHIP, "LD I, <addr>"},
+	{OP_SFB, 0xF0FF, 0xF001, lib.CM_XOCHIP, "SFB <x>"},
+	{OP_LDP, 0xF0FF, 0xF002, lib.CM_XOCHIP, "LDP"},
+	{OP_LD_VX_DT, 0xF0FF, 0xF007, lib.CM_CHIP8, "LD V<x>, DT"},
+	{OP_LD_VX_K, 0xF0FF, 0xF00A, lib.CM_CHIP8, "LD V<x>, K"},
+	{OP_LD_DT_VX, 0xF0FF, 0xF015, lib.CM_CHIP8, "LD DT, V<x>"},
+	{OP_LD_ST_VX, 0xF0FF, 0xF018, lib.CM_CHIP8, "LD ST, V<x>"},
+	{OP_ADD_I_VX, 0xF0FF, 0xF01E, lib.CM_CHIP8, "ADD I, V<x>"},
+	{OP_LD_F_VX, 0xF0FF, 0xF029, lib.CM_CHIP8, "LD F, V<x>"},
+	{OP_LD_HF_VX, 0xF0FF, 0xF030, lib.CM_SUPERCHIP, "LD HF, V<x>"},
+	{OP_LD_B_VX, 0xF0FF, 0xF033, lib.CM_CHIP8, "LD B, V<x>"},
+	{OP_PITCH, 0xF0FF, 0xF03A, lib.CM_XOCHIP, "PITCH V<x>"},
+	{OP_LD_MEM_VX, 0xF0FF, 0xF055, lib.CM_CHIP8, "LD [I], V<x>"},
+	{OP_LD_VX_MEM, 0xF0FF, 0xF065, lib.CM_CHIP8, "LD V<x>, [I]"},
+	{OP_SF, 0xF0FF, 0xF075, lib.CM_SUPERCHIP, "SF V<x>"},
+	{OP_LF, 0xF0FF, 0xF085, lib.CM_SUPERCHIP, "LF V<x>"},
+}
+
+// Instruction is a decoded opcode.
+type Instruction struct {
+	Op     Op
+	Opcode uint16
+	// Addr is the second word of the 4 byte F000 NNNN long load, which must
+	// be filled in by the caller since Disassemble only sees one word.
+	Addr uint16
+	// Size is the length of the instruction in bytes.
+	Size uint16
+	// Platform is the first compatibility mode that supports the instruction.
+	Platform lib.CompatibilityMode
+	// JumpVX is set when BNNN jumps to NNN + VX (jump quirk).
+	JumpVX bool
+
+	format string
+}
+
+// Disassemble decodes an opcode without executing it. The compatibility mode
+// selects the quirks that change the meaning of an instruction.
+func Disassemble(word uint16, mode lib.CompatibilityMode) Instruction {
+	inst := Instruction{
+		Op:     OP_UNKNOWN,
+		Opcode: word,
+		Size:   2,
+	}
+
+	for _, o := range opcodes {
+		if word&o.mask != o.pattern {
+			continue
+		}
+
+		inst.Op = o.op
+		inst.Platform = o.platform
+		inst.format = o.format
+
+		break
+	}
+
+	switch inst.Op {
+	case OP_LD_I_LONG:
+		inst.Size = 4
+	case OP_JP_V0:
+		inst.JumpVX = lib.DefaultQuirks(mode).JumpVX
+	}
+
+	return inst
+}
+
+// Encode returns the opcode of op with its operands, the inverse of
+// Disassemble. Operands the instruction does not take must be zero.
+func Encode(op Op, x, y byte, imm uint16) (uint16, error) {
+	for _, o := range opcodes {
+		if o.op != op {
+			continue
+		}
+
+		name := strings.Fields(o.format)[0]
+
+		var immMask uint16
+
+		switch {
+		case strings.Contains(o.format, "<nnn>"):
+			immMask = ADDR_MASK
+		case strings.Contains(o.format, "<nn>"):
+			immMask = 0xFF
+		case strings.Contains(o.format, "<n>"):
+			immMask = 0xF
+		}
+
+		if imm&^immMask != 0 {
+			return 0, fmt.Errorf("%s operand 0x%X out of range", name, imm)
+		}
+
+		if x > 0xF || (x != 0 && !strings.Contains(o.format, "<x>")) || y > 0xF || (y != 0 && !strings.Contains(o.format, "<y>")) {
+			return 0, fmt.Errorf("invalid %s register operands", name)
+		}
+
+		word := o.pattern | uint16(x)<<8 | uint16(y)<<4 | imm
+
+		if Disassemble(word, lib.CM_NONE).Op != op {
+			return 0, fmt.Errorf("invalid %s operands", name)
+		}
+
+		return word, nil
+	}
+
+	return 0, fmt.Errorf("unknown op %d", op)
+}
+
+func (i Instruction) X() byte {
+	return byte(i.Opcode>>8) & 0xF
+}
+
+func (i Instruction) Y() byte {
+	return byte(i.Opcode>>4) & 0xF
+}
+
+func (i Instruction) N() byte {
+	return byte(i.Opcode) & 0xF
+}
+
+func (i Instruction) NN() byte {
+	return byte(i.Opcode)
+}
+
+func (i Instruction) NNN() uint16 {
+	return i.Opcode & ADDR_MASK
+}
+
+// Target returns the address referenced by the instruction: the NNN operand
+// or the second word of a long load.
+func (i Instruction) Target() uint16 {
+	if i.Op == OP_LD_I_LONG {
+		return i.Addr
+	}
+
+	return i.NNN()
+}
+
+func (i Instruction) String() string {
+	return i.Format(nil)
+}
+
+// Format returns the mnemonic of the instruction. Addresses are replaced by
+// the name returned by label, if any.
+func (i Instruction) Format(label func(addr uint16) string) string {
+	if i.Op == OP_UNKNOWN {
+		return "DW " + lib.FormatHex(i.Opcode, 4)
+	}
+
+	format := i.format
+	if i.Op == OP_JP_V0 && i.JumpVX {
+		format = "JP V<x>, <nnn>"
+	}
+
+	nnn, addr := lib.FormatHex(i.NNN(), 3), lib.FormatHex(i.Addr, 4)
+
+	if label != nil {
+		if l := label(i.Target()); l != "" {
+			nnn, addr = l, l
+		}
+	}
+
+	r := strings.NewReplacer(
+		"<x>", lib.FormatHex(i.X(), 1),
+		"<y>", lib.FormatHex(i.Y(), 1),
+		"<nnn>", nnn,
+		"<nn>", lib.FormatHex(i.NN(), 2),
+		"<n>", lib.FormatHex(i.N(), 1),
+		"<addr>", addr,
+	)
+
+	return r.Replace(format)
+}
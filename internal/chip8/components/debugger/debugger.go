@@ -1,30 +1,747 @@
 package debugger
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
 	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+	"github.com/cterence/chip8-go/internal/chip8/components/timer"
+	"github.com/cterence/chip8-go/internal/lib"
 )
 
 type Debugger struct {
-	cpu *cpu.CPU
-	mem *memory.Memory
+	cpu   *cpu.CPU
+	mem   *memory.Memory
+	timer *timer.Timer
+
+	in        io.Reader
+	out       io.Writer
+	lines     chan string
+	closed    bool
+	startOnce sync.Once
+
+	env         machineEnv
+	sourceMap   SourceMap
+	breakpoints map[uint16][]*breakpoint
+	watchpoints map[int]string
+	stepsLeft   int
+	// Temporary breakpoint set by next, only hit at the same stack depth
+	nextAddr *uint16
+	nextSP   uint8
+	// Stack depth finish runs until, -1 when not finishing
+	finishSP int
+}
+
+type Option func(*Debugger)
+
+// SourceMap links the addresses of an assembled program to its source.
+type SourceMap interface {
+	// Position returns the file:line location addr was assembled from.
+	Position(addr uint16) (string, bool)
+	// Lookup resolves a label or a file:line location to an address.
+	Lookup(location string) (uint16, bool)
+}
+
+// breakpoint pauses execution, or only logs the machine state when trace is
+// set, before the instruction at its address runs and its condition holds.
+type breakpoint struct {
+	cond    expr
+	condSrc string
+	trace   bool
+	hits    int
+}
+
+type machineEnv struct {
+	*cpu.CPU
+
+	mem   *memory.Memory
+	timer *timer.Timer
+}
+
+func (m machineEnv) Delay() byte {
+	return m.timer.GetDelay()
 }
 
-func New(cpu *cpu.CPU, mem *memory.Memory) *Debugger {
-	d := &Debugger{}
+func (m machineEnv) Sound() byte {
+	return m.timer.GetSound()
+}
+
+// Peek does not trigger watchpoints, conditions are evaluated on every
+// instruction.
+func (m machineEnv) Peek(addr uint16) byte {
+	return m.mem.Peek(addr)
+}
+
+const PROMPT = "(c8db) "
+
+var errUsage = errors.New("wrong arguments")
+
+func New(cpu *cpu.CPU, mem *memory.Memory, t *timer.Timer, options ...Option) *Debugger {
+	d := &Debugger{
+		in:          os.Stdin,
+		out:         os.Stdout,
+		breakpoints: make(map[uint16][]*breakpoint),
+		watchpoints: make(map[int]string),
+		finishSP:    -1,
+	}
+
+	for _, o := range options {
+		o(d)
+	}
 
 	d.cpu = cpu
 	d.mem = mem
+	d.timer = t
+	d.env = machineEnv{CPU: cpu, mem: mem, timer: t}
 
 	return d
 }
 
+func WithInput(in io.Reader) Option {
+	return func(d *Debugger) {
+		d.in = in
+	}
+}
+
+func WithOutput(out io.Writer) Option {
+	return func(d *Debugger) {
+		d.out = out
+	}
+}
+
+// WithSourceMap shows source locations instead of addresses and allows
+// setting breakpoints by file:line or label.
+func WithSourceMap(sourceMap SourceMap) Option {
+	return func(d *Debugger) {
+		d.sourceMap = sourceMap
+	}
+}
+
 func (d *Debugger) DebugLog() string {
 	var debugLog strings.Builder
 
-	debugLog.WriteString("CPU | " + d.cpu.DebugInfo())
+	info := d.cpu.DebugInfo()
+
+	if d.sourceMap != nil {
+		pc := d.cpu.PC()
+
+		if pos, ok := d.sourceMap.Position(pc); ok {
+			info = strings.Replace(info, "PC:"+lib.FormatHex(pc, 4), pos, 1)
+		}
+	}
+
+	debugLog.WriteString("CPU | " + info)
 
 	return debugLog.String()
 }
+
+// Enter is called when the machine gets paused. It shows where execution
+// stopped and prompts for commands.
+func (d *Debugger) Enter() {
+	d.startOnce.Do(d.readLines)
+	d.clearStepping()
+
+	fmt.Fprintln(d.out, d.DebugLog())
+	fmt.Fprint(d.out, PROMPT)
+}
+
+// Leave is called when the machine gets resumed outside of the debugger.
+func (d *Debugger) Leave() {
+	d.clearStepping()
+}
+
+// Poll runs the commands entered since the last call without blocking. It
+// returns true when a command resumed execution.
+func (d *Debugger) Poll() bool {
+	for {
+		select {
+		case line, ok := <-d.lines:
+			if !ok {
+				d.closed = true
+				d.lines = nil
+
+				return false
+			}
+
+			resume, err := d.Exec(line)
+			if err != nil {
+				fmt.Fprintf(d.out, "error: %v\n", err)
+			}
+
+			if resume {
+				return true
+			}
+
+			fmt.Fprint(d.out, PROMPT)
+		default:
+			return false
+		}
+	}
+}
+
+// Closed reports whether the input reached its end: no command can resume
+// execution anymore.
+func (d *Debugger) Closed() bool {
+	return d.closed
+}
+
+// ShouldBreak is called after every instruction while running and reports
+// whether the machine must pause before the next one.
+func (d *Debugger) ShouldBreak() bool {
+	pc, sp := d.cpu.PC(), d.cpu.SP()
+
+	if hits := d.mem.WatchHits(); len(hits) > 0 {
+		for _, hit := range hits {
+			d.reportWatchHit(hit)
+		}
+
+		return true
+	}
+
+	if d.stepsLeft > 0 {
+		d.stepsLeft--
+
+		if d.stepsLeft == 0 {
+			return true
+		}
+	}
+
+	if d.nextAddr != nil && *d.nextAddr == pc && d.nextSP == sp {
+		return true
+	}
+
+	if d.finishSP >= 0 && int(sp) < d.finishSP {
+		return true
+	}
+
+	return d.checkBreakpoints(pc)
+}
+
+func (d *Debugger) checkBreakpoints(pc uint16) bool {
+	stop := false
+
+	for _, bp := range d.breakpoints[pc] {
+		if bp.cond != nil && bp.cond(d.env) == 0 {
+			continue
+		}
+
+		bp.hits++
+
+		if bp.trace {
+			fmt.Fprintf(d.out, "trace at %s (hit %d): %s\n", d.formatLocation(pc), bp.hits, d.DebugLog())
+
+			continue
+		}
+
+		fmt.Fprintf(d.out, "breakpoint at %s (hit %d)\n", d.formatLocation(pc), bp.hits)
+
+		stop = true
+	}
+
+	return stop
+}
+
+// Exec runs a single debugger command and reports whether it resumed
+// execution.
+func (d *Debugger) Exec(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	cmd, args := fields[0], fields[1:]
+
+	switch cmd {
+	case "break", "b":
+		return false, d.breakCmd(args, false)
+	case "trace", "t":
+		return false, d.breakCmd(args, true)
+	case "delete", "d":
+		return false, d.deleteCmd(args)
+	case "step", "s":
+		return d.stepCmd(args)
+	case "next", "n":
+		return d.nextCmd()
+	case "finish", "f":
+		return d.finishCmd()
+	case "continue", "c":
+		return true, nil
+	case "regs", "r":
+		d.regsCmd()
+
+		return false, nil
+	case "mem", "x":
+		return false, d.memCmd(args)
+	case "stack":
+		d.stackCmd()
+
+		return false, nil
+	case "watch", "w":
+		return false, d.watchCmd(args)
+	case "unwatch":
+		return false, d.unwatchCmd(args)
+	case "set":
+		return false, d.setCmd(args)
+	case "help", "h":
+		d.helpCmd()
+
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown command %q, type help for a list of commands", cmd)
+	}
+}
+
+func (d *Debugger) readLines() {
+	d.lines = make(chan string)
+
+	go func() {
+		defer close(d.lines)
+
+		scanner := bufio.NewScanner(d.in)
+		for scanner.Scan() {
+			d.lines <- scanner.Text()
+		}
+	}()
+}
+
+func (d *Debugger) clearStepping() {
+	d.stepsLeft = 0
+	d.nextAddr = nil
+	d.finishSP = -1
+}
+
+func (d *Debugger) breakCmd(args []string, trace bool) error {
+	if len(args) == 0 {
+		d.listBreakpoints()
+
+		return nil
+	}
+
+	addr, err := d.parseLocation(args[0])
+	if err != nil {
+		return err
+	}
+
+	bp := &breakpoint{trace: trace}
+
+	if len(args) > 1 {
+		if args[1] != "if" || len(args) == 2 {
+			return fmt.Errorf("%w, usage: break <addr> [if <condition>]", errUsage)
+		}
+
+		bp.condSrc = strings.Join(args[2:], " ")
+
+		bp.cond, err = compileExpr(bp.condSrc)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.breakpoints[addr] = append(d.breakpoints[addr], bp)
+
+	fmt.Fprintf(d.out, "%s set at %s\n", bp.kind(), d.formatLocation(addr)+bp.condition())
+
+	return nil
+}
+
+func (d *Debugger) listBreakpoints() {
+	addrs := slices.Sorted(maps.Keys(d.breakpoints))
+
+	for _, addr := range addrs {
+		for _, bp := range d.breakpoints[addr] {
+			fmt.Fprintf(d.out, "%s at %s, hit %d times\n", bp.kind(), d.formatLocation(addr)+bp.condition(), bp.hits)
+		}
+	}
+}
+
+func (bp *breakpoint) kind() string {
+	if bp.trace {
+		return "tracepoint"
+	}
+
+	return "breakpoint"
+}
+
+func (bp *breakpoint) condition() string {
+	if bp.cond == nil {
+		return ""
+	}
+
+	return " if " + bp.condSrc
+}
+
+func (d *Debugger) deleteCmd(args []string) error {
+	switch len(args) {
+	case 0:
+		clear(d.breakpoints)
+
+		fmt.Fprintln(d.out, "all breakpoints deleted")
+	case 1:
+		addr, err := d.parseLocation(args[0])
+		if err != nil {
+			return err
+		}
+
+		if len(d.breakpoints[addr]) == 0 {
+			return fmt.Errorf("no breakpoint at %s", d.formatLocation(addr))
+		}
+
+		delete(d.breakpoints, addr)
+	default:
+		return fmt.Errorf("%w, usage: delete [addr]", errUsage)
+	}
+
+	return nil
+}
+
+func (d *Debugger) watchCmd(args []string) error {
+	if len(args) == 0 {
+		ids := slices.Sorted(maps.Keys(d.watchpoints))
+
+		for _, id := range ids {
+			fmt.Fprintf(d.out, "watchpoint #%d: %s\n", id, d.watchpoints[id])
+		}
+
+		return nil
+	}
+
+	usage := fmt.Errorf("%w, usage: watch <read|write|access> <addr>[-<end>] [<op> <value>]", errUsage)
+
+	if len(args) != 2 && len(args) != 4 {
+		return usage
+	}
+
+	var w memory.Watchpoint
+
+	switch args[0] {
+	case "read", "r":
+		w.Kind = memory.AK_READ
+	case "write", "w":
+		w.Kind = memory.AK_WRITE
+	case "access", "rw":
+		w.Kind = memory.AK_ACCESS
+	default:
+		return usage
+	}
+
+	start, end, found := strings.Cut(args[1], "-")
+
+	var err error
+
+	w.Start, err = parseAddr(start)
+	if err != nil {
+		return err
+	}
+
+	w.End = w.Start
+
+	if found {
+		w.End, err = parseAddr(end)
+		if err != nil {
+			return err
+		}
+
+		if w.End < w.Start {
+			return fmt.Errorf("invalid address range %q", args[1])
+		}
+	}
+
+	if len(args) == 4 {
+		compare, err := parseComparison(args[2])
+		if err != nil {
+			return err
+		}
+
+		v, err := strconv.ParseUint(args[3], 0, 8)
+		if err != nil {
+			return fmt.Errorf("invalid value %q", args[3])
+		}
+
+		w.Condition = func(_, new byte) bool { return compare(new, byte(v)) }
+	}
+
+	id := d.mem.AddWatchpoint(w)
+	d.watchpoints[id] = strings.Join(args, " ")
+
+	fmt.Fprintf(d.out, "watchpoint #%d set: %s\n", id, d.watchpoints[id])
+
+	return nil
+}
+
+func (d *Debugger) unwatchCmd(args []string) error {
+	switch len(args) {
+	case 0:
+		for id := range d.watchpoints {
+			d.mem.RemoveWatchpoint(id)
+		}
+
+		clear(d.watchpoints)
+
+		fmt.Fprintln(d.out, "all watchpoints deleted")
+	case 1:
+		id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if err != nil || !d.mem.RemoveWatchpoint(id) {
+			return fmt.Errorf("no watchpoint %s", args[0])
+		}
+
+		delete(d.watchpoints, id)
+	default:
+		return fmt.Errorf("%w, usage: unwatch [id]", errUsage)
+	}
+
+	return nil
+}
+
+func (d *Debugger) reportWatchHit(hit memory.WatchHit) {
+	where := fmt.Sprintf("at %s by %s (%s)", formatAddr(hit.Addr), d.formatLocation(d.cpu.LastPC()), d.cpu.LastInstruction())
+
+	if hit.Kind == memory.AK_READ {
+		fmt.Fprintf(d.out, "watchpoint #%d (%s): read %s: 0x%s\n", hit.ID, d.watchpoints[hit.ID], where, lib.FormatHex(hit.New, 2))
+
+		return
+	}
+
+	fmt.Fprintf(d.out, "watchpoint #%d (%s): write %s: 0x%s -> 0x%s\n", hit.ID, d.watchpoints[hit.ID], where, lib.FormatHex(hit.Old, 2), lib.FormatHex(hit.New, 2))
+}
+
+func (d *Debugger) stepCmd(args []string) (bool, error) {
+	n := 1
+
+	if len(args) > 1 {
+		return false, fmt.Errorf("%w, usage: step [n]", errUsage)
+	}
+
+	if len(args) == 1 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v < 1 {
+			return false, fmt.Errorf("invalid step count %q", args[0])
+		}
+
+		n = v
+	}
+
+	d.stepsLeft = n
+
+	return true, nil
+}
+
+func (d *Debugger) nextCmd() (bool, error) {
+	// Step over CALL by running until it returns to the next instruction
+	if d.cpu.Opcode()>>12 == 0x2 {
+		next := d.cpu.PC() + 2
+		d.nextAddr = &next
+		d.nextSP = d.cpu.SP()
+
+		return true, nil
+	}
+
+	d.stepsLeft = 1
+
+	return true, nil
+}
+
+func (d *Debugger) finishCmd() (bool, error) {
+	if d.cpu.SP() == 0 {
+		return false, errors.New("not in a subroutine")
+	}
+
+	d.finishSP = int(d.cpu.SP())
+
+	return true, nil
+}
+
+func (d *Debugger) regsCmd() {
+	var regs strings.Builder
+
+	for r := range cpu.REGISTER_COUNT {
+		regs.WriteString("V" + lib.FormatHex(r, 1) + ":" + lib.FormatHex(d.cpu.Register(r), 2) + " ")
+
+		if r%8 == 7 {
+			fmt.Fprintln(d.out, strings.TrimSpace(regs.String()))
+			regs.Reset()
+		}
+	}
+
+	fmt.Fprintf(d.out, "PC:%s I:%s SP:%s DT:%s ST:%s TK:%d\n",
+		lib.FormatHex(d.cpu.PC(), 4), lib.FormatHex(d.cpu.I(), 4), lib.FormatHex(d.cpu.SP(), 2),
+		lib.FormatHex(d.timer.GetDelay(), 2), lib.FormatHex(d.timer.GetSound(), 2), d.cpu.Ticks())
+}
+
+func (d *Debugger) memCmd(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%w, usage: mem <addr> <len>", errUsage)
+	}
+
+	addr, err := parseAddr(args[0])
+	if err != nil {
+		return err
+	}
+
+	length, err := strconv.Atoi(args[1])
+	if err != nil || length < 1 {
+		return fmt.Errorf("invalid length %q", args[1])
+	}
+
+	end := min(int(addr)+length, int(memory.RAM_SIZE))
+
+	for line := int(addr); line < end; line += 16 {
+		var dump strings.Builder
+
+		dump.WriteString(lib.FormatHex(uint16(line), 4) + ":")
+
+		for a := line; a < min(line+16, end); a++ {
+			dump.WriteString(" " + lib.FormatHex(d.mem.Peek(uint16(a)), 2))
+		}
+
+		fmt.Fprintln(d.out, dump.String())
+	}
+
+	return nil
+}
+
+func (d *Debugger) stackCmd() {
+	stack := d.cpu.Stack()
+
+	if len(stack) == 0 {
+		fmt.Fprintln(d.out, "stack is empty")
+
+		return
+	}
+
+	// Most recent call first, showing where RET resumes execution
+	for i := len(stack) - 1; i >= 0; i-- {
+		fmt.Fprintf(d.out, "#%d returns to %s (called from %s)\n", len(stack)-1-i, d.formatLocation(stack[i]+2), d.formatLocation(stack[i]))
+	}
+}
+
+func (d *Debugger) setCmd(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w, usage: set <V0-VF|I|PC>=<value>", errUsage)
+	}
+
+	name, value, found := strings.Cut(strings.ToUpper(args[0]), "=")
+	if !found {
+		return fmt.Errorf("%w, usage: set <V0-VF|I|PC>=<value>", errUsage)
+	}
+
+	v, err := strconv.ParseUint(value, 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid value %q", value)
+	}
+
+	switch {
+	case name == "I":
+		d.cpu.SetI(uint16(v))
+	case name == "PC":
+		d.cpu.SetPC(uint16(v))
+	case len(name) == 2 && name[0] == 'V':
+		reg, err := strconv.ParseUint(name[1:], 16, 4)
+		if err != nil {
+			return fmt.Errorf("unknown register %q", name)
+		}
+
+		if v > 0xFF {
+			return fmt.Errorf("value %q does not fit in a register", value)
+		}
+
+		d.cpu.SetRegister(byte(reg), byte(v))
+	default:
+		return fmt.Errorf("unknown register %q", name)
+	}
+
+	return nil
+}
+
+func (d *Debugger) helpCmd() {
+	fmt.Fprint(d.out, `commands (addresses are hexadecimal, breakpoints of assembled programs can
+also be set by label or file:line):
+  break, b [addr [if <condition>]]
+                       set a breakpoint or list breakpoints
+  trace, t <addr> [if <condition>]
+                       log the machine state at addr without stopping
+  delete, d [addr]     delete the breakpoints at addr or all breakpoints
+  step, s [n]          execute n instructions (default 1)
+  next, n              execute one instruction, stepping over CALL
+  finish, f            run until the current subroutine returns
+  continue, c          resume execution
+  regs, r              print registers and timers
+  mem, x <addr> <len>  dump len bytes of memory
+  stack                print the call stack
+  set <reg>=<value>    set V0-VF, I or PC (value is decimal or 0x hexadecimal)
+  watch, w <read|write|access> <addr>[-<end>] [<op> <value>]
+                       break on memory accesses, optionally only when the
+                       value read or written compares to value (==, !=, <,
+                       <=, >, >=)
+  unwatch [id]         delete a watchpoint or all watchpoints
+
+conditions are C-like expressions over V0-VF, I, PC, SP, DT, ST, TK (tick
+count) and memory bytes ([I+2]), e.g. V3 == 0x10 && DT == 0
+`)
+}
+
+func parseComparison(op string) (func(a, b byte) bool, error) {
+	switch op {
+	case "==":
+		return func(a, b byte) bool { return a == b }, nil
+	case "!=":
+		return func(a, b byte) bool { return a != b }, nil
+	case "<":
+		return func(a, b byte) bool { return a < b }, nil
+	case "<=":
+		return func(a, b byte) bool { return a <= b }, nil
+	case ">":
+		return func(a, b byte) bool { return a > b }, nil
+	case ">=":
+		return func(a, b byte) bool { return a >= b }, nil
+	default:
+		return nil, fmt.Errorf("unknown comparison operator %q", op)
+	}
+}
+
+func parseAddr(s string) (uint16, error) {
+	addr, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", s)
+	}
+
+	return uint16(addr), nil
+}
+
+func formatAddr(addr uint16) string {
+	return "0x" + lib.FormatHex(addr, 3)
+}
+
+// parseLocation resolves a label or file:line location when a source map is
+// available, and an address otherwise.
+func (d *Debugger) parseLocation(s string) (uint16, error) {
+	if d.sourceMap != nil {
+		if addr, ok := d.sourceMap.Lookup(s); ok {
+			return addr, nil
+		}
+
+		if strings.Contains(s, ":") {
+			return 0, fmt.Errorf("no code at %s", s)
+		}
+	}
+
+	return parseAddr(s)
+}
+
+func (d *Debugger) formatLocation(addr uint16) string {
+	if d.sourceMap != nil {
+		if pos, ok := d.sourceMap.Position(addr); ok {
+			return formatAddr(addr) + " (" + pos + ")"
+		}
+	}
+
+	return formatAddr(addr)
+}
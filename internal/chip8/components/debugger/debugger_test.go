@@ -0,0 +1,136 @@
+package debugger_test
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/debugger"
+	"github.com/cterence/chip8-go/internal/hardware"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+var callROM = []byte{
+	0x60, 0x05, // 200 LD V0, 05
+	0x22, 0x0A, // 202 CALL 20A
+	0x71, 0x01, // 204 ADD V1, 01
+	0x12, 0x04, // 206 JP 204
+	0x00, 0x00, // 208
+	0xA3, 0x00, // 20A LD I, 300
+	0xF1, 0x55, // 20C LD [I], V1
+	0x00, 0xEE, // 20E RET
+}
+
+// session runs rom under the debugger like the frontend does, with the
+// commands of script, and returns the debugger output once the script ends.
+func session(t *testing.T, rom []byte, script ...string) string {
+	t.Helper()
+
+	m := hardware.New(hardware.WithCompatibilityMode(lib.CM_CHIP8))
+	assert.NoError(t, m.LoadROM(rom))
+
+	hw := m.Components()
+
+	var out bytes.Buffer
+
+	d := debugger.New(hw.CPU, hw.Memory, hw.Timer,
+		debugger.WithInput(strings.NewReader(strings.Join(script, "\n"))),
+		debugger.WithOutput(&out),
+	)
+
+	d.Enter()
+
+	for {
+		// Commands are read in the background
+		for !d.Poll() {
+			if d.Closed() {
+				return out.String()
+			}
+
+			runtime.Gosched()
+		}
+
+		for ticks := 0; ; ticks++ {
+			if !assert.Less(t, ticks, 1000, "no break") {
+				return out.String()
+			}
+
+			assert.NoError(t, m.Step())
+
+			if d.ShouldBreak() {
+				break
+			}
+		}
+
+		d.Enter()
+	}
+}
+
+// run runs the commands of a session and checks that the output of each
+// contains want, an empty want meaning no output.
+func run(t *testing.T, rom []byte, commands []struct{ cmd, want string }) {
+	t.Helper()
+
+	script := make([]string, len(commands))
+	for i, c := range commands {
+		script[i] = c.cmd
+	}
+
+	// The output of each command ends with the next prompt, commands are only
+	// echoed by terminals
+	outputs := strings.Split(session(t, rom, script...), debugger.PROMPT)[1:]
+
+	if !assert.Len(t, outputs, len(commands)+1) {
+		return
+	}
+
+	for i, c := range commands {
+		if c.want == "" {
+			assert.Empty(t, outputs[i], c.cmd)
+		} else {
+			assert.Contains(t, outputs[i], c.want, c.cmd)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	run(t, callROM, []struct{ cmd, want string }{
+		{"watch write 301", "watchpoint #1 set: write 301\n"},
+		{"watch write 300-301", "watchpoint #2 set: write 300-301\n"},
+		{"break 206", "breakpoint set at 0x206\n"},
+		{"step", "PC:0202 SP:00"},
+		// Stopped in the subroutine by the watchpoints, by address
+		{"next", "watchpoint #2 (write 300-301): write at 0x300 by 0x20C (LD [I], V1): 0x00 -> 0x05\n" +
+			"watchpoint #1 (write 301): write at 0x301 by 0x20C (LD [I], V1): 0x00 -> 0x00\n" +
+			"watchpoint #2 (write 300-301): write at 0x301 by 0x20C (LD [I], V1): 0x00 -> 0x00\n" +
+			"CPU | OP: LD [I], V1"},
+		{"stack", "#0 returns to 0x204 (called from 0x202)\n"},
+		{"mem 300 2", "0300: 05 00\n"},
+		{"unwatch", "all watchpoints deleted\n"},
+		{"finish", "PC:0204 SP:00"},
+		{"set V1=0x40", ""},
+		{"delete 206", ""},
+		{"break 206 if V1 == 0x42", "breakpoint set at 0x206 if V1 == 0x42\n"},
+		// Not stopped on the first loop, with V1 at 0x41
+		{"continue", "breakpoint at 0x206 (hit 1)\nCPU | OP: ADD V1, 01    TK: 8 "},
+		{"regs", "V0:05 V1:42 V2:00 V3:00 V4:00 V5:00 V6:00 V7:00\nV8:00 V9:00 VA:00 VB:00 VC:00 VD:00 VE:00 VF:00\nPC:0206 I:0302 SP:00 DT:00 ST:00 TK:8\n"},
+		{"break", "breakpoint at 0x206 if V1 == 0x42, hit 1 times\n"},
+	})
+}
+
+func TestSessionNext(t *testing.T) {
+	run(t, callROM, []struct{ cmd, want string }{
+		{"break 206", "breakpoint set at 0x206\n"},
+		{"next", "PC:0202 SP:00"},
+		// Over the subroutine
+		{"next", "PC:0204 SP:00"},
+		{"stack", "stack is empty\n"},
+		{"continue", "breakpoint at 0x206 (hit 1)\n"},
+		{"delete", "all breakpoints deleted\n"},
+		{"step 4", "PC:0206 SP:00"},
+		{"finish", "error: not in a subroutine\n"},
+		{"regs", "V0:05 V1:03 "},
+	})
+}
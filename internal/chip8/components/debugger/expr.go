@@ -0,0 +1,339 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+)
+
+// env gives expressions access to the machine state.
+type env interface {
+	Register(reg byte) byte
+	PC() uint16
+	I() uint16
+	SP() uint8
+	Delay() byte
+	Sound() byte
+	Ticks() int
+	Peek(addr uint16) byte
+}
+
+// expr is a compiled condition expression, for example
+// "V3 == 0x10 && DT == 0" or "[I+2] > 5". Booleans evaluate to 0 or 1.
+type expr func(e env) int
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+var binaryOperators = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func compileExpr(src string) (expr, error) {
+	tokens, err := tokenizeExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	p := &exprParser{tokens: tokens}
+
+	e, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in expression", p.tokens[p.pos])
+	}
+
+	return e, nil
+}
+
+func tokenizeExpr(src string) ([]string, error) {
+	var tokens []string
+
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+
+			tokens = append(tokens, src[i:j])
+			i = j
+		default:
+			if i+1 < len(src) {
+				switch op := src[i : i+2]; op {
+				case "||", "&&", "==", "!=", "<=", ">=", "<<", ">>":
+					tokens = append(tokens, op)
+					i += 2
+
+					continue
+				}
+			}
+
+			if !strings.ContainsRune("()[]+-*/%&|^!~<>", c) {
+				return nil, fmt.Errorf("unexpected character %q in expression", c)
+			}
+
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+
+	return tokens, nil
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+
+	return ""
+}
+
+func (p *exprParser) expect(token string) error {
+	if p.peek() != token {
+		return fmt.Errorf("expected %q in expression", token)
+	}
+
+	p.pos++
+
+	return nil
+}
+
+func (p *exprParser) binary(level int) (expr, error) {
+	if level == len(binaryOperators) {
+		return p.unary()
+	}
+
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if !isOperatorOfLevel(op, level) {
+			return left, nil
+		}
+
+		p.pos++
+
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		left = binaryExpr(op, left, right)
+	}
+}
+
+func isOperatorOfLevel(op string, level int) bool {
+	for _, o := range binaryOperators[level] {
+		if o == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+func binaryExpr(op string, l, r expr) expr {
+	switch op {
+	case "||":
+		return func(e env) int { return boolInt(l(e) != 0 || r(e) != 0) }
+	case "&&":
+		return func(e env) int { return boolInt(l(e) != 0 && r(e) != 0) }
+	case "|":
+		return func(e env) int { return l(e) | r(e) }
+	case "^":
+		return func(e env) int { return l(e) ^ r(e) }
+	case "&":
+		return func(e env) int { return l(e) & r(e) }
+	case "==":
+		return func(e env) int { return boolInt(l(e) == r(e)) }
+	case "!=":
+		return func(e env) int { return boolInt(l(e) != r(e)) }
+	case "<":
+		return func(e env) int { return boolInt(l(e) < r(e)) }
+	case "<=":
+		return func(e env) int { return boolInt(l(e) <= r(e)) }
+	case ">":
+		return func(e env) int { return boolInt(l(e) > r(e)) }
+	case ">=":
+		return func(e env) int { return boolInt(l(e) >= r(e)) }
+	case "<<":
+		return func(e env) int { return l(e) << (r(e) & 0x3F) }
+	case ">>":
+		return func(e env) int { return l(e) >> (r(e) & 0x3F) }
+	case "+":
+		return func(e env) int { return l(e) + r(e) }
+	case "-":
+		return func(e env) int { return l(e) - r(e) }
+	case "*":
+		return func(e env) int { return l(e) * r(e) }
+	case "/":
+		return func(e env) int {
+			if d := r(e); d != 0 {
+				return l(e) / d
+			}
+
+			return 0
+		}
+	default: // "%"
+		return func(e env) int {
+			if d := r(e); d != 0 {
+				return l(e) % d
+			}
+
+			return 0
+		}
+	}
+}
+
+func (p *exprParser) unary() (expr, error) {
+	switch op := p.peek(); op {
+	case "!", "-", "~":
+		p.pos++
+
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "!":
+			return func(e env) int { return boolInt(operand(e) == 0) }, nil
+		case "-":
+			return func(e env) int { return -operand(e) }, nil
+		default:
+			return func(e env) int { return ^operand(e) }, nil
+		}
+	}
+
+	return p.primary()
+}
+
+func (p *exprParser) primary() (expr, error) {
+	token := p.peek()
+	if token == "" {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	p.pos++
+
+	switch token {
+	case "(":
+		inner, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+
+		return inner, p.expect(")")
+	case "[":
+		start := p.pos
+
+		addr, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.constant(start) {
+			if a := addr(nil); a < 0 || a >= int(memory.RAM_SIZE) {
+				return nil, fmt.Errorf("address %d out of memory in expression", a)
+			}
+		}
+
+		return func(e env) int { return int(e.Peek(wrapAddr(addr(e)))) }, p.expect("]")
+	}
+
+	if unicode.IsDigit(rune(token[0])) {
+		v, err := strconv.ParseInt(token, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q in expression", token)
+		}
+
+		return func(env) int { return int(v) }, nil
+	}
+
+	return identExpr(token)
+}
+
+// constant reports whether the tokens parsed since start read no machine
+// state, so that the expression they form can be evaluated without env.
+func (p *exprParser) constant(start int) bool {
+	for _, token := range p.tokens[start:p.pos] {
+		if token == "[" || unicode.IsLetter(rune(token[0])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// wrapAddr wraps a computed address around memory, e.g. [I-1] with I at 0.
+func wrapAddr(a int) uint16 {
+	a %= int(memory.RAM_SIZE)
+	if a < 0 {
+		a += int(memory.RAM_SIZE)
+	}
+
+	return uint16(a)
+}
+
+func identExpr(name string) (expr, error) {
+	switch strings.ToUpper(name) {
+	case "PC":
+		return func(e env) int { return int(e.PC()) }, nil
+	case "I":
+		return func(e env) int { return int(e.I()) }, nil
+	case "SP":
+		return func(e env) int { return int(e.SP()) }, nil
+	case "DT":
+		return func(e env) int { return int(e.Delay()) }, nil
+	case "ST":
+		return func(e env) int { return int(e.Sound()) }, nil
+	case "TK", "TICKS":
+		return func(e env) int { return e.Ticks() }, nil
+	}
+
+	if len(name) == 2 && (name[0] == 'V' || name[0] == 'v') {
+		if reg, err := strconv.ParseUint(name[1:], 16, 4); err == nil {
+			return func(e env) int { return int(e.Register(byte(reg))) }, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown identifier %q in expression", name)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
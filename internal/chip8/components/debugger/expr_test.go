@@ -0,0 +1,70 @@
+package debugger
+
+import (
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeEnv struct {
+	registers [16]byte
+	i         uint16
+	mem       map[uint16]byte
+}
+
+func (f fakeEnv) Register(reg byte) byte { return f.registers[reg] }
+func (f fakeEnv) PC() uint16             { return 0x200 }
+func (f fakeEnv) I() uint16              { return f.i }
+func (f fakeEnv) SP() uint8              { return 0 }
+func (f fakeEnv) Delay() byte            { return 0 }
+func (f fakeEnv) Sound() byte            { return 3 }
+func (f fakeEnv) Ticks() int             { return 42 }
+func (f fakeEnv) Peek(addr uint16) byte  { return f.mem[addr] }
+
+func TestExpr(t *testing.T) {
+	e := fakeEnv{i: 0x300, mem: map[uint16]byte{0x302: 7, 0x2FF: 9}}
+	e.registers[0x3] = 0x10
+
+	for src, expected := range map[string]int{
+		"V3 == 0x10 && DT == 0": 1,
+		"v3 != 16 || ST > 3":    0,
+		"[I+2]":                 7,
+		"[I-1]":                 9,
+		"[I+0xFFFF]":            9,
+		"[0x302] * 2 + 1":       15,
+		"1 + 2 * 3":             7,
+		"(1 + 2) * 3":           9,
+		"-TK % 5":               -2,
+		"!PC":                   0,
+		"~0 & 0xFF":             0xFF,
+		"1 << 4 | 1":            17,
+		"10 / 0":                0,
+	} {
+		compiled, err := compileExpr(src)
+		if assert.NoError(t, err, src) {
+			assert.Equal(t, expected, compiled(e), src)
+		}
+	}
+
+	for _, src := range []string{"", "V3 ==", "VG", "(1", "[I", "1 $ 2", "1 2", "[0x10000]", "[-1]", "[0x8000 * 2]"} {
+		_, err := compileExpr(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestExprAddrWraps(t *testing.T) {
+	compiled, err := compileExpr("[I-1]")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 5, compiled(fakeEnv{mem: map[uint16]byte{memory.RAM_SIZE - 1: 5}}))
+
+	mem := memory.New()
+	mem.Write(0, 0x42)
+
+	mem.Write(memory.RAM_SIZE-1, 0x24)
+
+	assert.Equal(t, byte(0x42), machineEnv{mem: mem}.Peek(0))
+	assert.Equal(t, byte(0x24), machineEnv{mem: mem}.Peek(memory.RAM_SIZE-1))
+}
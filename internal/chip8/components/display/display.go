@@ -0,0 +1,305 @@
+package display
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+// Display holds the two framebuffer planes and implements the drawing
+// instructions, independently of how the planes are presented.
+type Display struct {
+	Quirks lib.Quirks
+
+	selectedFrameBuffer SelectedFrameBuffer
+	frameBuffer         [2][WIDTH][HEIGHT]byte
+	palette             [4]uint32
+	res                 int
+}
+
+type State struct {
+	FrameBuffer         [2][WIDTH][HEIGHT]byte
+	Res                 int32
+	SelectedFrameBuffer SelectedFrameBuffer
+}
+
+const (
+	WIDTH  = 128
+	HEIGHT = 64
+)
+
+// Colors of the pixels whose planes are off, first, second and both, as
+// ARGB.
+var DEFAULT_PALETTE = [4]uint32{0xFF0C0F1C, 0xFF87B6FF, 0xFFFFA7C8, 0xFFD0A7FF}
+
+var ErrInvalidFrameBuffer = errors.New("invalid framebuffer")
+
+type ScrollDirection uint8
+
+const (
+	SD_NONE ScrollDirection = iota
+	SD_LEFT
+	SD_RIGHT
+	SD_DOWN
+	SD_UP
+)
+
+type SelectedFrameBuffer uint8
+
+const (
+	SF_NONE SelectedFrameBuffer = iota
+	SF_0
+	SF_1
+	SF_BOTH
+)
+
+func New() *Display {
+	return &Display{
+		palette: DEFAULT_PALETTE,
+	}
+}
+
+func (d *Display) Init() {
+	d.res = 2
+	d.selectedFrameBuffer = SF_BOTH
+	d.Reset()
+	d.selectedFrameBuffer = SF_NONE
+}
+
+// Palette returns the ARGB color of each combination of planes.
+func (d *Display) Palette() [4]uint32 {
+	return d.palette
+}
+
+// SetPalette replaces the colors of the first len(palette) combinations of
+// planes, the others are left unchanged.
+func (d *Display) SetPalette(palette color.Palette) {
+	for i, c := range palette[:min(len(palette), len(d.palette))] {
+		r, g, b, _ := c.RGBA()
+		d.palette[i] = 0xFF<<24 | r>>8<<16 | g>>8<<8 | b>>8
+	}
+}
+
+// Res returns the size of a pixel in the framebuffer: 2 in low resolution, 1
+// in high resolution.
+func (d *Display) Res() int {
+	return d.res
+}
+
+func (d *Display) SetQuirks(quirks lib.Quirks) {
+	d.Quirks = quirks
+}
+
+func (d *Display) ToggleHiRes(enable bool) {
+	if enable {
+		d.res = 1
+	} else {
+		d.res = 2
+	}
+}
+
+func (d *Display) DrawSprite(x, y byte, sprite []byte) bool {
+	fbIDs := d.getFrameBufferIDs()
+
+	if len(fbIDs) == 1 {
+		return d.drawSpriteOnFramebuffer(x, y, sprite, fbIDs[0])
+	}
+
+	collision0 := d.drawSpriteOnFramebuffer(x, y, sprite[:len(sprite)/2], fbIDs[0])
+	collision1 := d.drawSpriteOnFramebuffer(x, y, sprite[len(sprite)/2:], fbIDs[1])
+
+	return collision0 || collision1
+}
+
+func (d *Display) Reset() {
+	for _, i := range d.getFrameBufferIDs() {
+		d.resetFramebuffer(i)
+	}
+}
+
+// SelectFrameBuffer selects the planes drawn to: none, the first, the second
+// or both.
+func (d *Display) SelectFrameBuffer(id byte) error {
+	switch id {
+	case 0:
+		d.selectedFrameBuffer = SF_NONE
+	case 1:
+		d.selectedFrameBuffer = SF_0
+	case 2:
+		d.selectedFrameBuffer = SF_1
+	case 3:
+		d.selectedFrameBuffer = SF_BOTH
+	default:
+		return fmt.Errorf("%w: must be 0, 1, 2 or 3, actual %d", ErrInvalidFrameBuffer, id)
+	}
+
+	return nil
+}
+
+func (d *Display) SelectedFrameBuffer() SelectedFrameBuffer {
+	return d.selectedFrameBuffer
+}
+
+// FrameBuffers returns both display planes, indexed by x then y. Low
+// resolution pixels cover 2x2 pixels.
+func (d *Display) FrameBuffers() [2][WIDTH][HEIGHT]byte {
+	return d.frameBuffer
+}
+
+func (d *Display) SaveState() State {
+	return State{
+		FrameBuffer:         d.frameBuffer,
+		Res:                 int32(d.res),
+		SelectedFrameBuffer: d.selectedFrameBuffer,
+	}
+}
+
+// Validate checks that the state can be loaded without breaking the display
+// invariants.
+func (s State) Validate() error {
+	if s.Res != 1 && s.Res != 2 {
+		return fmt.Errorf("invalid resolution scale: %d", s.Res)
+	}
+
+	if s.SelectedFrameBuffer > SF_BOTH {
+		return fmt.Errorf("%w: %d", ErrInvalidFrameBuffer, s.SelectedFrameBuffer)
+	}
+
+	for p := range s.FrameBuffer {
+		for x := range s.FrameBuffer[p] {
+			for y, v := range s.FrameBuffer[p][x] {
+				if v > 1 {
+					return fmt.Errorf("invalid pixel value %d at %d,%d on plane %d", v, x, y, p)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Display) LoadState(s State) {
+	d.frameBuffer = s.FrameBuffer
+	d.res = int(s.Res)
+	d.selectedFrameBuffer = s.SelectedFrameBuffer
+}
+
+func (d *Display) Scroll(sd ScrollDirection, pixels int) {
+	for _, i := range d.getFrameBufferIDs() {
+		d.scrollFrameBuffer(sd, pixels, i)
+	}
+}
+
+func (d *Display) scrolledCoords(x, y int, sd ScrollDirection, pixels int) (int, int) {
+	newX, newY := x, y
+
+	switch sd {
+	case SD_LEFT:
+		newX -= pixels * d.res
+	case SD_RIGHT:
+		newX += pixels * d.res
+	case SD_UP:
+		newY -= pixels * d.res
+	case SD_DOWN:
+		newY += pixels * d.res
+	}
+
+	return newX, newY
+}
+
+// getFrameBufferIDs returns the framebuffers drawn on. SelectFrameBuffer and
+// LoadState only accept SF_NONE to SF_BOTH, SF_NONE draws on the first one.
+func (d *Display) getFrameBufferIDs() []byte {
+	switch d.selectedFrameBuffer {
+	case SF_1:
+		return []byte{1}
+	case SF_BOTH:
+		return []byte{0, 1}
+	default:
+		return []byte{0}
+	}
+}
+
+func (d *Display) drawSpriteOnFramebuffer(x, y byte, sprite []byte, frameBufferID byte) bool {
+	collision := false
+	startYDraw := (y * byte(d.res)) % HEIGHT
+	spriteWidth := byte(8)
+	spriteHeight := byte(len(sprite))
+
+	if len(sprite) == 32 {
+		spriteWidth = 16
+		spriteHeight = 16
+	}
+
+	for row := range spriteHeight {
+		yDraw := (y + row) * byte(d.res) % HEIGHT
+		prevXDraw := (x * byte(d.res)) % WIDTH
+
+		if d.Quirks.Clip && yDraw < startYDraw {
+			continue
+		}
+
+		spriteRow := uint16(sprite[row])
+
+		if spriteWidth == 16 {
+			spriteRow = (uint16(sprite[row*2]) << uint16(lib.BYTE_SIZE)) | uint16(sprite[row*2+1])
+		}
+
+		for offset := range spriteWidth {
+			xDraw := byte((int(x)+int(offset))*d.res) % WIDTH
+			spritePixel := lib.Bit(spriteRow, uint16(spriteWidth-1-offset))
+			oldPixel := d.frameBuffer[frameBufferID][xDraw][yDraw]
+			newPixel := spritePixel ^ oldPixel
+
+			if d.Quirks.Clip && xDraw < prevXDraw {
+				continue
+			}
+
+			if spritePixel == 1 && oldPixel == 1 {
+				collision = true
+			}
+
+			prevXDraw = xDraw
+
+			// if spritePixel == 1 {
+			// 	fmt.Printf("Drawing pixel at (%d, %d)\n", xDraw, yDraw)
+			// }
+
+			d.frameBuffer[frameBufferID][xDraw][yDraw] = newPixel
+			if d.res == 2 {
+				d.frameBuffer[frameBufferID][xDraw+1][yDraw] = newPixel
+				d.frameBuffer[frameBufferID][xDraw][yDraw+1] = newPixel
+				d.frameBuffer[frameBufferID][xDraw+1][yDraw+1] = newPixel
+			}
+		}
+	}
+
+	return collision
+}
+
+func (d *Display) resetFramebuffer(frameBufferID byte) {
+	for x := range WIDTH {
+		for y := range HEIGHT {
+			d.frameBuffer[frameBufferID][x][y] = 0
+		}
+	}
+}
+
+func (d *Display) scrollFrameBuffer(sd ScrollDirection, pixels int, frameBufferID byte) {
+	var tmpBuf [WIDTH][HEIGHT]byte
+
+	for x := range WIDTH {
+		for y := range HEIGHT {
+			newX, newY := d.scrolledCoords(x, y, sd, pixels)
+			if newX < 0 || newY < 0 || newX >= WIDTH || newY >= HEIGHT {
+				continue
+			}
+
+			tmpBuf[newX][newY] = d.frameBuffer[frameBufferID][x][y]
+		}
+	}
+
+	copy(d.frameBuffer[frameBufferID][:][:], tmpBuf[:][:])
+}
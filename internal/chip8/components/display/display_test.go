@@ -0,0 +1,50 @@
+package display_test
+
+import (
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrawSprite(t *testing.T) {
+	d := display.New()
+	d.Init()
+	d.ToggleHiRes(true)
+
+	assert.False(t, d.DrawSprite(0, 0, []byte{0xC0}))
+	assert.True(t, d.DrawSprite(1, 0, []byte{0x80}))
+
+	fb := d.FrameBuffers()
+	assert.Equal(t, byte(1), fb[0][0][0])
+	assert.Equal(t, byte(0), fb[0][1][0])
+
+	// Sprites wrap around the edges unless clipped
+	d.DrawSprite(display.WIDTH-1, 0, []byte{0xC0})
+	assert.Equal(t, byte(0), d.FrameBuffers()[0][0][0])
+
+	d.SetQuirks(lib.Quirks{Clip: true})
+	d.DrawSprite(display.WIDTH-1, 0, []byte{0xC0})
+	assert.Equal(t, byte(0), d.FrameBuffers()[0][0][0])
+	assert.Equal(t, byte(0), d.FrameBuffers()[0][display.WIDTH-1][0])
+}
+
+func TestScroll(t *testing.T) {
+	d := display.New()
+	d.Init()
+	assert.NoError(t, d.SelectFrameBuffer(3))
+	d.DrawSprite(0, 0, []byte{0x80, 0x80})
+
+	// Low resolution scrolls move by 2 framebuffer pixels per pixel
+	d.Scroll(display.SD_DOWN, 1)
+	d.Scroll(display.SD_RIGHT, 4)
+
+	fb := d.FrameBuffers()
+	assert.Equal(t, byte(0), fb[0][0][0])
+	assert.Equal(t, byte(1), fb[0][8][2])
+	assert.Equal(t, byte(1), fb[1][9][3])
+
+	d.Reset()
+	assert.Equal(t, [2][display.WIDTH][display.HEIGHT]byte{}, d.FrameBuffers())
+}
@@ -0,0 +1,96 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+)
+
+// FrameImage is an image.Image view of the display planes coloured with the
+// palette, which does not need SDL.
+type FrameImage struct {
+	frameBuffer [2][WIDTH][HEIGHT]byte
+	palette     [4]uint32
+	// Framebuffer pixels skipped per image pixel (2 for low resolution at
+	// native size) and image pixels per framebuffer pixel
+	step, scale int
+}
+
+// Image returns a snapshot of the 128x64 framebuffer, scaled by scale.
+func (d *Display) Image(scale int) *FrameImage {
+	return &FrameImage{
+		frameBuffer: d.frameBuffer,
+		palette:     d.palette,
+		step:        1,
+		scale:       max(scale, 1),
+	}
+}
+
+// NativeImage returns a snapshot of the display at its resolution: 64x32 in
+// low resolution and 128x64 in high resolution.
+func (d *Display) NativeImage() *FrameImage {
+	return &FrameImage{
+		frameBuffer: d.frameBuffer,
+		palette:     d.palette,
+		step:        max(d.res, 1),
+		scale:       1,
+	}
+}
+
+func (fi *FrameImage) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (fi *FrameImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, WIDTH/fi.step*fi.scale, HEIGHT/fi.step*fi.scale)
+}
+
+func (fi *FrameImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}).In(fi.Bounds()) {
+		return color.RGBA{}
+	}
+
+	fx, fy := x/fi.scale*fi.step, y/fi.scale*fi.step
+
+	return argbToRGBA(fi.palette[fi.frameBuffer[1][fx][fy]<<1|fi.frameBuffer[0][fx][fy]])
+}
+
+// SavePNG writes the display to a PNG file.
+func (fi *FrameImage) SavePNG(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create screenshot file: %w", err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, fi); err != nil {
+		return fmt.Errorf("failed to encode screenshot: %w", err)
+	}
+
+	return f.Close()
+}
+
+// Paletted returns the 128x64 framebuffer as a paletted image, low resolution
+// pixels being doubled.
+func (d *Display) Paletted() *image.Paletted {
+	palette := make(color.Palette, len(d.palette))
+	for i, argb := range d.palette {
+		palette[i] = argbToRGBA(argb)
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, WIDTH, HEIGHT), palette)
+
+	for y := range HEIGHT {
+		for x := range WIDTH {
+			img.Pix[y*img.Stride+x] = d.frameBuffer[1][x][y]<<1 | d.frameBuffer[0][x][y]
+		}
+	}
+
+	return img
+}
+
+func argbToRGBA(argb uint32) color.RGBA {
+	return color.RGBA{R: byte(argb >> 16), G: byte(argb >> 8), B: byte(argb), A: byte(argb >> 24)}
+}
@@ -0,0 +1,37 @@
+package display_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImage(t *testing.T) {
+	d := display.New()
+	d.Init()
+
+	// Low resolution pixel at (1, 0)
+	d.DrawSprite(1, 0, []byte{0x80})
+
+	off := color.RGBA{R: 0x0C, G: 0x0F, B: 0x1C, A: 0xFF}
+	on := color.RGBA{R: 0x87, G: 0xB6, B: 0xFF, A: 0xFF}
+
+	native := d.NativeImage()
+	assert.Equal(t, image.Rect(0, 0, 64, 32), native.Bounds())
+	assert.Equal(t, off, native.At(0, 0))
+	assert.Equal(t, on, native.At(1, 0))
+	assert.Equal(t, off, native.At(1, 1))
+
+	scaled := d.Image(3)
+	assert.Equal(t, image.Rect(0, 0, 384, 192), scaled.Bounds())
+	assert.Equal(t, off, scaled.At(5, 0))
+	assert.Equal(t, on, scaled.At(6, 5))
+	assert.Equal(t, on, scaled.At(11, 5))
+	assert.Equal(t, off, scaled.At(12, 0))
+
+	d.ToggleHiRes(true)
+	assert.Equal(t, image.Rect(0, 0, 128, 64), d.NativeImage().Bounds())
+}
@@ -0,0 +1,100 @@
+// Package frontend defines what the CPU and the timer need from the display,
+// the keypad and the speaker, so that they can be backed by SDL, another
+// frontend or memory only.
+package frontend
+
+import (
+	"errors"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+type Display interface {
+	Reset()
+	DrawSprite(x, y byte, sprite []byte) bool
+	Scroll(sd display.ScrollDirection, pixels int)
+	ToggleHiRes(enable bool)
+	SelectFrameBuffer(id byte) error
+	SelectedFrameBuffer() display.SelectedFrameBuffer
+	SetQuirks(quirks lib.Quirks)
+}
+
+type Keypad interface {
+	IsKeyPressed(key byte) bool
+	GetPressedKey() *byte
+}
+
+// Sound is called once per timer tick with PlaySound or PlaySilence.
+type Sound interface {
+	PlaySound()
+	PlaySilence()
+	ResetPhase()
+	FillPatternBuffer(pattern [16]byte)
+	SetPlaybackRate(pitch byte)
+}
+
+// NullSound discards all sound.
+type NullSound struct{}
+
+func (NullSound) PlaySound()                 {}
+func (NullSound) PlaySilence()               {}
+func (NullSound) ResetPhase()                {}
+func (NullSound) FillPatternBuffer([16]byte) {}
+func (NullSound) SetPlaybackRate(byte)       {}
+
+// Frontend presents the display and turns user input into keypad changes and
+// Hooks calls.
+type Frontend interface {
+	Init() error
+	Update() error
+	// HandleEvents returns ErrQuit when the user asked to exit
+	HandleEvents() error
+	Destroy()
+}
+
+var ErrQuit = errors.New("quit")
+
+// KeyMap binds the arrow keys and two action keys, named "up", "down",
+// "left", "right", "a" and "b", to keypad keys.
+type KeyMap map[string]byte
+
+// Hooks are the machine controls triggered from a frontend.
+type Hooks struct {
+	ResetChip8        func() error
+	IsChip8Paused     func() bool
+	TogglePauseChip8  func()
+	TickChip8         func() error
+	SaveStateChip8    func(slot int) error
+	LoadStateChip8    func(slot int) error
+	RewindChip8       func(rewind bool)
+	ToggleRecordChip8 func()
+	KeyChangedChip8   func(key byte, pressed bool)
+	TakeOverChip8     func()
+	// Fault that paused the machine, if any
+	Chip8Fault func() error
+	// Title of the running program, if known
+	ProgramTitle string
+}
+
+// Title returns the window title, which tells the program running, whether
+// the machine is paused and on which fault.
+func (h Hooks) Title() string {
+	title := "chip8-go"
+
+	if h.ProgramTitle != "" {
+		title += ": " + h.ProgramTitle
+	}
+
+	if h.IsChip8Paused() {
+		title += " [PAUSED]"
+	}
+
+	if h.Chip8Fault != nil {
+		if err := h.Chip8Fault(); err != nil {
+			title += " - " + err.Error()
+		}
+	}
+
+	return title
+}
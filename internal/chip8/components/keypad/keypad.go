@@ -0,0 +1,38 @@
+package keypad
+
+// Keypad holds the state of the 16 keys, whatever the input device.
+type Keypad struct {
+	keys [KEY_COUNT]bool
+}
+
+const (
+	KEY_COUNT = 16
+)
+
+func New() *Keypad {
+	return &Keypad{}
+}
+
+func (k *Keypad) Init() {
+	k.keys = [KEY_COUNT]bool{}
+}
+
+func (k *Keypad) IsKeyPressed(key byte) bool {
+	return int(key) < KEY_COUNT && k.keys[key]
+}
+
+// SetKey changes the state of a key.
+func (k *Keypad) SetKey(key byte, pressed bool) {
+	k.keys[key] = pressed
+}
+
+// GetPressedKey returns the lowest pressed key.
+func (k *Keypad) GetPressedKey() *byte {
+	for id := range byte(KEY_COUNT) {
+		if k.keys[id] {
+			return &id
+		}
+	}
+
+	return nil
+}
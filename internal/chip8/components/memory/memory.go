@@ -1,24 +1,60 @@
 package memory
 
 import (
-	"fmt"
-
-	"github.com/cterence/chip8-go/internal/lib"
+	"cmp"
+	"slices"
 )
 
 type Memory struct {
 	ram [RAM_SIZE]byte
+
+	watchpoints map[int]Watchpoint
+	nextWatchID int
+	watchHits   []WatchHit
+}
+
+type AccessKind uint8
+
+const (
+	AK_READ AccessKind = 1 << iota
+	AK_WRITE
+
+	AK_ACCESS = AK_READ | AK_WRITE
+)
+
+// Watchpoint records accesses of Kind to the addresses between Start and End
+// (inclusive) for which Condition returns true. For reads, old and new are
+// both the value read.
+type Watchpoint struct {
+	Start     uint16
+	End       uint16
+	Kind      AccessKind
+	Condition func(old, new byte) bool
+}
+
+type WatchHit struct {
+	ID   int
+	Kind AccessKind
+	Addr uint16
+	Old  byte
+	New  byte
+}
+
+type State struct {
+	RAM [RAM_SIZE]byte
 }
 
 const (
-	RAM_SIZE uint16 = 0xFFFF
+	// RAM_SIZE covers every 16-bit address, so reads and writes through a
+	// uint16 are always in memory.
+	RAM_SIZE = 0x10000
 
 	INTERPRETER_RAM_START uint16 = 0
 	INTERPRETER_RAM_END   uint16 = 0x1FF
 
 	PROGRAM_RAM_START uint16 = INTERPRETER_RAM_END + 1
-	PROGRAM_RAM_END   uint16 = RAM_SIZE
-	PROGRAM_RAM_SIZE  uint16 = PROGRAM_RAM_END - PROGRAM_RAM_START
+	PROGRAM_RAM_END   uint16 = RAM_SIZE - 1
+	PROGRAM_RAM_SIZE  uint16 = PROGRAM_RAM_END - PROGRAM_RAM_START + 1
 )
 
 func New() *Memory {
@@ -28,6 +64,8 @@ func New() *Memory {
 }
 
 func (m *Memory) Init() {
+	m.watchHits = nil
+
 	fontSprites := [16 * 5]byte{
 		0xF0, 0x90, 0x90, 0x90, 0xF0,
 		0x20, 0x60, 0x20, 0x20, 0x70,
@@ -48,7 +86,7 @@ func (m *Memory) Init() {
 	}
 
 	for i := range fontSprites {
-		m.Write(uint16(i), fontSprites[i])
+		m.Poke(uint16(i), fontSprites[i])
 	}
 
 	superFontSprites := [16 * 10]byte{
@@ -71,18 +109,90 @@ func (m *Memory) Init() {
 	}
 
 	for i := range superFontSprites {
-		m.Write(uint16(i+len(fontSprites)), superFontSprites[i])
+		m.Poke(uint16(i+len(fontSprites)), superFontSprites[i])
 	}
 }
 
 func (m *Memory) Read(a uint16) byte {
-	lib.Assert(a < RAM_SIZE, fmt.Errorf("address must be lower than 0x%03X, actual 0x%03X", RAM_SIZE, a))
+	v := m.Peek(a)
 
-	return m.ram[a]
+	if len(m.watchpoints) > 0 {
+		m.watch(AK_READ, a, v, v)
+	}
+
+	return v
 }
 
 func (m *Memory) Write(a uint16, v byte) {
-	lib.Assert(a < RAM_SIZE, fmt.Errorf("address must be lower than 0x%03X, actual 0x%03X", RAM_SIZE, a))
+	if len(m.watchpoints) > 0 {
+		m.watch(AK_WRITE, a, m.Peek(a), v)
+	}
+
+	m.Poke(a, v)
+}
+
+// Peek reads memory without triggering watchpoints, for instruction fetches
+// and tooling.
+func (m *Memory) Peek(a uint16) byte {
+	return m.ram[a]
+}
 
+// Poke writes memory without triggering watchpoints, for loading programs and
+// tooling.
+func (m *Memory) Poke(a uint16, v byte) {
 	m.ram[a] = v
 }
+
+func (m *Memory) AddWatchpoint(w Watchpoint) int {
+	if m.watchpoints == nil {
+		m.watchpoints = make(map[int]Watchpoint)
+	}
+
+	m.nextWatchID++
+	m.watchpoints[m.nextWatchID] = w
+
+	return m.nextWatchID
+}
+
+func (m *Memory) RemoveWatchpoint(id int) bool {
+	_, ok := m.watchpoints[id]
+	delete(m.watchpoints, id)
+
+	return ok
+}
+
+// WatchHits returns and clears the watchpoint hits recorded since the last
+// call, by address then watchpoint ID.
+func (m *Memory) WatchHits() []WatchHit {
+	hits := m.watchHits
+	m.watchHits = nil
+
+	// The watchpoints hit by one access are found in map order
+	slices.SortStableFunc(hits, func(a, b WatchHit) int {
+		return cmp.Or(cmp.Compare(a.Addr, b.Addr), cmp.Compare(a.ID, b.ID))
+	})
+
+	return hits
+}
+
+func (m *Memory) watch(kind AccessKind, a uint16, old, new byte) {
+	for id, w := range m.watchpoints {
+		if w.Kind&kind == 0 || a < w.Start || a > w.End {
+			continue
+		}
+
+		if w.Condition != nil && !w.Condition(old, new) {
+			continue
+		}
+
+		m.watchHits = append(m.watchHits, WatchHit{ID: id, Kind: kind, Addr: a, Old: old, New: new})
+	}
+}
+
+func (m *Memory) SaveState() State {
+	return State{RAM: m.ram}
+}
+
+func (m *Memory) LoadState(s State) {
+	m.ram = s.RAM
+}
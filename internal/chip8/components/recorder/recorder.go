@@ -0,0 +1,118 @@
+package recorder
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"os"
+)
+
+// Recorder collects displayed frames into an animated GIF. Frames are added
+// once per 60 Hz frame and identical consecutive frames are merged into a
+// longer delay, so the timing only depends on the number of frames and not on
+// the wall clock.
+type Recorder struct {
+	scale  int
+	images []*image.Paletted
+	// 60 Hz frame at which each image starts to be displayed
+	starts []int
+	frames int
+}
+
+const (
+	FPS = 60
+	// GIF delays are in hundredths of a second and most viewers slow down
+	// frames shorter than 2/100 s, so faster changes are dropped
+	MIN_DELAY = 2
+)
+
+func New(scale int) *Recorder {
+	return &Recorder{
+		scale: max(scale, 1),
+	}
+}
+
+// Len returns the number of distinct images recorded.
+func (r *Recorder) Len() int {
+	return len(r.images)
+}
+
+// Add records the image displayed during the next frame. The image must not
+// be modified afterwards.
+func (r *Recorder) Add(img *image.Paletted) {
+	frame := r.frames
+	r.frames++
+
+	if n := len(r.images); n > 0 {
+		if bytes.Equal(r.images[n-1].Pix, img.Pix) {
+			return
+		}
+
+		if centiseconds(frame)-centiseconds(r.starts[n-1]) < MIN_DELAY {
+			r.images[n-1] = img
+
+			return
+		}
+	}
+
+	r.images = append(r.images, img)
+	r.starts = append(r.starts, frame)
+}
+
+// Encode writes the recording as a looping GIF.
+func (r *Recorder) Encode(w io.Writer) error {
+	if len(r.images) == 0 {
+		return fmt.Errorf("no frames recorded")
+	}
+
+	g := &gif.GIF{}
+
+	for i, img := range r.images {
+		end := r.frames
+		if i+1 < len(r.starts) {
+			end = r.starts[i+1]
+		}
+
+		g.Image = append(g.Image, upscale(img, r.scale))
+		g.Delay = append(g.Delay, max(centiseconds(end)-centiseconds(r.starts[i]), MIN_DELAY))
+	}
+
+	return gif.EncodeAll(w, g)
+}
+
+func (r *Recorder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create recording file: %w", err)
+	}
+	defer f.Close()
+
+	if err := r.Encode(f); err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+
+	return f.Close()
+}
+
+func centiseconds(frame int) int {
+	return (frame*100 + FPS/2) / FPS
+}
+
+func upscale(img *image.Paletted, scale int) *image.Paletted {
+	if scale == 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	out := image.NewPaletted(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale), img.Palette)
+
+	for y := range out.Rect.Dy() {
+		for x := range out.Rect.Dx() {
+			out.Pix[y*out.Stride+x] = img.Pix[(y/scale)*img.Stride+x/scale]
+		}
+	}
+
+	return out
+}
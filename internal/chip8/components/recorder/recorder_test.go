@@ -0,0 +1,50 @@
+package recorder_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/recorder"
+	"github.com/stretchr/testify/assert"
+)
+
+func frame(pixel byte) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 2), color.Palette{color.Black, color.White})
+	img.Pix[0] = pixel
+
+	return img
+}
+
+func TestRecorder(t *testing.T) {
+	r := recorder.New(2)
+
+	// 6 identical frames, then changes faster than the minimum delay
+	for range 6 {
+		r.Add(frame(0))
+	}
+
+	r.Add(frame(1))
+	r.Add(frame(0))
+	r.Add(frame(1))
+	r.Add(frame(1))
+
+	assert.Equal(t, 3, r.Len())
+
+	var buf bytes.Buffer
+
+	assert.NoError(t, r.Encode(&buf))
+
+	g, err := gif.DecodeAll(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{10, 2, 5}, g.Delay)
+	assert.Equal(t, image.Rect(0, 0, 8, 4), g.Image[0].Bounds())
+	assert.Equal(t, uint8(1), g.Image[2].ColorIndexAt(1, 1))
+	assert.Equal(t, uint8(0), g.Image[2].ColorIndexAt(2, 0))
+}
+
+func TestRecorderEmpty(t *testing.T) {
+	assert.Error(t, recorder.New(1).Encode(&bytes.Buffer{}))
+}
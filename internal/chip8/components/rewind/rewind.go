@@ -0,0 +1,127 @@
+package rewind
+
+import (
+	"encoding/binary"
+)
+
+// Buffer is a ring buffer of machine snapshots. Only the newest snapshot is
+// kept in full, older ones are stored as run-length encoded XOR deltas against
+// their successor, which keeps mostly unchanged RAM cheap to store.
+type Buffer struct {
+	latest []byte
+	deltas [][]byte
+	head   int
+	count  int
+}
+
+func New(capacity int) *Buffer {
+	return &Buffer{
+		deltas: make([][]byte, max(capacity-1, 0)),
+	}
+}
+
+// Len returns the number of snapshots that can be popped.
+func (b *Buffer) Len() int {
+	if b.latest == nil {
+		return 0
+	}
+
+	return b.count + 1
+}
+
+func (b *Buffer) Reset() {
+	b.latest = nil
+	b.head = 0
+	b.count = 0
+
+	for i := range b.deltas {
+		b.deltas[i] = nil
+	}
+}
+
+// Push records a new snapshot, dropping the oldest one if the buffer is full.
+func (b *Buffer) Push(snapshot []byte) {
+	if b.latest != nil && len(b.latest) == len(snapshot) && len(b.deltas) > 0 {
+		b.deltas[b.head] = encodeDelta(b.latest, snapshot)
+		b.head = (b.head + 1) % len(b.deltas)
+		b.count = min(b.count+1, len(b.deltas))
+	} else {
+		b.count = 0
+	}
+
+	b.latest = append(b.latest[:0], snapshot...)
+}
+
+// Pop removes and returns the newest snapshot.
+func (b *Buffer) Pop() ([]byte, bool) {
+	if b.latest == nil {
+		return nil, false
+	}
+
+	snapshot := make([]byte, len(b.latest))
+	copy(snapshot, b.latest)
+
+	if b.count == 0 {
+		b.latest = nil
+
+		return snapshot, true
+	}
+
+	b.head = (b.head - 1 + len(b.deltas)) % len(b.deltas)
+	applyDelta(b.latest, b.deltas[b.head])
+	b.deltas[b.head] = nil
+	b.count--
+
+	return snapshot, true
+}
+
+// encodeDelta encodes a XOR b as a sequence of (zero run, literal length,
+// literal bytes) records.
+func encodeDelta(a, b []byte) []byte {
+	var delta []byte
+
+	for i := 0; i < len(a); {
+		zeroRun := 0
+		for i+zeroRun < len(a) && a[i+zeroRun] == b[i+zeroRun] {
+			zeroRun++
+		}
+
+		i += zeroRun
+
+		litLen := 0
+		for i+litLen < len(a) && a[i+litLen] != b[i+litLen] {
+			litLen++
+		}
+
+		delta = binary.AppendUvarint(delta, uint64(zeroRun))
+		delta = binary.AppendUvarint(delta, uint64(litLen))
+
+		for j := range litLen {
+			delta = append(delta, a[i+j]^b[i+j])
+		}
+
+		i += litLen
+	}
+
+	return delta
+}
+
+func applyDelta(dst, delta []byte) {
+	pos := 0
+
+	for len(delta) > 0 {
+		zeroRun, n := binary.Uvarint(delta)
+		delta = delta[n:]
+		litLen, n := binary.Uvarint(delta)
+		delta = delta[n:]
+
+		pos += int(zeroRun)
+
+		for j := range int(litLen) {
+			dst[pos+j] ^= delta[j]
+		}
+
+		pos += int(litLen)
+		delta = delta[litLen:]
+	}
+}
@@ -0,0 +1,79 @@
+package rewind_test
+
+import (
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/rewind"
+	"github.com/stretchr/testify/assert"
+)
+
+func snapshot(i int) []byte {
+	s := make([]byte, 64)
+	s[0] = byte(i)
+	s[10+i%8] = 0xFF
+	s[63] = byte(i * 3)
+
+	return s
+}
+
+func Test(t *testing.T) {
+	t.Run("PopReversesPush", func(t *testing.T) {
+		b := rewind.New(8)
+
+		for i := range 5 {
+			b.Push(snapshot(i))
+		}
+
+		assert.Equal(t, 5, b.Len())
+
+		for i := 4; i >= 0; i-- {
+			s, ok := b.Pop()
+			assert.True(t, ok)
+			assert.Equal(t, snapshot(i), s)
+		}
+
+		_, ok := b.Pop()
+		assert.False(t, ok)
+	})
+
+	t.Run("DropsOldest", func(t *testing.T) {
+		b := rewind.New(4)
+
+		for i := range 10 {
+			b.Push(snapshot(i))
+		}
+
+		assert.Equal(t, 4, b.Len())
+
+		for i := 9; i >= 6; i-- {
+			s, ok := b.Pop()
+			assert.True(t, ok)
+			assert.Equal(t, snapshot(i), s)
+		}
+
+		assert.Equal(t, 0, b.Len())
+	})
+
+	t.Run("PushAfterPop", func(t *testing.T) {
+		b := rewind.New(4)
+
+		b.Push(snapshot(0))
+		b.Push(snapshot(1))
+		b.Pop()
+		b.Push(snapshot(2))
+
+		s, _ := b.Pop()
+		assert.Equal(t, snapshot(2), s)
+		s, _ = b.Pop()
+		assert.Equal(t, snapshot(0), s)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		b := rewind.New(4)
+
+		b.Push(snapshot(0))
+		b.Reset()
+
+		assert.Equal(t, 0, b.Len())
+	})
+}
@@ -0,0 +1,530 @@
+// Package terminal is a frontend drawing the display with half-block
+// characters and 24-bit ANSI colors, reading the keyboard in raw mode.
+package terminal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/chip8/components/frontend"
+	"github.com/cterence/chip8-go/internal/chip8/components/keypad"
+	"golang.org/x/term"
+)
+
+// Terminal draws two display rows per text row, the top pixel being the
+// foreground color of '▀' and the bottom pixel its background color.
+//
+// Terminals do not report key releases: a key is held while the terminal
+// repeats it and released keyTimeout after the last repeat. While paused,
+// typed characters are sent to the debugger instead of the keypad.
+type Terminal struct {
+	display    *display.Display
+	in         io.Reader
+	out        io.Writer
+	keyTimeout time.Duration
+
+	started   bool
+	restore   func()
+	input     chan []byte
+	screen    bytes.Buffer
+	lastRes   int
+	commands  *commandReader
+	command   string
+	keyIDs    map[byte]byte
+	keyMap    frontend.KeyMap
+	buttonIDs map[string]byte
+	keyExpiry [keypad.KEY_COUNT]time.Time
+	rewindEnd time.Time
+
+	eventCooldown time.Time
+
+	// Log and debugger output shown under the display
+	mu       sync.Mutex
+	messages []string
+	partial  string
+
+	frontend.Hooks
+}
+
+type Option func(*Terminal)
+
+const (
+	// Longer than the usual 500ms keyboard repeat delay, for held keys not to
+	// be released before their first repeat
+	DEFAULT_KEY_TIMEOUT = 600 * time.Millisecond
+	MESSAGE_LINES       = 5
+
+	HALF_BLOCK = "▀"
+	CTRL_C     = "\x03"
+	ESCAPE     = "\x1b"
+)
+
+// Escape sequences sent by F1-F4, with and without Shift
+var (
+	functionKeys = map[string]int{
+		"\x1bOP": 1, "\x1bOQ": 2, "\x1bOR": 3, "\x1bOS": 4,
+		"\x1b[11~": 1, "\x1b[12~": 2, "\x1b[13~": 3, "\x1b[14~": 4,
+	}
+	shiftFunctionKeys = map[string]int{
+		"\x1b[1;2P": 1, "\x1b[1;2Q": 2, "\x1b[1;2R": 3, "\x1b[1;2S": 4,
+	}
+	// Sequences sent by the arrow keys, in normal and application mode, and
+	// Enter. There is no key for the b button.
+	buttonSequences = map[string][]string{
+		"up":    {"\x1b[A", "\x1bOA"},
+		"down":  {"\x1b[B", "\x1bOB"},
+		"right": {"\x1b[C", "\x1bOC"},
+		"left":  {"\x1b[D", "\x1bOD"},
+		"a":     {"\r"},
+	}
+)
+
+func New(d *display.Display, options ...Option) *Terminal {
+	t := &Terminal{
+		display:    d,
+		in:         os.Stdin,
+		out:        os.Stdout,
+		keyTimeout: DEFAULT_KEY_TIMEOUT,
+		commands:   &commandReader{lines: make(chan string, 16)},
+	}
+
+	for _, o := range options {
+		o(t)
+	}
+
+	// Same layout as the SDL frontend
+	t.keyIDs = map[byte]byte{
+		'1': 0x1, '2': 0x2, '3': 0x3, '4': 0xC,
+		'q': 0x4, 'w': 0x5, 'e': 0x6, 'r': 0xD,
+		'a': 0x7, 's': 0x8, 'd': 0x9, 'f': 0xE,
+		'z': 0xA, 'x': 0x0, 'c': 0xB, 'v': 0xF,
+	}
+
+	t.buttonIDs = map[string]byte{}
+
+	for button, key := range t.keyMap {
+		for _, sequence := range buttonSequences[button] {
+			t.buttonIDs[sequence] = key
+		}
+	}
+
+	return t
+}
+
+func WithInput(in io.Reader) Option {
+	return func(t *Terminal) {
+		t.in = in
+	}
+}
+
+func WithOutput(out io.Writer) Option {
+	return func(t *Terminal) {
+		t.out = out
+	}
+}
+
+// WithKeyMap binds the arrow keys and Enter to keypad keys, on top of the
+// keypad layout.
+func WithKeyMap(keyMap frontend.KeyMap) Option {
+	return func(t *Terminal) {
+		t.keyMap = keyMap
+	}
+}
+
+// WithKeyTimeout sets how long a key stays pressed after the terminal last
+// sent it. It must be longer than the keyboard repeat delay for held keys
+// not to be released between repeats.
+func WithKeyTimeout(timeout time.Duration) Option {
+	return func(t *Terminal) {
+		if timeout > 0 {
+			t.keyTimeout = timeout
+		}
+	}
+}
+
+// Init switches the terminal to raw mode and the alternate screen, and
+// starts reading the keyboard. It does nothing once started, as it is called
+// again on reset.
+func (t *Terminal) Init() error {
+	if t.started {
+		return nil
+	}
+
+	if f, ok := t.in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		state, err := term.MakeRaw(int(f.Fd()))
+		if err != nil {
+			return fmt.Errorf("failed to set terminal raw mode: %w", err)
+		}
+
+		t.restore = func() {
+			if err := term.Restore(int(f.Fd()), state); err != nil {
+				log.Printf("failed to restore terminal: %v", err)
+			}
+		}
+	}
+
+	// Alternate screen, hidden cursor
+	if _, err := io.WriteString(t.out, "\x1b[?1049h\x1b[?25l\x1b[2J"); err != nil {
+		return fmt.Errorf("failed to init terminal: %w", err)
+	}
+
+	log.SetOutput(t)
+
+	t.input = make(chan []byte, 16)
+	t.started = true
+
+	go t.readInput()
+
+	return nil
+}
+
+func (t *Terminal) readInput() {
+	buf := make([]byte, 256)
+
+	for {
+		n, err := t.in.Read(buf)
+		if n > 0 {
+			t.input <- bytes.Clone(buf[:n])
+		}
+
+		if err != nil {
+			close(t.input)
+
+			return
+		}
+	}
+}
+
+func (t *Terminal) Destroy() {
+	if !t.started {
+		return
+	}
+
+	log.SetOutput(os.Stderr)
+
+	io.WriteString(t.out, "\x1b[0m\x1b[?25h\x1b[?1049l")
+
+	if t.restore != nil {
+		t.restore()
+	}
+
+	t.started = false
+}
+
+// Commands returns the debugger commands typed while paused, one per line.
+func (t *Terminal) Commands() io.Reader {
+	return t.commands
+}
+
+// Write adds log or debugger output to the lines shown under the display.
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := strings.Split(t.partial+string(p), "\n")
+	t.partial = lines[len(lines)-1]
+	t.messages = append(t.messages, lines[:len(lines)-1]...)
+
+	if len(t.messages) > MESSAGE_LINES {
+		t.messages = t.messages[len(t.messages)-MESSAGE_LINES:]
+	}
+
+	return len(p), nil
+}
+
+func (t *Terminal) Update() error {
+	frameBuffer, palette, res := t.display.FrameBuffers(), t.display.Palette(), max(t.display.Res(), 1)
+
+	t.screen.Reset()
+
+	// Clear leftovers of the high resolution display when switching to low
+	if res != t.lastRes {
+		t.screen.WriteString("\x1b[2J")
+		t.lastRes = res
+	}
+
+	t.screen.WriteString("\x1b[H")
+
+	t.screen.WriteString(t.Title() + "\x1b[K\r\n")
+
+	var fg, bg uint32
+
+	for y := 0; y < display.HEIGHT; y += 2 * res {
+		for x := 0; x < display.WIDTH; x += res {
+			top := palette[frameBuffer[1][x][y]<<1|frameBuffer[0][x][y]]
+			bottom := palette[frameBuffer[1][x][y+res]<<1|frameBuffer[0][x][y+res]]
+
+			if x == 0 || top != fg {
+				fmt.Fprintf(&t.screen, "\x1b[38;2;%d;%d;%dm", byte(top>>16), byte(top>>8), byte(top))
+			}
+
+			if x == 0 || bottom != bg {
+				fmt.Fprintf(&t.screen, "\x1b[48;2;%d;%d;%dm", byte(bottom>>16), byte(bottom>>8), byte(bottom))
+			}
+
+			fg, bg = top, bottom
+
+			t.screen.WriteString(HALF_BLOCK)
+		}
+
+		t.screen.WriteString("\x1b[0m\r\n")
+	}
+
+	t.mu.Lock()
+
+	for i := range MESSAGE_LINES {
+		if i < len(t.messages) {
+			t.screen.WriteString(t.messages[i])
+		}
+
+		t.screen.WriteString("\x1b[K\r\n")
+	}
+
+	t.screen.WriteString(t.partial)
+
+	t.mu.Unlock()
+
+	if t.IsChip8Paused() {
+		t.screen.WriteString(t.command)
+	}
+
+	t.screen.WriteString("\x1b[K")
+
+	if _, err := t.out.Write(t.screen.Bytes()); err != nil {
+		return fmt.Errorf("failed to draw terminal: %w", err)
+	}
+
+	return nil
+}
+
+// HandleEvents processes the input read since the last call, then releases
+// the keys that were not repeated within the key timeout.
+func (t *Terminal) HandleEvents() error {
+	now := time.Now()
+
+	for drained := false; !drained; {
+		select {
+		case b, ok := <-t.input:
+			if !ok {
+				// Keep running without input
+				t.input = nil
+
+				continue
+			}
+
+			for len(b) > 0 {
+				token, n := nextToken(b)
+				b = b[n:]
+
+				if err := t.handleToken(token, now); err != nil {
+					return err
+				}
+			}
+		default:
+			drained = true
+		}
+	}
+
+	for key, expiry := range t.keyExpiry {
+		if !expiry.IsZero() && now.After(expiry) {
+			t.keyExpiry[key] = time.Time{}
+			t.KeyChangedChip8(byte(key), false)
+		}
+	}
+
+	if !t.rewindEnd.IsZero() && now.After(t.rewindEnd) {
+		t.rewindEnd = time.Time{}
+		t.RewindChip8(false)
+	}
+
+	return nil
+}
+
+func (t *Terminal) handleToken(token string, now time.Time) error {
+	if token == CTRL_C {
+		log.Println("exit")
+
+		return frontend.ErrQuit
+	}
+
+	if slot, ok := functionKeys[token]; ok {
+		t.handleStateSlot(slot, false)
+
+		return nil
+	}
+
+	if slot, ok := shiftFunctionKeys[token]; ok {
+		t.handleStateSlot(slot, true)
+
+		return nil
+	}
+
+	if t.IsChip8Paused() {
+		t.editCommand(token)
+
+		return nil
+	}
+
+	if key, ok := t.buttonIDs[token]; ok {
+		t.pressKey(key, now)
+
+		return nil
+	}
+
+	if len(token) != 1 {
+		return nil
+	}
+
+	c := token[0]
+	if c >= 'A' && c <= 'Z' {
+		c += 'a' - 'A'
+	}
+
+	if key, ok := t.keyIDs[c]; ok {
+		t.pressKey(key, now)
+
+		return nil
+	}
+
+	switch c {
+	case 0x7F, '\b':
+		if t.rewindEnd.IsZero() {
+			t.RewindChip8(true)
+		}
+
+		t.rewindEnd = now.Add(t.keyTimeout)
+
+		return nil
+	}
+
+	if now.Sub(t.eventCooldown) <= 100*time.Millisecond {
+		return nil
+	}
+
+	t.eventCooldown = now
+
+	switch c {
+	case ' ':
+		log.Println("reset")
+
+		if err := t.ResetChip8(); err != nil {
+			return fmt.Errorf("failed to reset chip8: %w", err)
+		}
+	case 'p':
+		t.TogglePauseChip8()
+	case 'g':
+		t.ToggleRecordChip8()
+	case '\t':
+		t.TakeOverChip8()
+	case 'm':
+		log.Println("exit")
+
+		return frontend.ErrQuit
+	}
+
+	return nil
+}
+
+// pressKey presses a keypad key, or keeps it pressed, until keyTimeout from
+// now.
+func (t *Terminal) pressKey(key byte, now time.Time) {
+	if t.keyExpiry[key].IsZero() {
+		t.KeyChangedChip8(key, true)
+	}
+
+	t.keyExpiry[key] = now.Add(t.keyTimeout)
+}
+
+// editCommand edits the debugger command line: Enter sends it, Escape
+// resumes execution.
+func (t *Terminal) editCommand(token string) {
+	switch token {
+	case "\r", "\n":
+		fmt.Fprintln(t, t.command)
+
+		select {
+		case t.commands.lines <- t.command + "\n":
+		default:
+			log.Println("debugger is busy, command dropped")
+		}
+
+		t.command = ""
+	case "\x7f", "\b":
+		if t.command != "" {
+			t.command = t.command[:len(t.command)-1]
+		}
+	case ESCAPE:
+		t.command = ""
+		t.TogglePauseChip8()
+	default:
+		if len(token) == 1 && token[0] >= ' ' && token[0] < 0x7F {
+			t.command += token
+		}
+	}
+}
+
+// handleStateSlot saves the machine state to the given slot when save is set
+// (Shift+F1-F4), loads it otherwise (F1-F4).
+func (t *Terminal) handleStateSlot(slot int, save bool) {
+	if save {
+		if err := t.SaveStateChip8(slot); err != nil {
+			log.Printf("failed to save state to slot %d: %v", slot, err)
+		}
+
+		return
+	}
+
+	if err := t.LoadStateChip8(slot); err != nil {
+		log.Printf("failed to load state from slot %d: %v", slot, err)
+	}
+}
+
+// nextToken returns the first key in b: a character or a whole escape
+// sequence, and its length.
+func nextToken(b []byte) (string, int) {
+	if b[0] != 0x1B || len(b) == 1 {
+		return string(b[:1]), 1
+	}
+
+	switch b[1] {
+	case 'O':
+		if len(b) >= 3 {
+			return string(b[:3]), 3
+		}
+	case '[':
+		// CSI sequences end with a byte in 0x40-0x7E
+		for i := 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7E {
+				return string(b[:i+1]), i + 1
+			}
+		}
+	default:
+		return ESCAPE, 1
+	}
+
+	return string(b), len(b)
+}
+
+// commandReader hands the debugger the lines typed while paused.
+type commandReader struct {
+	lines   chan string
+	pending string
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	if r.pending == "" {
+		r.pending = <-r.lines
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+
+	return n, nil
+}
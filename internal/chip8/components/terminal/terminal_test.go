@@ -0,0 +1,207 @@
+package terminal_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/chip8/components/frontend"
+	"github.com/cterence/chip8-go/internal/chip8/components/terminal"
+	"github.com/stretchr/testify/assert"
+)
+
+type keyChange struct {
+	key     byte
+	pressed bool
+}
+
+func fgColor(argb uint32) string {
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", byte(argb>>16), byte(argb>>8), byte(argb))
+}
+
+func bgColor(argb uint32) string {
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm", byte(argb>>16), byte(argb>>8), byte(argb))
+}
+
+func newTerminal(t *testing.T, d *display.Display, paused *bool, changes *[]keyChange, options ...terminal.Option) (*terminal.Terminal, *io.PipeWriter, *bytes.Buffer) {
+	t.Helper()
+
+	in, keyboard := io.Pipe()
+	out := &bytes.Buffer{}
+
+	d.Init()
+
+	options = append([]terminal.Option{terminal.WithInput(in), terminal.WithOutput(out), terminal.WithKeyTimeout(50 * time.Millisecond)}, options...)
+
+	term := terminal.New(d, options...)
+	term.Hooks = frontend.Hooks{
+		IsChip8Paused:    func() bool { return *paused },
+		TogglePauseChip8: func() { *paused = !*paused },
+		KeyChangedChip8: func(key byte, pressed bool) {
+			*changes = append(*changes, keyChange{key, pressed})
+		},
+	}
+
+	assert.NoError(t, term.Init())
+	t.Cleanup(term.Destroy)
+
+	return term, keyboard, out
+}
+
+func TestUpdate(t *testing.T) {
+	paused := false
+	d := display.New()
+	term, _, out := newTerminal(t, d, &paused, nil)
+
+	d.DrawSprite(0, 0, []byte{0x80})
+	out.Reset()
+
+	assert.NoError(t, term.Update())
+
+	// 64x32 low resolution pixels on 16 rows, the top pixel of the first one is set
+	palette := d.Palette()
+
+	assert.Equal(t, 64*16, strings.Count(out.String(), terminal.HALF_BLOCK))
+	// Colors are only sent when they change
+	assert.Contains(t, out.String(), fgColor(palette[1])+bgColor(palette[0])+terminal.HALF_BLOCK+fgColor(palette[0])+terminal.HALF_BLOCK)
+	assert.NotContains(t, out.String(), "[PAUSED]")
+
+	paused = true
+	out.Reset()
+
+	assert.NoError(t, term.Update())
+	assert.Contains(t, out.String(), "chip8-go [PAUSED]")
+}
+
+func TestKeyTimeout(t *testing.T) {
+	paused := false
+
+	var changes []keyChange
+
+	term, keyboard, _ := newTerminal(t, display.New(), &paused, &changes)
+
+	// Repeated and upper case keys are only pressed once
+	go keyboard.Write([]byte("wwW"))
+
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, term.HandleEvents())
+
+		return len(changes) > 0
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, []keyChange{{0x5, true}}, changes)
+
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, term.HandleEvents())
+
+		return len(changes) > 1
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, []keyChange{{0x5, true}, {0x5, false}}, changes)
+}
+
+func TestDefaultKeyTimeout(t *testing.T) {
+	paused := false
+
+	var changes []keyChange
+
+	term, keyboard, _ := newTerminal(t, display.New(), &paused, &changes, terminal.WithKeyTimeout(terminal.DEFAULT_KEY_TIMEOUT))
+
+	go keyboard.Write([]byte("w"))
+
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, term.HandleEvents())
+
+		return len(changes) > 0
+	}, time.Second, time.Millisecond)
+
+	// A held key is first repeated after the usual 500ms keyboard delay
+	time.Sleep(500 * time.Millisecond)
+	assert.NoError(t, term.HandleEvents())
+	assert.Equal(t, []keyChange{{0x5, true}}, changes)
+
+	_, err := keyboard.Write([]byte("w"))
+	assert.NoError(t, err)
+
+	repeated := time.Now()
+
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, term.HandleEvents())
+
+		return len(changes) > 1
+	}, 2*time.Second, time.Millisecond)
+
+	// Released once the timeout has passed since the last repeat
+	assert.Equal(t, []keyChange{{0x5, true}, {0x5, false}}, changes)
+	assert.GreaterOrEqual(t, time.Since(repeated), terminal.DEFAULT_KEY_TIMEOUT)
+}
+
+func TestKeyMap(t *testing.T) {
+	paused := false
+
+	var changes []keyChange
+
+	term, keyboard, _ := newTerminal(t, display.New(), &paused, &changes, terminal.WithKeyMap(frontend.KeyMap{"up": 0x5, "a": 0x6}))
+
+	// Down is not mapped
+	go keyboard.Write([]byte("\x1b[A\x1b[B\r"))
+
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, term.HandleEvents())
+
+		return len(changes) > 1
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, []keyChange{{0x5, true}, {0x6, true}}, changes)
+}
+
+func TestDebuggerCommands(t *testing.T) {
+	paused := true
+
+	var changes []keyChange
+
+	term, keyboard, out := newTerminal(t, display.New(), &paused, &changes)
+
+	go keyboard.Write([]byte("regz\x7fs\r"))
+
+	lines := bufio.NewScanner(term.Commands())
+
+	// The command is echoed under the display once sent
+	assert.Eventually(t, func() bool {
+		out.Reset()
+
+		assert.NoError(t, term.HandleEvents())
+		assert.NoError(t, term.Update())
+
+		return strings.Contains(out.String(), "regs\x1b[K\r\n")
+	}, time.Second, time.Millisecond)
+
+	assert.True(t, lines.Scan())
+	assert.Equal(t, "regs", lines.Text())
+	assert.Empty(t, changes)
+
+	// Escape resumes
+	go keyboard.Write([]byte("\x1b"))
+
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, term.HandleEvents())
+
+		return !paused
+	}, time.Second, time.Millisecond)
+}
+
+func TestQuit(t *testing.T) {
+	paused := false
+	term, keyboard, _ := newTerminal(t, display.New(), &paused, nil)
+
+	go keyboard.Write([]byte{0x03})
+
+	assert.Eventually(t, func() bool {
+		return term.HandleEvents() == frontend.ErrQuit
+	}, time.Second, time.Millisecond)
+}
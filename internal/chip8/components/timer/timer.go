@@ -1,12 +1,12 @@
 package timer
 
 import (
-	"github.com/cterence/chip8-go/internal/chip8/components/apu"
+	"github.com/cterence/chip8-go/internal/chip8/components/frontend"
 	"github.com/cterence/chip8-go/internal/lib"
 )
 
 type Timer struct {
-	apu               *apu.APU
+	speaker           frontend.Sound
 	CompatibilityMode lib.CompatibilityMode
 
 	delay uint8
@@ -15,14 +15,19 @@ type Timer struct {
 
 type Option func(*Timer)
 
-func New(apu *apu.APU, options ...Option) *Timer {
+type State struct {
+	Delay uint8
+	Sound uint8
+}
+
+func New(speaker frontend.Sound, options ...Option) *Timer {
 	t := &Timer{}
 
 	for _, o := range options {
 		o(t)
 	}
 
-	t.apu = apu
+	t.speaker = speaker
 
 	return t
 }
@@ -40,10 +45,23 @@ func (t *Timer) SetDelay(v byte) {
 	t.delay = v
 }
 
+func (t *Timer) GetSound() byte {
+	return t.sound
+}
+
 func (t *Timer) SetSound(v byte) {
 	t.sound = v
 }
 
+func (t *Timer) SaveState() State {
+	return State{Delay: t.delay, Sound: t.sound}
+}
+
+func (t *Timer) LoadState(s State) {
+	t.delay = s.Delay
+	t.sound = s.Sound
+}
+
 func (t *Timer) Tick() {
 	if t.delay > 0 {
 		t.delay--
@@ -51,11 +69,14 @@ func (t *Timer) Tick() {
 
 	if t.sound > 0 {
 		if t.CompatibilityMode == lib.CM_XOCHIP || t.sound > 1 {
-			t.apu.PlaySound()
+			t.speaker.PlaySound()
+		} else {
+			t.speaker.PlaySilence()
 		}
 
 		t.sound--
 	} else {
-		t.apu.ResetPhase()
+		t.speaker.ResetPhase()
+		t.speaker.PlaySilence()
 	}
 }
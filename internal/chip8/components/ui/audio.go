@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/cterence/chip8-go/internal/chip8/components/apu"
+)
+
+// speaker plays the samples on the default SDL playback device.
+type speaker struct {
+	id          sdl.AudioDeviceID
+	audioStream *sdl.AudioStream
+}
+
+func (s *speaker) open(sampleRate int32) error {
+	if s.audioStream != nil {
+		return nil
+	}
+
+	spec := &sdl.AudioSpec{
+		Freq:     sampleRate,
+		Format:   sdl.AUDIO_U8,
+		Channels: 1,
+	}
+
+	err := sdl.Init(sdl.INIT_AUDIO)
+	if err != nil {
+		return fmt.Errorf("failed to init sdl audio: %w", err)
+	}
+
+	s.id, err = sdl.AUDIO_DEVICE_DEFAULT_PLAYBACK.OpenAudioDevice(spec)
+	if err != nil {
+		return fmt.Errorf("failed to get default playback audio device: %w", err)
+	}
+
+	s.audioStream, err = sdl.CreateAudioStream(spec, spec)
+	if err != nil {
+		return fmt.Errorf("failed to create audio stream: %w", err)
+	}
+
+	if err := s.id.BindAudioStream(s.audioStream); err != nil {
+		return fmt.Errorf("failed to bind audio stream to device: %w", err)
+	}
+
+	return nil
+}
+
+func (s *speaker) play(sound []byte) {
+	available, err := s.audioStream.Available()
+	if err != nil {
+		log.Printf("failed to get available audio stream: %v", err)
+	}
+
+	if available < int32(len(sound)) {
+		if err := s.audioStream.PutData(sound); err != nil {
+			log.Printf("failed to put data to audio stream: %v", err)
+		}
+	}
+}
+
+// PlayAudio plays the samples generated by the APU during one frame. Silent
+// frames are skipped.
+func (ui *UI) PlayAudio(samples []byte) {
+	if ui.audioDisabled || ui.speaker.audioStream == nil {
+		return
+	}
+
+	for _, s := range samples {
+		if s != apu.SAMPLE_SILENCE {
+			ui.speaker.play(samples)
+
+			return
+		}
+	}
+}
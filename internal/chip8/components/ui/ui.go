@@ -4,23 +4,24 @@ import (
 	"fmt"
 	"log"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Zyko0/go-sdl3/bin/binimg"
 	"github.com/Zyko0/go-sdl3/img"
 	"github.com/Zyko0/go-sdl3/sdl"
-	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/cterence/chip8-go/internal/chip8/components/apu"
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/chip8/components/frontend"
 )
 
+// UI is the SDL frontend: it presents the display in a window and turns
+// keyboard events into keypad changes and hotkeys.
 type UI struct {
-	compatibilityMode lib.CompatibilityMode
-	scale             int
-
-	SelectedFrameBuffer SelectedFrameBuffer
-	frameBuffer         [2][WIDTH][HEIGHT]byte
-	colorPalette        [4]uint32
+	display       *display.Display
+	scale         int
+	audioDisabled bool
+	speaker       speaker
 
 	window      *sdl.Window
 	windowTitle string
@@ -28,49 +29,30 @@ type UI struct {
 	texture     *sdl.Texture
 	surface     *sdl.Surface
 
-	keyPressed *byte
-	sdlKeyIDs  map[sdl.Keycode]byte
-	keyState   map[byte]bool
+	sdlKeyIDs map[sdl.Keycode]byte
+	keyMap    frontend.KeyMap
 
-	res             int
-	eventCooldown   time.Time
-	scrollDirection ScrollDirection
-	scrollPixels    int32
+	eventCooldown time.Time
 
-	ResetChip8       func() error
-	IsChip8Paused    func() bool
-	TogglePauseChip8 func()
-	TickChip8        func() error
+	frontend.Hooks
 }
 
 type Option func(*UI)
 
-const (
-	WIDTH  = 128
-	HEIGHT = 64
-)
-
-type ScrollDirection uint8
-
-const (
-	SD_NONE ScrollDirection = iota
-	SD_LEFT
-	SD_RIGHT
-	SD_DOWN
-	SD_UP
-)
-
-type SelectedFrameBuffer uint8
-
-const (
-	SF_NONE SelectedFrameBuffer = iota
-	SF_0
-	SF_1
-	SF_BOTH
-)
+// Keys of the key map buttons
+var buttonKeycodes = map[string]sdl.Keycode{
+	"up":    sdl.K_UP,
+	"down":  sdl.K_DOWN,
+	"left":  sdl.K_LEFT,
+	"right": sdl.K_RIGHT,
+	"a":     sdl.K_RETURN,
+	"b":     sdl.K_RSHIFT,
+}
 
-func New(options ...Option) *UI {
-	ui := &UI{}
+func New(d *display.Display, options ...Option) *UI {
+	ui := &UI{
+		display: d,
+	}
 
 	for _, o := range options {
 		o(ui)
@@ -98,9 +80,11 @@ func New(options ...Option) *UI {
 		sdl.K_V: 0xF,
 	}
 
-	ui.keyState = make(map[byte]bool)
-	ui.colorPalette = [4]uint32{0xFF0C0F1C, 0xFF87B6FF, 0xFFFFA7C8, 0xFFD0A7FF}
-	// ui.colorPalette = [4]uint32{0xFF0C0F1C, 0xFF87B6FF, 0xFFFFA7C8, 0xFFFFA7C8}
+	for button, key := range ui.keyMap {
+		if keycode, ok := buttonKeycodes[button]; ok {
+			ui.sdlKeyIDs[keycode] = key
+		}
+	}
 
 	return ui
 }
@@ -111,17 +95,22 @@ func WithScale(scale int) Option {
 	}
 }
 
-func WithCompatibilityMode(mode lib.CompatibilityMode) Option {
-	return func(ui *UI) {
-		ui.compatibilityMode = mode
+// WithKeyMap binds the arrow keys, Return and Right Shift to keypad keys, on
+// top of the keypad layout.
+func WithKeyMap(keyMap frontend.KeyMap) Option {
+	return func(u *UI) {
+		u.keyMap = keyMap
+	}
+}
+
+// WithAudioDisabled never opens an audio device.
+func WithAudioDisabled(audioDisabled bool) Option {
+	return func(u *UI) {
+		u.audioDisabled = audioDisabled
 	}
 }
 
 func (ui *UI) Init() error {
-	ui.scrollDirection = SD_NONE
-	ui.scrollPixels = 0
-	ui.res = 2
-	ui.keyPressed = nil
 	ui.windowTitle = "chip8-go"
 
 	err := sdl.Init(sdl.INIT_VIDEO)
@@ -130,50 +119,50 @@ func (ui *UI) Init() error {
 	}
 
 	if ui.window == nil && ui.renderer == nil {
-		ui.window, ui.renderer, err = sdl.CreateWindowAndRenderer(ui.windowTitle, WIDTH*ui.scale, HEIGHT*ui.scale, sdl.WINDOW_RESIZABLE)
+		ui.window, ui.renderer, err = sdl.CreateWindowAndRenderer(ui.windowTitle, display.WIDTH*ui.scale, display.HEIGHT*ui.scale, sdl.WINDOW_RESIZABLE)
 		if err != nil {
 			return fmt.Errorf("failed to create window and renderer: %w", err)
 		}
 	}
 
 	if ui.texture == nil {
-		ui.texture, err = ui.renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, WIDTH*ui.scale, HEIGHT*ui.scale)
+		ui.texture, err = ui.renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, display.WIDTH*ui.scale, display.HEIGHT*ui.scale)
 		if err != nil {
 			return fmt.Errorf("failed to create SDL texture: %w", err)
 		}
 	}
 
 	if ui.surface == nil {
-		ui.surface, err = sdl.CreateSurface(WIDTH*ui.scale, HEIGHT*ui.scale, sdl.PIXELFORMAT_ARGB8888)
+		ui.surface, err = sdl.CreateSurface(display.WIDTH*ui.scale, display.HEIGHT*ui.scale, sdl.PIXELFORMAT_ARGB8888)
 		if err != nil {
 			return fmt.Errorf("failed to create SDL surface: %w", err)
 		}
 	}
 
-	for _, v := range ui.sdlKeyIDs {
-		ui.keyState[v] = false
+	if !ui.audioDisabled {
+		if err := ui.speaker.open(apu.SAMPLE_RATE); err != nil {
+			return err
+		}
 	}
 
-	ui.SelectedFrameBuffer = SF_BOTH
-	ui.Reset()
-	ui.SelectedFrameBuffer = SF_NONE
-
 	return nil
 }
 
 func (ui *UI) Update() error {
-	for x := range WIDTH {
-		for y := range HEIGHT {
+	frameBuffer, palette := ui.display.FrameBuffers(), ui.display.Palette()
+
+	for x := range display.WIDTH {
+		for y := range display.HEIGHT {
 			rc := &sdl.Rect{
 				X: int32(x * ui.scale),
 				Y: int32(y * ui.scale),
-				W: int32(ui.scale * ui.res),
-				H: int32(ui.scale * ui.res),
+				W: int32(ui.scale),
+				H: int32(ui.scale),
 			}
 
-			pixel0, pixel1 := ui.frameBuffer[0][x][y], ui.frameBuffer[1][x][y]
+			pixel0, pixel1 := frameBuffer[0][x][y], frameBuffer[1][x][y]
 
-			color := ui.colorPalette[pixel1<<1|pixel0]
+			color := palette[pixel1<<1|pixel0]
 
 			if err := ui.surface.FillRect(rc, color); err != nil {
 				return fmt.Errorf("failed to fill rect: %w", err)
@@ -181,13 +170,7 @@ func (ui *UI) Update() error {
 		}
 	}
 
-	ui.scrollDirection = SD_NONE
-	ui.scrollPixels = 0
-	ui.windowTitle = "chip8-go"
-
-	if ui.IsChip8Paused() {
-		ui.windowTitle += " [PAUSED]"
-	}
+	ui.windowTitle = ui.Title()
 
 	if err := ui.texture.Update(nil, ui.surface.Pixels(), ui.surface.Pitch); err != nil {
 		return fmt.Errorf("failed to update texture: %w", err)
@@ -212,36 +195,6 @@ func (ui *UI) Update() error {
 	return nil
 }
 
-func (ui *UI) ToggleHiRes(enable bool) {
-	if enable {
-		ui.res = 1
-	} else {
-		ui.res = 2
-	}
-}
-
-func (ui *UI) DrawSprite(x, y byte, sprite []byte) bool {
-	fbIDs := ui.getFrameBufferIDs()
-
-	switch len(fbIDs) {
-	case 1:
-		return ui.drawSpriteOnFramebuffer(x, y, sprite, fbIDs[0])
-	case 2:
-		collision0 := ui.drawSpriteOnFramebuffer(x, y, sprite[:len(sprite)/2], fbIDs[0])
-		collision1 := ui.drawSpriteOnFramebuffer(x, y, sprite[len(sprite)/2:], fbIDs[1])
-
-		return collision0 || collision1
-	default:
-		panic("wrong framebuffer id slice length: " + strconv.Itoa(len(fbIDs)))
-	}
-}
-
-func (ui *UI) Reset() {
-	for _, i := range ui.getFrameBufferIDs() {
-		ui.resetFramebuffer(i)
-	}
-}
-
 func (ui *UI) Destroy() {
 	ui.renderer.Destroy()
 	ui.window.Destroy()
@@ -249,35 +202,7 @@ func (ui *UI) Destroy() {
 	ui.texture.Destroy()
 }
 
-func (ui *UI) IsKeyPressed(key byte) bool {
-	return ui.keyState[key]
-}
-
-func (ui *UI) GetPressedKey() *byte {
-	for id, pressed := range ui.keyState {
-		if pressed {
-			return &id
-		}
-	}
-
-	return nil
-}
-
-func (ui *UI) SelectFrameBuffer(id byte) {
-	switch id {
-	case 0:
-		ui.SelectedFrameBuffer = SF_NONE
-	case 1:
-		ui.SelectedFrameBuffer = SF_0
-	case 2:
-		ui.SelectedFrameBuffer = SF_1
-	case 3:
-		ui.SelectedFrameBuffer = SF_BOTH
-	default:
-		log.Fatalf("framebuffer id must be 0, 1, 2 or 3 actual %d", id)
-	}
-}
-
+// Screenshot saves the window content as a JPG file in the working directory.
 func (ui *UI) Screenshot(romFileName string) {
 	defer binimg.Load().Unload()
 
@@ -301,25 +226,24 @@ func (ui *UI) Screenshot(romFileName string) {
 	}
 }
 
-func (ui *UI) Scroll(sd ScrollDirection, pixels int) {
-	for _, i := range ui.getFrameBufferIDs() {
-		ui.scrollFrameBuffer(sd, pixels, i)
-	}
-}
-
 func (ui *UI) HandleEvents() error {
 	var event sdl.Event
 
 	for sdl.PollEvent(&event) {
 		switch event.Type {
 		case sdl.EVENT_QUIT, sdl.EVENT_WINDOW_DESTROYED:
-			return sdl.EndLoop
+			return frontend.ErrQuit
 		case sdl.EVENT_KEY_DOWN, sdl.EVENT_KEY_UP:
 			key := event.KeyboardEvent().Key
+			if id, ok := ui.sdlKeyIDs[key]; ok {
+				ui.KeyChangedChip8(id, event.Type == sdl.EVENT_KEY_DOWN)
+
+				continue
+			}
+
 			switch key {
-			case sdl.K_1, sdl.K_2, sdl.K_3, sdl.K_4, sdl.K_Q, sdl.K_W, sdl.K_E, sdl.K_R, sdl.K_A, sdl.K_S, sdl.K_D, sdl.K_F, sdl.K_Z, sdl.K_X, sdl.K_C, sdl.K_V:
-				keyId := ui.sdlKeyIDs[key]
-				ui.keyState[keyId] = event.Type == sdl.EVENT_KEY_DOWN
+			case sdl.K_BACKSPACE:
+				ui.RewindChip8(event.Type == sdl.EVENT_KEY_DOWN)
 			default:
 				if time.Since(ui.eventCooldown) > 100*time.Millisecond && event.Type == sdl.EVENT_KEY_DOWN {
 					switch key {
@@ -335,10 +259,16 @@ func (ui *UI) HandleEvents() error {
 						if ui.IsChip8Paused() {
 							return ui.TickChip8()
 						}
+					case sdl.K_G:
+						ui.ToggleRecordChip8()
+					case sdl.K_TAB:
+						ui.TakeOverChip8()
 					case sdl.K_M:
 						log.Println("exit")
 
-						return sdl.EndLoop
+						return frontend.ErrQuit
+					case sdl.K_F1, sdl.K_F2, sdl.K_F3, sdl.K_F4:
+						ui.handleStateSlot(int(key-sdl.K_F1)+1, event.KeyboardEvent().Mod&sdl.KMOD_SHIFT != 0)
 					}
 
 					ui.eventCooldown = time.Now()
@@ -350,116 +280,18 @@ func (ui *UI) HandleEvents() error {
 	return nil
 }
 
-func (ui *UI) scrolledCoords(x, y int, sd ScrollDirection, pixels int) (int, int) {
-	newX, newY := x, y
-
-	switch sd {
-	case SD_LEFT:
-		newX -= pixels * ui.res
-	case SD_RIGHT:
-		newX += pixels * ui.res
-	case SD_UP:
-		newY -= pixels * ui.res
-	case SD_DOWN:
-		newY += pixels * ui.res
-	}
-
-	return newX, newY
-}
-
-func (ui *UI) getFrameBufferIDs() []byte {
-	switch ui.SelectedFrameBuffer {
-	case SF_NONE, SF_0:
-		return []byte{0}
-	case SF_1:
-		return []byte{1}
-	case SF_BOTH:
-		return []byte{0, 1}
-	default:
-		log.Fatalf("unknown framebuffer ID: %d", ui.SelectedFrameBuffer)
-	}
-
-	return nil
-}
-
-func (ui *UI) drawSpriteOnFramebuffer(x, y byte, sprite []byte, frameBufferID byte) bool {
-	collision := false
-	startYDraw := (y * byte(ui.res)) % HEIGHT
-	spriteWidth := byte(8)
-	spriteHeight := byte(len(sprite))
-
-	if len(sprite) == 32 {
-		spriteWidth = 16
-		spriteHeight = 16
-	}
-
-	for row := range spriteHeight {
-		yDraw := (y + row) * byte(ui.res) % HEIGHT
-		prevXDraw := (x * byte(ui.res)) % WIDTH
-
-		if yDraw < startYDraw {
-			continue
+// handleStateSlot saves the machine state to the given slot when save is set
+// (Shift+F1-F4), loads it otherwise (F1-F4).
+func (ui *UI) handleStateSlot(slot int, save bool) {
+	if save {
+		if err := ui.SaveStateChip8(slot); err != nil {
+			log.Printf("failed to save state to slot %d: %v", slot, err)
 		}
 
-		spriteRow := uint16(sprite[row])
-
-		if spriteWidth == 16 {
-			spriteRow = (uint16(sprite[row*2]) << uint16(lib.BYTE_SIZE)) | uint16(sprite[row*2+1])
-		}
-
-		for offset := range spriteWidth {
-			xDraw := byte((int(x)+int(offset))*ui.res) % WIDTH
-			spritePixel := lib.Bit(spriteRow, uint16(spriteWidth-1-offset))
-			oldPixel := ui.frameBuffer[frameBufferID][xDraw][yDraw]
-			newPixel := spritePixel ^ oldPixel
-
-			if xDraw < prevXDraw {
-				continue
-			}
-
-			if spritePixel == 1 && oldPixel == 1 {
-				collision = true
-			}
-
-			prevXDraw = xDraw
-
-			// if spritePixel == 1 {
-			// 	fmt.Printf("Drawing pixel at (%d, %d)\n", xDraw, yDraw)
-			// }
-
-			ui.frameBuffer[frameBufferID][xDraw][yDraw] = newPixel
-			if ui.res == 2 {
-				ui.frameBuffer[frameBufferID][xDraw+1][yDraw] = newPixel
-				ui.frameBuffer[frameBufferID][xDraw][yDraw+1] = newPixel
-				ui.frameBuffer[frameBufferID][xDraw+1][yDraw+1] = newPixel
-			}
-		}
-	}
-
-	return collision
-}
-
-func (ui *UI) resetFramebuffer(frameBufferID byte) {
-	for x := range WIDTH {
-		for y := range HEIGHT {
-			ui.frameBuffer[frameBufferID][x][y] = 0
-		}
+		return
 	}
-}
-
-func (ui *UI) scrollFrameBuffer(sd ScrollDirection, pixels int, frameBufferID byte) {
-	var tmpBuf [WIDTH][HEIGHT]byte
 
-	for x := range WIDTH {
-		for y := range HEIGHT {
-			newX, newY := ui.scrolledCoords(x, y, sd, pixels)
-			if newX < 0 || newY < 0 || newX >= WIDTH || newY >= HEIGHT {
-				continue
-			}
-
-			tmpBuf[newX][newY] = ui.frameBuffer[frameBufferID][x][y]
-		}
+	if err := ui.LoadStateChip8(slot); err != nil {
+		log.Printf("failed to load state from slot %d: %v", slot, err)
 	}
-
-	copy(ui.frameBuffer[frameBufferID][:][:], tmpBuf[:][:])
 }
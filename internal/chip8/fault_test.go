@@ -0,0 +1,38 @@
+package chip8_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cterence/chip8-go/internal/chip8"
+	"github.com/cterence/chip8-go/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeadlessFault(t *testing.T) {
+	rom := []byte{
+		0x00, 0xEE, // RET without CALL
+	}
+
+	run := func(options ...chip8.Option) error {
+		options = append(options, chip8.WithHeadless(true), chip8.WithAudioDisabled(true), chip8.WithExitAfter(100), chip8.WithDebuggerInput(strings.NewReader("")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := chip8.New(rom, options...).Run(ctx)
+		assert.NoError(t, ctx.Err(), "run did not stop on its own")
+
+		return err
+	}
+
+	assert.ErrorIs(t, run(chip8.WithFaultPolicy(chip8.FP_EXIT)), machine.ErrStackUnderflow)
+
+	// Paused in the debugger, whose input is closed
+	assert.ErrorIs(t, run(chip8.WithFaultPolicy(chip8.FP_PAUSE)), machine.ErrStackUnderflow)
+
+	// The faulting instruction is skipped until the tick limit
+	assert.NoError(t, run(chip8.WithFaultPolicy(chip8.FP_IGNORE)))
+}
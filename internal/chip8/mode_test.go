@@ -0,0 +1,68 @@
+package chip8_test
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/cterence/chip8-go/internal/romdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticAnalysis(t *testing.T) {
+	chip8ROM := []byte{
+		0x00, 0xE0, // 200 CLS
+		0x12, 0x02, // 202 JP 202
+	}
+
+	// XO-CHIP instruction on a branch never taken, runtime detection would
+	// never reach it
+	xoROM := []byte{
+		0x60, 0x00, // 200 LD V0, 00
+		0x30, 0x01, // 202 SE V0, 01
+		0x12, 0x0A, // 204 JP 20A
+		0xF0, 0x00, 0x02, 0x00, // 206 LD I, 0200
+		0x12, 0x0A, // 20A JP 20A
+	}
+
+	// HIRES is only reached through a computed jump
+	jumpROM := []byte{
+		0x60, 0x02, // 200 LD V0, 02
+		0xB2, 0x04, // 202 JP V0, 204
+		0x12, 0x04, // 204 JP 204
+		0x00, 0xFF, // 206 HIRES
+		0x12, 0x08, // 208 JP 208
+	}
+
+	sum := sha1.Sum(xoROM)
+
+	db, err := romdb.Parse(strings.NewReader(fmt.Sprintf(`[{"title": "Test", "roms": {"%x": {"platforms": ["superchip"]}}}]`, sum)))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		rom     []byte
+		options []chip8.Option
+		mode    lib.CompatibilityMode
+	}{
+		// The absence of later instructions is no evidence for CHIP-8
+		{name: "no evidence", rom: chip8ROM, mode: lib.CM_NONE},
+		{name: "unreached branch", rom: xoROM, mode: lib.CM_XOCHIP},
+		{name: "computed jump", rom: jumpROM, mode: lib.CM_NONE},
+		{name: "disabled", rom: xoROM, options: []chip8.Option{chip8.WithStaticAnalysis(false)}, mode: lib.CM_NONE},
+		{name: "forced", rom: xoROM, options: []chip8.Option{chip8.WithCompatibilityMode(lib.CM_CHIP8)}, mode: lib.CM_CHIP8},
+		// The database takes precedence over the analysis
+		{name: "database", rom: xoROM, options: []chip8.Option{chip8.WithROMDatabase(db)}, mode: lib.CM_SUPERCHIP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]chip8.Option{chip8.WithHeadless(true), chip8.WithROMDatabase(nil)}, tt.options...)
+
+			assert.Equal(t, tt.mode, chip8.New(tt.rom, options...).CompatibilityMode())
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package chip8
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/cterence/chip8-go/internal/movie"
+)
+
+// applyMovieHeader replaces the mode, quirks, IPF and random generator with
+// the ones the movie was recorded with, which the database and the static
+// analysis then leave alone. It returns an error naming the settings given as
+// options that differ.
+func (c8 *Chip8) applyMovieHeader(h movie.Header) error {
+	overrides, err := lib.ParseQuirkOverrides(h.Quirks)
+	if err != nil {
+		return fmt.Errorf("invalid input movie quirks: %w", err)
+	}
+
+	var mismatches []string
+
+	if c8.compatibilityMode != lib.CM_NONE && c8.compatibilityMode != h.Mode {
+		mismatches = append(mismatches, fmt.Sprintf("mode %s", h.Mode))
+	}
+
+	// Overrides matching the recorded quirks do not change them
+	if lib.FormatQuirkOverrides(slices.Concat(overrides, c8.quirkOverrides)) != h.Quirks {
+		mismatches = append(mismatches, fmt.Sprintf("quirks %q", h.Quirks))
+	}
+
+	if c8.ipf != 0 && c8.ipf != h.IPF {
+		mismatches = append(mismatches, fmt.Sprintf("ipf %d", h.IPF))
+	}
+
+	if c8.legacyRand && !h.LegacyRand {
+		mismatches = append(mismatches, "legacy-rand false")
+	}
+
+	c8.compatibilityMode, c8.quirkOverrides, c8.ipf, c8.legacyRand = h.Mode, overrides, h.IPF, h.LegacyRand
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("input movie was recorded with %s", strings.Join(mismatches, ", "))
+	}
+
+	return nil
+}
+
+func (c8 *Chip8) openInputRecording() (func(), error) {
+	f, err := os.Create(c8.recordInputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input movie file: %w", err)
+	}
+
+	c8.inputRecorder, err = movie.NewWriter(f, movie.Header{
+		Seed:       c8.seed,
+		ROMHash:    movie.HashROM(c8.romBytes),
+		Mode:       c8.compatibilityMode,
+		Quirks:     lib.FormatQuirkOverrides(c8.quirkOverrides),
+		IPF:        c8.ipf,
+		LegacyRand: c8.legacyRand,
+	})
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	log.Printf("recording input with seed %d", c8.seed)
+
+	return func() {
+		if err := c8.inputRecorder.Flush(); err != nil {
+			log.Printf("failed to write input movie: %v", err)
+		}
+
+		c8.inputRecorder = nil
+
+		f.Close()
+	}, nil
+}
+
+// keyChanged handles a key pressed or released on the keyboard, which is
+// ignored while an input movie is replayed.
+func (c8 *Chip8) keyChanged(key byte, pressed bool) {
+	if c8.inputMovie != nil {
+		return
+	}
+
+	c8.setKey(key, pressed)
+}
+
+func (c8 *Chip8) setKey(key byte, pressed bool) {
+	if c8.hw.Keypad.IsKeyPressed(key) == pressed {
+		return
+	}
+
+	c8.machine.SetKey(key, pressed)
+
+	if c8.inputRecorder == nil {
+		return
+	}
+
+	if err := c8.inputRecorder.Write(movie.Event{Tick: uint64(c8.machine.Ticks()), Key: key, Pressed: pressed}); err != nil {
+		log.Printf("failed to record input: %v", err)
+	}
+}
+
+// playInput applies the movie events due before the next instruction.
+func (c8 *Chip8) playInput() {
+	if c8.inputMovie == nil {
+		return
+	}
+
+	events := c8.inputMovie.Events
+
+	for c8.movieEvent < len(events) && events[c8.movieEvent].Tick <= uint64(c8.machine.Ticks()) {
+		e := events[c8.movieEvent]
+		c8.setKey(e.Key, e.Pressed)
+		c8.movieEvent++
+	}
+
+	if c8.movieEvent == len(events) {
+		log.Println("input movie finished, keyboard enabled")
+
+		c8.inputMovie = nil
+	}
+}
+
+// takeOver stops the input playback and hands the keypad back to the
+// keyboard.
+func (c8 *Chip8) takeOver() {
+	if c8.inputMovie == nil {
+		return
+	}
+
+	c8.inputMovie = nil
+
+	for key := range byte(16) {
+		c8.setKey(key, false)
+	}
+
+	log.Println("input playback stopped, keyboard enabled")
+}
+
+// canTravelInTime reports whether the machine can go back to an earlier
+// state. Resets, state loads and rewinds would desynchronise the ticks of an
+// input movie being recorded or replayed.
+func (c8 *Chip8) canTravelInTime() bool {
+	return c8.inputMovie == nil && c8.inputRecorder == nil
+}
+
+func (c8 *Chip8) reset() error {
+	if !c8.canTravelInTime() {
+		log.Println("reset is disabled while recording or replaying input")
+
+		return nil
+	}
+
+	return c8.init()
+}
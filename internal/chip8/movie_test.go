@@ -0,0 +1,122 @@
+package chip8_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/cterence/chip8-go/internal/movie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayInput(t *testing.T) {
+	// Wait for a key and draw its hex digit in the top left corner
+	rom := []byte{
+		0xF0, 0x0A, // LD V0, K
+		0xF0, 0x29, // LD F, V0
+		0x61, 0x00, // LD V1, 00
+		0xD1, 0x15, // DRW V1, V1, 5
+		0x12, 0x08, // JP 208
+	}
+
+	m := &movie.Movie{
+		Header: movie.Header{Seed: 1, ROMHash: movie.HashROM(rom)},
+		Events: []movie.Event{
+			{Tick: 5, Key: 0x7, Pressed: true},
+			{Tick: 10, Key: 0x7},
+		},
+	}
+
+	c8 := chip8.New(rom, chip8.WithHeadless(true), chip8.WithAudioDisabled(true), chip8.WithExitAfter(100), chip8.WithPlayInput(m))
+	assert.NoError(t, c8.Run(context.Background()))
+
+	// Font sprite of 7: F0 10 20 40 40, low resolution pixels are doubled
+	screen := c8.Screen()
+	assert.Equal(t, byte(1), screen[0][0][0])
+	assert.Equal(t, byte(1), screen[0][7][1])
+	assert.Equal(t, byte(0), screen[0][8][0])
+	assert.Equal(t, byte(0), screen[0][0][2])
+	assert.Equal(t, byte(1), screen[0][6][2])
+
+	m.ROMHash = movie.HashROM(rom[:2])
+	c8 = chip8.New(rom, chip8.WithHeadless(true), chip8.WithAudioDisabled(true), chip8.WithExitAfter(100), chip8.WithPlayInput(m))
+	assert.Error(t, c8.Run(context.Background()))
+}
+
+func TestPlayInputSettings(t *testing.T) {
+	// Draw the hex digit of 04 shifted right once, 02 with the VY shift quirk
+	rom := []byte{
+		0x60, 0x01, // LD V0, 01
+		0x61, 0x04, // LD V1, 04
+		0x80, 0x16, // SHR V0, V1
+		0xF0, 0x29, // LD F, V0
+		0x62, 0x00, // LD V2, 00
+		0xD2, 0x25, // DRW V2, V2, 5
+		0x12, 0x0C, // JP 20C
+	}
+
+	m := &movie.Movie{Header: movie.Header{Seed: 1, ROMHash: movie.HashROM(rom), Quirks: "shift=vy"}}
+
+	run := func(options ...chip8.Option) (*chip8.Chip8, error) {
+		options = append([]chip8.Option{
+			chip8.WithHeadless(true), chip8.WithAudioDisabled(true), chip8.WithExitAfter(10), chip8.WithPlayInput(m),
+		}, options...)
+
+		c8 := chip8.New(rom, options...)
+
+		return c8, c8.Run(context.Background())
+	}
+
+	c8, err := run()
+	require.NoError(t, err)
+
+	// Font sprite of 2: F0 10 F0 80 F0, the second row is empty on the left
+	assert.Equal(t, byte(1), c8.Screen()[0][0][0])
+	assert.Equal(t, byte(0), c8.Screen()[0][0][2])
+
+	vy, err := lib.ParseQuirkOverrides("shift=vy")
+	require.NoError(t, err)
+
+	_, err = run(chip8.WithQuirkOverrides(vy))
+	assert.NoError(t, err)
+
+	vx, err := lib.ParseQuirkOverrides("shift=vx")
+	require.NoError(t, err)
+
+	_, err = run(chip8.WithQuirkOverrides(vx))
+	assert.ErrorContains(t, err, `quirks "shift=vy"`)
+
+	_, err = run(chip8.WithCompatibilityMode(lib.CM_CHIP8), chip8.WithIPF(20), chip8.WithLegacyRand(true))
+	assert.ErrorContains(t, err, "mode none, ipf 0, legacy-rand false")
+}
+
+func TestRecordInputSettings(t *testing.T) {
+	rom := []byte{0x12, 0x00} // JP 200
+	file := filepath.Join(t.TempDir(), "run.movie")
+
+	overrides, err := lib.ParseQuirkOverrides("clip=off,shift=vy")
+	require.NoError(t, err)
+
+	c8 := chip8.New(rom,
+		chip8.WithHeadless(true), chip8.WithAudioDisabled(true), chip8.WithExitAfter(10), chip8.WithRecordInput(file),
+		chip8.WithCompatibilityMode(lib.CM_SUPERCHIP), chip8.WithQuirkOverrides(overrides), chip8.WithIPF(30), chip8.WithLegacyRand(true),
+	)
+	require.NoError(t, c8.Run(context.Background()))
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	m, err := movie.Read(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, lib.CM_SUPERCHIP, m.Mode)
+	assert.Equal(t, "shift=vy,clip=off", m.Quirks)
+	assert.Equal(t, 30, m.IPF)
+	assert.True(t, m.LegacyRand)
+}
@@ -0,0 +1,70 @@
+package chip8
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/recorder"
+)
+
+func (c8 *Chip8) toggleRecording() {
+	if c8.recorder != nil {
+		c8.stopRecording()
+
+		return
+	}
+
+	log.Println("recording started")
+
+	c8.recorder = recorder.New(c8.scale)
+}
+
+// stopRecording saves the current recording to the --record file the first
+// time, then to files named after the ROM.
+func (c8 *Chip8) stopRecording() {
+	if c8.recorder == nil {
+		return
+	}
+
+	path := c8.recordFile
+	if path == "" {
+		name, _ := strings.CutSuffix(filepath.Base(c8.romFileName), filepath.Ext(c8.romFileName))
+		path = fmt.Sprintf("%s-%s.gif", name, time.Now().Format("20060102150405"))
+	}
+
+	if err := c8.recorder.Save(path); err != nil {
+		log.Printf("failed to save recording: %v", err)
+	} else {
+		log.Printf("recording saved: %s (%d frames)", path, c8.recorder.Len())
+	}
+
+	c8.recorder = nil
+	c8.recordFile = ""
+}
+
+// saveScreenshot saves the window as a JPG, or the display as a PNG without
+// the SDL frontend or at native resolution.
+func (c8 *Chip8) saveScreenshot() {
+	if c8.ui != nil && !c8.nativeScreenshot {
+		c8.ui.Screenshot(c8.romFileName)
+
+		return
+	}
+
+	name, _ := strings.CutSuffix(filepath.Base(c8.romFileName), ".ch8")
+	path := fmt.Sprintf("%s-%s.png", name, time.Now().Format("20060102150405"))
+
+	log.Printf("saving screenshot: %s", path)
+
+	img := c8.hw.Display.Image(c8.scale)
+	if c8.nativeScreenshot {
+		img = c8.hw.Display.NativeImage()
+	}
+
+	if err := img.SavePNG(path); err != nil {
+		log.Fatalf("failed to save screenshot: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package chip8
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+// RegressionTest is a test suite ROM run headless for a fixed number of
+// ticks, whose final display is compared to a golden file.
+type RegressionTest struct {
+	// Name of the golden file, without extension
+	Name     string
+	ROM      string
+	TestFlag byte
+	Ticks    int
+	Mode     lib.CompatibilityMode
+}
+
+const (
+	TEST_SUITE_DIR = "sub/chip8-test-suite/bin"
+	GOLDEN_DIR     = "internal/chip8/testdata/golden"
+	GOLDEN_EXT     = ".txt"
+	GOLDEN_HEADER  = "sha256:"
+)
+
+// Same runs as the screenshots in results/
+var TimendusTests = []RegressionTest{
+	{Name: "1-chip8-logo", ROM: "1-chip8-logo.ch8", Ticks: 50},
+	{Name: "2-ibm-logo", ROM: "2-ibm-logo.ch8", Ticks: 50},
+	{Name: "3-corax+", ROM: "3-corax+.ch8", Ticks: 350},
+	{Name: "4-flags", ROM: "4-flags.ch8", Ticks: 1000},
+	{Name: "5-quirks-chip8", ROM: "5-quirks.ch8", TestFlag: 1, Ticks: 3000, Mode: lib.CM_CHIP8},
+	{Name: "5-quirks-super", ROM: "5-quirks.ch8", TestFlag: 2, Ticks: 3500, Mode: lib.CM_SUPERCHIP},
+	{Name: "5-quirks-xo", ROM: "5-quirks.ch8", TestFlag: 3, Ticks: 2000000, Mode: lib.CM_XOCHIP},
+	{Name: "8-scrolling-super-lores", ROM: "8-scrolling.ch8", TestFlag: 1, Ticks: 300},
+	{Name: "8-scrolling-super-hires", ROM: "8-scrolling.ch8", TestFlag: 3, Ticks: 300},
+	{Name: "8-scrolling-xo-lores", ROM: "8-scrolling.ch8", TestFlag: 4, Ticks: 2000000},
+	{Name: "8-scrolling-xo-hires", ROM: "8-scrolling.ch8", TestFlag: 5, Ticks: 2000000},
+}
+
+var ErrGoldenMismatch = errors.New("display does not match golden")
+
+// Screen is a snapshot of both display planes.
+type Screen [2][display.WIDTH][display.HEIGHT]byte
+
+// Run executes the test ROM found in romDir and returns its final display.
+func (rt RegressionTest) Run(ctx context.Context, romDir string) (Screen, error) {
+	romBytes, err := os.ReadFile(filepath.Join(romDir, rt.ROM))
+	if err != nil {
+		return Screen{}, fmt.Errorf("failed to read rom: %w", err)
+	}
+
+	c8 := New(romBytes,
+		WithHeadless(true),
+		WithAudioDisabled(true),
+		WithExitAfter(rt.Ticks),
+		WithTestFlag(rt.TestFlag),
+		WithCompatibilityMode(rt.Mode),
+		WithSeed(0),
+		// The goldens only depend on the test definition
+		WithROMDatabase(nil),
+		WithStaticAnalysis(false),
+	)
+
+	if err := c8.Run(ctx); err != nil {
+		return Screen{}, fmt.Errorf("failed to run %s: %w", rt.Name, err)
+	}
+
+	return c8.Screen(), nil
+}
+
+// Check runs the test and compares its display to the golden file in
+// goldenDir, or overwrites the golden file when update is set.
+func (rt RegressionTest) Check(ctx context.Context, romDir, goldenDir string, update bool) error {
+	screen, err := rt.Run(ctx, romDir)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(goldenDir, rt.Name+GOLDEN_EXT)
+
+	if update {
+		if err := os.MkdirAll(goldenDir, 0755); err != nil {
+			return fmt.Errorf("failed to create golden dir: %w", err)
+		}
+
+		if err := os.WriteFile(path, []byte(screen.Golden()), 0644); err != nil {
+			return fmt.Errorf("failed to write golden: %w", err)
+		}
+
+		return nil
+	}
+
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read golden: %w", err)
+	}
+
+	header, want, _ := strings.Cut(string(golden), "\n")
+	if header == GOLDEN_HEADER+screen.Hash() {
+		return nil
+	}
+
+	return fmt.Errorf("%w %s:\n%s", ErrGoldenMismatch, path, DiffScreens(want, screen.String()))
+}
+
+func (c8 *Chip8) Screen() Screen {
+	return c8.hw.Display.FrameBuffers()
+}
+
+// Hash returns the SHA-256 of both planes, column by column.
+func (s Screen) Hash() string {
+	h := sha256.New()
+
+	for p := range s {
+		for x := range s[p] {
+			h.Write(s[p][x][:])
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// String renders the display one character per pixel: '.' when off, '#' for
+// the first plane, '+' for the second and '@' for both.
+func (s Screen) String() string {
+	var sb strings.Builder
+
+	for y := range display.HEIGHT {
+		for x := range display.WIDTH {
+			sb.WriteByte(".#+@"[s[0][x][y]|s[1][x][y]<<1])
+		}
+
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+// Golden returns the content of a golden file: the hash on the first line,
+// then the rendered display.
+func (s Screen) Golden() string {
+	return GOLDEN_HEADER + s.Hash() + "\n" + s.String()
+}
+
+// DiffScreens lists the rows that differ between two rendered displays, with
+// a caret under each differing pixel.
+func DiffScreens(want, got string) string {
+	wantRows := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	gotRows := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	var sb strings.Builder
+
+	for y := range max(len(wantRows), len(gotRows)) {
+		var w, g string
+
+		if y < len(wantRows) {
+			w = wantRows[y]
+		}
+
+		if y < len(gotRows) {
+			g = gotRows[y]
+		}
+
+		if w == g {
+			continue
+		}
+
+		carets := make([]byte, max(len(w), len(g)))
+		for x := range carets {
+			carets[x] = ' '
+
+			if x >= len(w) || x >= len(g) || w[x] != g[x] {
+				carets[x] = '^'
+			}
+		}
+
+		fmt.Fprintf(&sb, "row %2d want %s\n       got  %s\n            %s\n", y, w, g, strings.TrimRight(string(carets), " "))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,50 @@
+package chip8_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "regenerate golden files")
+
+func TestTimendus(t *testing.T) {
+	romDir := filepath.Join("..", "..", chip8.TEST_SUITE_DIR)
+	goldenDir := filepath.Join("testdata", "golden")
+
+	if _, err := os.Stat(romDir); err != nil {
+		// CI checks the submodules out, a missing suite there is a failure
+		if os.Getenv("CI") != "" {
+			t.Fatalf("test suite not found: %v", err)
+		}
+
+		t.Skipf("test suite not found, run git submodule update --init: %v", err)
+	}
+
+	for _, rt := range chip8.TimendusTests {
+		t.Run(rt.Name, func(t *testing.T) {
+			assert.NoError(t, rt.Check(context.Background(), romDir, goldenDir, *update))
+		})
+	}
+}
+
+func TestDiffScreens(t *testing.T) {
+	var screen chip8.Screen
+
+	want := screen.String()
+
+	screen[0][1][2] = 1
+	screen[1][1][2] = 1
+	screen[1][3][2] = 1
+
+	got := screen.String()
+
+	assert.Equal(t, "row  2 want "+want[:128]+"\n       got  .@.+"+want[:124]+"\n             ^ ^\n", chip8.DiffScreens(want, got))
+	assert.Empty(t, chip8.DiffScreens(got, got))
+	assert.NotEqual(t, (chip8.Screen{}).Hash(), screen.Hash())
+}
@@ -0,0 +1,52 @@
+package chip8_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/cterence/chip8-go/internal/romdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestROMDatabase(t *testing.T) {
+	// Draw 0 when 8XY6 shifts VX in place, 1 when it shifts VY
+	rom := []byte{
+		0x60, 0x00, // LD V0, 00
+		0x61, 0x03, // LD V1, 03
+		0x80, 0x16, // SHR V0, V1
+		0xF0, 0x29, // LD F, V0
+		0x62, 0x00, // LD V2, 00
+		0xD2, 0x25, // DRW V2, V2, 5
+		0x12, 0x0C, // JP 20C
+	}
+
+	sum := sha1.Sum(rom)
+
+	db, err := romdb.Parse(strings.NewReader(fmt.Sprintf(`[{"title": "Test", "roms": {"%x": {"platforms": ["originalChip8"]}}}]`, sum)))
+	assert.NoError(t, err)
+
+	// The top left pixel is only set by 0
+	drewZero := func(options ...chip8.Option) bool {
+		options = append(options, chip8.WithHeadless(true), chip8.WithAudioDisabled(true), chip8.WithExitAfter(50))
+
+		c8 := chip8.New(rom, options...)
+		assert.NoError(t, c8.Run(context.Background()))
+
+		return c8.Screen()[0][0][0] == 1
+	}
+
+	assert.True(t, drewZero(chip8.WithROMDatabase(nil)))
+	assert.False(t, drewZero(chip8.WithROMDatabase(db)))
+
+	// Options override the database
+	shiftVX, err := lib.ParseQuirkOverrides("shift=vx")
+	assert.NoError(t, err)
+
+	assert.True(t, drewZero(chip8.WithROMDatabase(db), chip8.WithQuirkOverrides(shiftVX)))
+	assert.True(t, drewZero(chip8.WithROMDatabase(db), chip8.WithCompatibilityMode(lib.CM_SUPERCHIP)))
+}
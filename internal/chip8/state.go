@@ -0,0 +1,93 @@
+package chip8
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+// SaveState writes a versioned snapshot of the whole machine to w.
+func (c8 *Chip8) SaveState(w io.Writer) error {
+	return c8.machine.SaveState(w)
+}
+
+// LoadState restores a snapshot written by SaveState. The machine is left
+// untouched if the snapshot cannot be read.
+func (c8 *Chip8) LoadState(r io.Reader) error {
+	return c8.machine.LoadState(r)
+}
+
+func (c8 *Chip8) saveStateFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer f.Close()
+
+	if err := c8.SaveState(f); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func (c8 *Chip8) loadStateFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open state file: %w", err)
+	}
+	defer f.Close()
+
+	return c8.LoadState(f)
+}
+
+func (c8 *Chip8) stateSlotPath(slot int) (string, error) {
+	dataDir, err := lib.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data dir: %w", err)
+	}
+
+	romFileBaseName, _ := strings.CutSuffix(filepath.Base(c8.romFileName), ".ch8")
+
+	return filepath.Join(dataDir, romFileBaseName+"-slot"+strconv.Itoa(slot)+".state"), nil
+}
+
+func (c8 *Chip8) saveStateSlot(slot int) error {
+	path, err := c8.stateSlotPath(slot)
+	if err != nil {
+		return err
+	}
+
+	if err := c8.saveStateFile(path); err != nil {
+		return err
+	}
+
+	log.Printf("state saved to slot %d: %s", slot, path)
+
+	return nil
+}
+
+func (c8 *Chip8) loadStateSlot(slot int) error {
+	if !c8.canTravelInTime() {
+		return fmt.Errorf("loading a state is disabled while recording or replaying input")
+	}
+
+	path, err := c8.stateSlotPath(slot)
+	if err != nil {
+		return err
+	}
+
+	if err := c8.loadStateFile(path); err != nil {
+		return err
+	}
+
+	log.Printf("state loaded from slot %d: %s", slot, path)
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package disasm
+
+import (
+	"math"
+	"slices"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+// Report is the platform a ROM most likely targets, found from the
+// instructions reachable from the program start.
+type Report struct {
+	Mode lib.CompatibilityMode
+	// Between 0 and 1
+	Confidence float64
+	// Reached instructions that need SUPER-CHIP or XO-CHIP, by address
+	Evidence []Evidence
+	// Number of instructions reached
+	Instructions int
+	// Words never reached that decode as instructions of a later platform
+	// than Mode, which may be code the trace missed behind a computed jump
+	Unreached int
+}
+
+type Evidence struct {
+	Addr        uint16
+	Instruction cpu.Instruction
+}
+
+const (
+	// Confidence in CHIP-8 when nothing points to a later platform: the
+	// absence of an instruction is weaker evidence than its presence
+	CHIP8_CONFIDENCE = 0.9
+	// Share of the remaining doubt removed by each reached instruction
+	EVIDENCE_WEIGHT = 0.7
+	// Confidence kept for each suspicious unreached word
+	UNREACHED_PENALTY = 0.8
+)
+
+// Analyze guesses the platform a ROM was written for: XO-CHIP if any reached
+// instruction needs it, SUPER-CHIP likewise, CHIP-8 otherwise.
+func Analyze(rom []byte) Report {
+	p := Disassemble(rom, lib.CM_NONE)
+
+	r := Report{
+		Mode:         lib.CM_CHIP8,
+		Instructions: len(p.code),
+	}
+
+	for addr, inst := range p.code {
+		mode := platform(inst)
+		if mode == lib.CM_CHIP8 {
+			continue
+		}
+
+		r.Evidence = append(r.Evidence, Evidence{Addr: addr, Instruction: inst})
+		r.Mode = max(r.Mode, mode)
+	}
+
+	slices.SortFunc(r.Evidence, func(a, b Evidence) int { return int(a.Addr) - int(b.Addr) })
+
+	r.Confidence = CHIP8_CONFIDENCE
+
+	if r.Mode != lib.CM_CHIP8 {
+		n := 0
+
+		for _, e := range r.Evidence {
+			if platform(e.Instruction) == r.Mode {
+				n++
+			}
+		}
+
+		r.Confidence = 1 - math.Pow(1-EVIDENCE_WEIGHT, float64(n))
+	}
+
+	r.Unreached = p.unreached(r.Mode)
+	r.Confidence *= math.Pow(UNREACHED_PENALTY, float64(r.Unreached))
+
+	return r
+}
+
+// Conclusive reports whether the mode is based on instructions found. A
+// CHIP-8 report only means that none was reached.
+func (r Report) Conclusive() bool {
+	return len(r.Evidence) > 0
+}
+
+// platform returns the first platform that supports the instruction. DXY0
+// draws a 16x16 sprite since SUPER-CHIP.
+func platform(inst cpu.Instruction) lib.CompatibilityMode {
+	if inst.Op == cpu.OP_DRW && inst.N() == 0 {
+		return lib.CM_SUPERCHIP
+	}
+
+	return inst.Platform
+}
+
+// unreached counts the words aligned with the program start that were not
+// reached and decode as instructions of a later platform than mode.
+func (p *Program) unreached(mode lib.CompatibilityMode) int {
+	covered := make(map[uint16]bool)
+
+	for addr, inst := range p.code {
+		for i := range inst.Size {
+			covered[addr+i] = true
+		}
+	}
+
+	n := 0
+
+	for addr := memory.PROGRAM_RAM_START; int(addr)+1 < p.end(); addr += 2 {
+		if covered[addr] || covered[addr+1] {
+			continue
+		}
+
+		if inst, ok := p.decode(addr); ok && platform(inst) > mode {
+			n++
+		}
+	}
+
+	return n
+}
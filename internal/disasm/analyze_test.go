@@ -0,0 +1,97 @@
+package disasm_test
+
+import (
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/disasm"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name       string
+		rom        []byte
+		mode       lib.CompatibilityMode
+		confidence float64
+		evidence   []uint16
+		unreached  int
+	}{
+		{
+			name: "chip8",
+			rom: []byte{
+				0x00, 0xE0, // 200 CLS
+				0xD0, 0x15, // 202 DRW V0, V1, 5
+				0x12, 0x04, // 204 JP 204
+			},
+			mode:       lib.CM_CHIP8,
+			confidence: 0.9,
+		},
+		{
+			name: "superchip",
+			rom: []byte{
+				0x00, 0xFF, // 200 HIRES
+				0x22, 0x06, // 202 CALL 206
+				0x12, 0x04, // 204 JP 204
+				0xD0, 0x10, // 206 DRW V0, V1, 0
+				0x00, 0xEE, // 208 RET
+			},
+			mode:       lib.CM_SUPERCHIP,
+			confidence: 0.91,
+			evidence:   []uint16{0x200, 0x206},
+		},
+		{
+			name: "xochip",
+			rom: []byte{
+				0x00, 0xFF, // 200 HIRES
+				0xF0, 0x00, 0x02, 0x08, // 202 LD I, 0208
+				0x12, 0x06, // 206 JP 206
+			},
+			mode:       lib.CM_XOCHIP,
+			confidence: 0.7,
+			evidence:   []uint16{0x200, 0x202},
+		},
+		{
+			name: "unreached",
+			rom: []byte{
+				0x12, 0x00, // 200 JP 200
+				0x00, 0xFB, // 202 data
+				0x00, 0xFF, // 204 data
+			},
+			mode:       lib.CM_CHIP8,
+			confidence: 0.9 * 0.8 * 0.8,
+			unreached:  2,
+		},
+		{
+			name: "computed jump",
+			rom: []byte{
+				0x60, 0x02, // 200 LD V0, 02
+				0xB2, 0x04, // 202 JP V0, 204
+				0x12, 0x04, // 204 JP 204
+				0x00, 0xFF, // 206 HIRES, only reached through the computed jump
+				0x12, 0x08, // 208 JP 208
+			},
+			mode:       lib.CM_CHIP8,
+			confidence: 0.9 * 0.8,
+			unreached:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := disasm.Analyze(tt.rom)
+
+			assert.Equal(t, tt.mode, r.Mode)
+			assert.InDelta(t, tt.confidence, r.Confidence, 1e-9)
+			assert.Equal(t, tt.unreached, r.Unreached)
+			assert.Equal(t, tt.evidence != nil, r.Conclusive())
+
+			var evidence []uint16
+			for _, e := range r.Evidence {
+				evidence = append(evidence, e.Addr)
+			}
+
+			assert.Equal(t, tt.evidence, evidence)
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package disasm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+// Program is a ROM split into reachable instructions and data.
+type Program struct {
+	rom    []byte
+	mode   lib.CompatibilityMode
+	code   map[uint16]cpu.Instruction
+	labels map[uint16]string
+}
+
+const (
+	DATA_BYTES_PER_LINE = 8
+)
+
+// Disassemble traces the control flow of a ROM from the program start.
+// Bytes that are never reached are considered data.
+func Disassemble(rom []byte, mode lib.CompatibilityMode) *Program {
+	p := &Program{
+		rom:    rom,
+		mode:   mode,
+		code:   make(map[uint16]cpu.Instruction),
+		labels: make(map[uint16]string),
+	}
+
+	p.trace(memory.PROGRAM_RAM_START)
+
+	return p
+}
+
+// Instruction returns the instruction starting at addr, if addr was reached
+// while tracing.
+func (p *Program) Instruction(addr uint16) (cpu.Instruction, bool) {
+	inst, ok := p.code[addr]
+
+	return inst, ok
+}
+
+// Label returns the name generated for addr, or an empty string.
+func (p *Program) Label(addr uint16) string {
+	return p.labels[addr]
+}
+
+func (p *Program) end() int {
+	return int(memory.PROGRAM_RAM_START) + len(p.rom)
+}
+
+func (p *Program) word(addr uint16) (uint16, bool) {
+	i := int(addr) - int(memory.PROGRAM_RAM_START)
+	if i < 0 || i+1 >= len(p.rom) {
+		return 0, false
+	}
+
+	return uint16(p.rom[i])<<lib.BYTE_SIZE | uint16(p.rom[i+1]), true
+}
+
+// decode returns the instruction at addr, including the second word of a long
+// load, or false if it does not fit in the ROM or is not a valid opcode.
+func (p *Program) decode(addr uint16) (cpu.Instruction, bool) {
+	word, ok := p.word(addr)
+	if !ok {
+		return cpu.Instruction{}, false
+	}
+
+	inst := cpu.Disassemble(word, p.mode)
+	if inst.Op == cpu.OP_UNKNOWN {
+		return inst, false
+	}
+
+	if inst.Size == 4 {
+		inst.Addr, ok = p.word(addr + 2)
+	}
+
+	return inst, ok
+}
+
+func (p *Program) trace(start uint16) {
+	pending := []uint16{start}
+
+	for len(pending) > 0 {
+		addr := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if _, visited := p.code[addr]; visited {
+			continue
+		}
+
+		inst, ok := p.decode(addr)
+		if !ok {
+			continue
+		}
+
+		p.code[addr] = inst
+		next := addr + inst.Size
+
+		switch inst.Op {
+		case cpu.OP_RET, cpu.OP_EXIT:
+		case cpu.OP_JP:
+			p.addLabel(inst.NNN(), "L")
+			pending = append(pending, inst.NNN())
+		case cpu.OP_CALL:
+			p.addLabel(inst.NNN(), "S")
+			pending = append(pending, next, inst.NNN())
+		case cpu.OP_JP_V0:
+			// The destination depends on a register, but the base address
+			// usually is a jump table
+			p.addLabel(inst.NNN(), "T")
+			pending = append(pending, inst.NNN())
+		case cpu.OP_SE_VX_NN, cpu.OP_SNE_VX_NN, cpu.OP_SE_VX_VY, cpu.OP_SNE_VX_VY, cpu.OP_SKP, cpu.OP_SKNP:
+			pending = append(pending, next)
+
+			if skipped, ok := p.decode(next); ok {
+				pending = append(pending, next+skipped.Size)
+			} else {
+				pending = append(pending, next+2)
+			}
+		default:
+			pending = append(pending, next)
+		}
+	}
+}
+
+// addLabel names a branch target. Subroutines take precedence over jump
+// targets so that an entry point jumped into keeps its subroutine name.
+func (p *Program) addLabel(addr uint16, prefix string) {
+	if l, ok := p.labels[addr]; ok && (strings.HasPrefix(l, "S") || prefix != "S") {
+		return
+	}
+
+	p.labels[addr] = prefix + lib.FormatHex(addr, 3)
+}
+
+// WriteTo writes the listing of the program, one instruction or row of data
+// bytes per line preceded by its address and raw bytes.
+func (p *Program) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+
+	addr := int(memory.PROGRAM_RAM_START)
+
+	for addr < p.end() {
+		a := uint16(addr)
+
+		if l := p.labels[a]; l != "" {
+			fmt.Fprintf(&sb, "%s:\n", l)
+		}
+
+		if inst, ok := p.code[a]; ok {
+			raw := lib.FormatHex(inst.Opcode, 4)
+			if inst.Size == 4 {
+				raw += " " + lib.FormatHex(inst.Addr, 4)
+			}
+
+			fmt.Fprintf(&sb, "    %s  %-9s  %s\n", lib.FormatHex(a, 3), raw, inst.Format(p.Label))
+
+			addr += int(inst.Size)
+
+			continue
+		}
+
+		n := p.dataLen(a)
+		data := p.rom[addr-int(memory.PROGRAM_RAM_START) : addr-int(memory.PROGRAM_RAM_START)+n]
+
+		hex := make([]string, len(data))
+		for i, b := range data {
+			hex[i] = lib.FormatHex(b, 2)
+		}
+
+		fmt.Fprintf(&sb, "    %s  %-9s  DB %s\n", lib.FormatHex(a, 3), "data", strings.Join(hex, " "))
+
+		addr += n
+	}
+
+	n, err := io.WriteString(w, sb.String())
+
+	return int64(n), err
+}
+
+// dataLen returns the number of data bytes starting at addr to put on one
+// line, stopping before code, labels and the end of the ROM.
+func (p *Program) dataLen(addr uint16) int {
+	n := 1
+
+	for n < DATA_BYTES_PER_LINE && int(addr)+n < p.end() {
+		a := addr + uint16(n)
+
+		if _, ok := p.code[a]; ok {
+			break
+		}
+
+		if p.labels[a] != "" {
+			break
+		}
+
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,53 @@
+package disasm_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/disasm"
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisassemble(t *testing.T) {
+	rom := []byte{
+		0x00, 0xE0, // 200 CLS
+		0xA2, 0x10, // 202 LD I, 210
+		0x22, 0x0C, // 204 CALL 20C
+		0x3A, 0x05, // 206 SE VA, 05
+		0xF0, 0x00, 0x12, 0x34, // 208 LD I, 1234
+		0x12, 0x08, // 20C JP 208
+		0x00, 0xEE, // 20E unreachable
+	}
+
+	p := disasm.Disassemble(rom, lib.CM_XOCHIP)
+
+	inst, ok := p.Instruction(0x208)
+	assert.True(t, ok)
+	assert.Equal(t, cpu.OP_LD_I_LONG, inst.Op)
+	assert.Equal(t, uint16(0x1234), inst.Addr)
+	assert.Equal(t, "LD I, 1234", inst.String())
+
+	_, ok = p.Instruction(0x20A)
+	assert.False(t, ok, "second word of a long load is not an instruction")
+
+	_, ok = p.Instruction(0x20E)
+	assert.False(t, ok, "unreachable bytes are data")
+
+	assert.Equal(t, "S20C", p.Label(0x20C))
+	assert.Equal(t, "L208", p.Label(0x208))
+
+	var sb strings.Builder
+
+	_, err := p.WriteTo(&sb)
+	assert.NoError(t, err)
+	assert.Contains(t, sb.String(), "CALL S20C\n")
+	assert.Contains(t, sb.String(), "20E  data       DB 00 EE\n")
+}
+
+func TestDisassembleJumpQuirk(t *testing.T) {
+	assert.Equal(t, "JP V0, 234", cpu.Disassemble(0xB234, lib.CM_CHIP8).String())
+	assert.Equal(t, "JP V2, 234", cpu.Disassemble(0xB234, lib.CM_SUPERCHIP).String())
+	assert.Equal(t, "DW 0123", cpu.Disassemble(0x0123, lib.CM_CHIP8).String())
+}
@@ -0,0 +1,23 @@
+// Package hardware implements the interpreter exposed by the machine package.
+// The frontends of this module use it directly, as they need more than the
+// public API: the debugger reads and writes the CPU and the memory, the
+// windowed and terminal frontends draw the display.
+package hardware
+
+import (
+	"github.com/cterence/chip8-go/internal/chip8/components/apu"
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/chip8/components/keypad"
+	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+	"github.com/cterence/chip8-go/internal/chip8/components/timer"
+)
+
+type Components struct {
+	CPU     *cpu.CPU
+	Memory  *memory.Memory
+	Display *display.Display
+	Keypad  *keypad.Keypad
+	Timer   *timer.Timer
+	APU     *apu.APU
+}
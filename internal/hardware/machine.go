@@ -0,0 +1,332 @@
+package hardware
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand/v2"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/apu"
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/chip8/components/keypad"
+	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+	"github.com/cterence/chip8-go/internal/chip8/components/timer"
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+// Machine implements machine.Machine, the frontends of this module also use
+// its components.
+type Machine struct {
+	hw Components
+
+	rom        []byte
+	testFlag   byte
+	ipf        int
+	currentIPF int
+	paused     bool
+	samples    []byte
+	palette    color.Palette
+
+	cpuOptions []cpu.Option
+	onExit     func()
+}
+
+// Registers is a copy of the CPU registers and timers.
+type Registers struct {
+	V  [16]byte
+	PC uint16
+	I  uint16
+	SP uint8
+	// Return addresses currently pushed, oldest first
+	Stack []uint16
+	Delay byte
+	Sound byte
+}
+
+type Option func(*Machine)
+
+const (
+	KEY_COUNT = 16
+
+	// Samples kept when AudioSamples is not called, older ones are dropped
+	MAX_BUFFERED_SAMPLES = apu.SAMPLE_RATE
+)
+
+// New returns a machine with an empty program memory, ready for LoadROM.
+func New(options ...Option) *Machine {
+	m := &Machine{}
+
+	for _, o := range options {
+		o(m)
+	}
+
+	if m.onExit == nil {
+		m.onExit = func() { m.paused = !m.paused }
+	}
+
+	a := apu.New()
+	a.SetOutput(samplesWriter{m})
+
+	m.hw.Memory = memory.New()
+	m.hw.Display = display.New()
+	m.hw.Display.SetPalette(m.palette)
+	m.hw.Keypad = keypad.New()
+	m.hw.APU = a
+	m.hw.Timer = timer.New(a)
+	m.hw.CPU = cpu.New(m.hw.Memory, m.hw.Display, m.hw.Keypad, m.hw.Timer, a, m.cpuOptions...)
+	m.hw.CPU.SetCurrentIPF = func(ipf int) { m.currentIPF = ipf }
+	m.hw.CPU.TogglePauseChip8 = m.onExit
+
+	m.Reset()
+
+	return m
+}
+
+// WithCompatibilityMode forces a compatibility mode. With CM_NONE, the mode is
+// detected from the instructions executed.
+func WithCompatibilityMode(mode lib.CompatibilityMode) Option {
+	return func(m *Machine) {
+		m.cpuOptions = append(m.cpuOptions, cpu.WithCompatibilityMode(mode))
+	}
+}
+
+func WithQuirkOverrides(overrides []lib.QuirkOverride) Option {
+	return func(m *Machine) {
+		m.cpuOptions = append(m.cpuOptions, cpu.WithQuirkOverrides(overrides))
+	}
+}
+
+// WithSeed seeds the random number generator used by CXNN, making runs
+// reproducible.
+func WithSeed(seed uint64) Option {
+	return func(m *Machine) {
+		m.cpuOptions = append(m.cpuOptions, cpu.WithRandSource(rand.NewPCG(seed, seed)))
+	}
+}
+
+func WithLegacyRand(legacyRand bool) Option {
+	return func(m *Machine) {
+		m.cpuOptions = append(m.cpuOptions, cpu.WithLegacyRand(legacyRand))
+	}
+}
+
+// WithRomFileName names the file the SUPER-CHIP flag registers are persisted
+// to.
+func WithRomFileName(romFileName string) Option {
+	return func(m *Machine) {
+		m.cpuOptions = append(m.cpuOptions, cpu.WithRomFileName(romFileName))
+	}
+}
+
+// WithIPF sets the number of instructions executed per frame, overriding the
+// compatibility mode default when greater than 0.
+func WithIPF(ipf int) Option {
+	return func(m *Machine) {
+		m.ipf = ipf
+	}
+}
+
+// WithPalette sets the colors of the framebuffer, indexed like its pixels.
+// Missing colors keep their default.
+func WithPalette(palette color.Palette) Option {
+	return func(m *Machine) {
+		m.palette = palette
+	}
+}
+
+// WithTestFlag writes testFlag at 0x1FF on reset, which the Timendus test
+// suite reads to select a test without a menu.
+func WithTestFlag(testFlag byte) Option {
+	return func(m *Machine) {
+		m.testFlag = testFlag
+	}
+}
+
+// WithExitHandler calls onExit when the ROM executes 00FD instead of toggling
+// the pause.
+func WithExitHandler(onExit func()) Option {
+	return func(m *Machine) {
+		m.onExit = onExit
+	}
+}
+
+// LoadROM copies rom to the program memory and resets the machine.
+func (m *Machine) LoadROM(rom []byte) error {
+	if len(rom) > int(memory.PROGRAM_RAM_SIZE) {
+		return fmt.Errorf("rom file size %d is bigger than chip8 program ram %d", len(rom), memory.PROGRAM_RAM_SIZE)
+	}
+
+	m.rom = rom
+	m.Reset()
+
+	return nil
+}
+
+// Reset restarts the loaded ROM from a cleared machine.
+func (m *Machine) Reset() {
+	m.paused = false
+	m.samples = m.samples[:0]
+
+	m.hw.Memory.Init()
+	m.hw.CPU.Init()
+	m.hw.Timer.Init()
+	m.hw.APU.Init()
+	m.hw.Display.Init()
+	m.hw.Keypad.Init()
+
+	if m.testFlag != 0 {
+		m.hw.Memory.Poke(0x1FF, m.testFlag)
+	}
+
+	for i, b := range m.rom {
+		m.hw.Memory.Poke(uint16(i)+memory.PROGRAM_RAM_START, b)
+	}
+}
+
+// Step executes one instruction, whether or not the machine is paused. Invalid
+// instructions are not executed and return a *cpu.Fault.
+func (m *Machine) Step() error {
+	return m.hw.CPU.Tick()
+}
+
+// Skip moves past the instruction at PC without executing it, to carry on
+// after a fault.
+func (m *Machine) Skip() {
+	m.hw.CPU.Skip()
+}
+
+// RunFrame runs one 60 Hz frame: up to IPF instructions, fewer when the CPU
+// waits for the vertical blank or faults, then EndFrame. Paused machines do
+// not run.
+func (m *Machine) RunFrame() error {
+	if m.paused {
+		return nil
+	}
+
+	var err error
+
+	for range m.IPF() {
+		if m.paused || m.WaitingForVBlank() {
+			break
+		}
+
+		if err = m.Step(); err != nil {
+			break
+		}
+	}
+
+	m.EndFrame()
+
+	return err
+}
+
+// EndFrame ticks the timers, which generates the frame audio samples, and
+// signals the vertical blank. Frontends stepping through a frame themselves
+// call it once per frame.
+func (m *Machine) EndFrame() {
+	m.hw.Timer.Tick()
+	m.hw.CPU.VBlank()
+}
+
+// IPF returns the number of instructions executed per frame.
+func (m *Machine) IPF() int {
+	if m.ipf > 0 {
+		return m.ipf
+	}
+
+	return m.currentIPF
+}
+
+// WaitingForVBlank reports whether the CPU is stalled after a draw until the
+// end of the frame (display wait quirk).
+func (m *Machine) WaitingForVBlank() bool {
+	return m.hw.CPU.WaitingForVBlank()
+}
+
+// Ticks returns the number of instructions executed since the last reset.
+func (m *Machine) Ticks() int {
+	return m.hw.CPU.Ticks()
+}
+
+func (m *Machine) Paused() bool {
+	return m.paused
+}
+
+func (m *Machine) SetPaused(paused bool) {
+	m.paused = paused
+}
+
+// SetKey presses or releases key 0x0 to 0xF of the keypad.
+func (m *Machine) SetKey(key byte, down bool) {
+	m.hw.Keypad.SetKey(key&(KEY_COUNT-1), down)
+}
+
+// Framebuffer returns a display.WIDTH x display.HEIGHT snapshot of the display, low resolution
+// pixels being doubled. The palette index of each pixel has bit 0 set by the
+// first plane and bit 1 by the second.
+func (m *Machine) Framebuffer() *image.Paletted {
+	return m.hw.Display.Paletted()
+}
+
+// HiRes reports whether the display is in high resolution.
+func (m *Machine) HiRes() bool {
+	return m.hw.Display.Res() == 1
+}
+
+func (m *Machine) Registers() Registers {
+	c, t := m.hw.CPU, m.hw.Timer
+
+	r := Registers{
+		PC:    c.PC(),
+		I:     c.I(),
+		SP:    c.SP(),
+		Stack: append([]uint16(nil), c.Stack()...),
+		Delay: t.GetDelay(),
+		Sound: t.GetSound(),
+	}
+
+	for i := range r.V {
+		r.V[i] = c.Register(byte(i))
+	}
+
+	return r
+}
+
+// Components returns the components of the machine.
+func (m *Machine) Components() *Components {
+	return &m.hw
+}
+
+// Memory returns a copy of the whole address space.
+func (m *Machine) Memory() []byte {
+	s := m.hw.Memory.SaveState()
+
+	return s.RAM[:]
+}
+
+// AudioSamples returns the samples generated since the previous call, one
+// frame of samples per frame with silence included, and at most
+// MAX_BUFFERED_SAMPLES.
+func (m *Machine) AudioSamples() []byte {
+	samples := append([]byte(nil), m.samples...)
+	m.samples = m.samples[:0]
+
+	return samples
+}
+
+// samplesWriter buffers the APU output for AudioSamples.
+type samplesWriter struct {
+	m *Machine
+}
+
+func (w samplesWriter) Write(samples []byte) (int, error) {
+	m := w.m
+	m.samples = append(m.samples, samples...)
+
+	if extra := len(m.samples) - MAX_BUFFERED_SAMPLES; extra > 0 {
+		m.samples = m.samples[:copy(m.samples, m.samples[extra:])]
+	}
+
+	return len(samples), nil
+}
@@ -0,0 +1,108 @@
+package hardware
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/apu"
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+	"github.com/cterence/chip8-go/internal/chip8/components/timer"
+)
+
+const (
+	STATE_MAGIC   = "C8GS"
+	STATE_VERSION = 3
+)
+
+var ErrInvalidState = errors.New("invalid save state")
+
+type stateHeader struct {
+	Magic   [4]byte
+	Version uint16
+}
+
+type state struct {
+	CPU     cpu.State
+	Memory  memory.State
+	Timer   timer.State
+	Display display.State
+	APU     apu.State
+}
+
+// SaveState writes a versioned snapshot of the whole machine to w.
+func (m *Machine) SaveState(w io.Writer) error {
+	header := stateHeader{Version: STATE_VERSION}
+	copy(header.Magic[:], STATE_MAGIC)
+
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("failed to write state header: %w", err)
+	}
+
+	s := m.saveState()
+
+	if err := binary.Write(w, binary.LittleEndian, s); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+
+	return nil
+}
+
+// LoadState restores a snapshot written by SaveState. The machine is left
+// untouched if the snapshot cannot be read or holds an invalid state.
+func (m *Machine) LoadState(r io.Reader) error {
+	var header stateHeader
+
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("failed to read state header: %w", err)
+	}
+
+	if string(header.Magic[:]) != STATE_MAGIC {
+		return fmt.Errorf("%w: bad magic %q", ErrInvalidState, header.Magic[:])
+	}
+
+	if header.Version != STATE_VERSION {
+		return fmt.Errorf("%w: unsupported version %d, expected %d", ErrInvalidState, header.Version, STATE_VERSION)
+	}
+
+	s := &state{}
+
+	if err := binary.Read(r, binary.LittleEndian, s); err != nil {
+		return fmt.Errorf("failed to read state: %w", err)
+	}
+
+	return m.loadState(s)
+}
+
+func (m *Machine) saveState() *state {
+	return &state{
+		CPU:     m.hw.CPU.SaveState(),
+		Memory:  m.hw.Memory.SaveState(),
+		Timer:   m.hw.Timer.SaveState(),
+		Display: m.hw.Display.SaveState(),
+		APU:     m.hw.APU.SaveState(),
+	}
+}
+
+func (m *Machine) loadState(s *state) error {
+	// Nothing is restored until every component accepts its state
+	for _, err := range []error{s.CPU.Validate(), s.Display.Validate(), s.APU.Validate()} {
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidState, err)
+		}
+	}
+
+	if err := m.hw.CPU.LoadState(s.CPU); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+
+	m.hw.Memory.LoadState(s.Memory)
+	m.hw.Timer.LoadState(s.Timer)
+	m.hw.Display.LoadState(s.Display)
+	m.hw.APU.LoadState(s.APU)
+
+	return nil
+}
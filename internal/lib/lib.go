@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strconv"
 
 	"golang.org/x/exp/constraints"
@@ -22,6 +23,33 @@ const (
 	CM_XOCHIP
 )
 
+func ParseCompatibilityMode(mode string) (CompatibilityMode, error) {
+	switch mode {
+	case "chip8":
+		return CM_CHIP8, nil
+	case "super":
+		return CM_SUPERCHIP, nil
+	case "xo":
+		return CM_XOCHIP, nil
+	default:
+		return CM_NONE, fmt.Errorf("unknown compatibility mode: %s", mode)
+	}
+}
+
+// String returns the name ParseCompatibilityMode accepts.
+func (m CompatibilityMode) String() string {
+	switch m {
+	case CM_CHIP8:
+		return "chip8"
+	case CM_SUPERCHIP:
+		return "super"
+	case CM_XOCHIP:
+		return "xo"
+	default:
+		return "none"
+	}
+}
+
 func Assert(condition bool, errorMsg error) {
 	if !condition {
 		panic("assertion failed: " + errorMsg.Error())
@@ -63,6 +91,23 @@ func SetLogger(logLevel string) {
 	slog.SetDefault(logger)
 }
 
+// DataDir returns the directory where persistent interpreter data (flags,
+// save states) is stored, creating it if needed.
+func DataDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	dataDir := filepath.Join(homeDir, ".local/share/chip8-go")
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", err
+	}
+
+	return dataDir, nil
+}
+
 func FormatHex[T constraints.Unsigned](v T, length int) string {
 	switch length {
 	case 1:
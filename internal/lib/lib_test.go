@@ -45,4 +45,13 @@ func Test(t *testing.T) {
 		assert.Equal(t, byte(0b00010010), lib.ResetBit(b, 7))
 		assert.Panics(t, func() { lib.ResetBit(b, 8) })
 	})
+	t.Run("CompatibilityMode", func(t *testing.T) {
+		for _, mode := range []lib.CompatibilityMode{lib.CM_CHIP8, lib.CM_SUPERCHIP, lib.CM_XOCHIP} {
+			parsed, err := lib.ParseCompatibilityMode(mode.String())
+			assert.NoError(t, err)
+			assert.Equal(t, mode, parsed)
+		}
+
+		assert.Equal(t, "none", lib.CM_NONE.String())
+	})
 }
@@ -0,0 +1,206 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Quirks holds the behaviors that differ between CHIP-8 platforms.
+type Quirks struct {
+	// 8XY1, 8XY2 and 8XY3 reset VF to 0
+	VFReset bool
+	// 8XY6 and 8XYE shift VY into VX instead of shifting VX in place
+	ShiftVY bool
+	// FX55 and FX65 leave I pointing after the last register stored or loaded
+	MemoryIncrementI bool
+	// BXNN jumps to XNN + VX instead of BNNN jumping to NNN + V0
+	JumpVX bool
+	// Sprites are clipped at the screen edges instead of wrapping around
+	Clip bool
+	// DXYN waits for the next vertical blank (60 Hz timer tick) before the
+	// next instruction runs
+	DisplayWait bool
+}
+
+// QuirkOverride changes one or more quirks on top of a preset.
+type QuirkOverride func(*Quirks)
+
+var QuirkPresets = map[string]Quirks{
+	"chip8": {
+		VFReset:          true,
+		ShiftVY:          true,
+		MemoryIncrementI: true,
+		Clip:             true,
+		DisplayWait:      true,
+	},
+	"schip-legacy": {
+		JumpVX:      true,
+		Clip:        true,
+		DisplayWait: true,
+	},
+	"schip-modern": {
+		JumpVX: true,
+		Clip:   true,
+	},
+	"xo-chip": {
+		ShiftVY:          true,
+		MemoryIncrementI: true,
+	},
+}
+
+// DefaultQuirks returns the quirk preset matching a compatibility mode.
+func DefaultQuirks(mode CompatibilityMode) Quirks {
+	switch mode {
+	case CM_CHIP8:
+		return QuirkPresets["chip8"]
+	case CM_XOCHIP:
+		return QuirkPresets["xo-chip"]
+	default:
+		return QuirkPresets["schip-modern"]
+	}
+}
+
+func (q Quirks) With(overrides []QuirkOverride) Quirks {
+	for _, o := range overrides {
+		o(&q)
+	}
+
+	return q
+}
+
+// ParseQuirkOverrides parses a comma separated list of quirk overrides, for
+// example "shift=vy,jump=bxnn,clip=on". A bare preset name replaces every
+// quirk with the preset values.
+func ParseQuirkOverrides(spec string) ([]QuirkOverride, error) {
+	var overrides []QuirkOverride
+
+	for item := range strings.SplitSeq(spec, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(item, "=")
+		if !found {
+			preset, ok := QuirkPresets[key]
+			if !ok {
+				return nil, fmt.Errorf("unknown quirk preset %q, expected one of %s", key, strings.Join(quirkPresetNames(), ", "))
+			}
+
+			overrides = append(overrides, func(q *Quirks) { *q = preset })
+
+			continue
+		}
+
+		o, err := parseQuirkOverride(key, value)
+		if err != nil {
+			return nil, err
+		}
+
+		overrides = append(overrides, o)
+	}
+
+	return overrides, nil
+}
+
+// FormatQuirkOverrides returns the quirks set by overrides as a spec for
+// ParseQuirkOverrides, each quirk once in a fixed order, so that equivalent
+// overrides are formatted the same.
+func FormatQuirkOverrides(overrides []QuirkOverride) string {
+	// Quirks set by the overrides have the same value from both sides
+	off := Quirks{}.With(overrides)
+	on := Quirks{VFReset: true, ShiftVY: true, MemoryIncrementI: true, JumpVX: true, Clip: true, DisplayWait: true}.With(overrides)
+
+	switches := [2]string{"off", "on"}
+
+	var items []string
+
+	for _, q := range []struct {
+		key     string
+		off, on bool
+		values  [2]string
+	}{
+		{"vfreset", off.VFReset, on.VFReset, switches},
+		{"shift", off.ShiftVY, on.ShiftVY, [2]string{"vx", "vy"}},
+		{"memory", off.MemoryIncrementI, on.MemoryIncrementI, switches},
+		{"jump", off.JumpVX, on.JumpVX, [2]string{"bnnn", "bxnn"}},
+		{"clip", off.Clip, on.Clip, switches},
+		{"vblank", off.DisplayWait, on.DisplayWait, switches},
+	} {
+		if q.off != q.on {
+			continue
+		}
+
+		value := q.values[0]
+		if q.off {
+			value = q.values[1]
+		}
+
+		items = append(items, q.key+"="+value)
+	}
+
+	return strings.Join(items, ",")
+}
+
+func parseQuirkOverride(key, value string) (QuirkOverride, error) {
+	switch key {
+	case "vfreset":
+		v, err := parseQuirkSwitch(key, value)
+
+		return func(q *Quirks) { q.VFReset = v }, err
+	case "memory":
+		v, err := parseQuirkSwitch(key, value)
+
+		return func(q *Quirks) { q.MemoryIncrementI = v }, err
+	case "clip":
+		v, err := parseQuirkSwitch(key, value)
+
+		return func(q *Quirks) { q.Clip = v }, err
+	case "vblank":
+		v, err := parseQuirkSwitch(key, value)
+
+		return func(q *Quirks) { q.DisplayWait = v }, err
+	case "shift":
+		switch value {
+		case "vy":
+			return func(q *Quirks) { q.ShiftVY = true }, nil
+		case "vx":
+			return func(q *Quirks) { q.ShiftVY = false }, nil
+		}
+	case "jump":
+		switch value {
+		case "bnnn":
+			return func(q *Quirks) { q.JumpVX = false }, nil
+		case "bxnn":
+			return func(q *Quirks) { q.JumpVX = true }, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown quirk %q", key)
+	}
+
+	return nil, fmt.Errorf("invalid value %q for quirk %s", value, key)
+}
+
+func parseQuirkSwitch(key, value string) (bool, error) {
+	switch value {
+	case "on", "true", "1":
+		return true, nil
+	case "off", "false", "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid value %q for quirk %s, expected on or off", value, key)
+	}
+}
+
+func quirkPresetNames() []string {
+	names := make([]string, 0, len(QuirkPresets))
+
+	for name := range QuirkPresets {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
@@ -0,0 +1,66 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuirks(t *testing.T) {
+	t.Run("DefaultQuirks", func(t *testing.T) {
+		assert.Equal(t, lib.QuirkPresets["chip8"], lib.DefaultQuirks(lib.CM_CHIP8))
+		assert.Equal(t, lib.QuirkPresets["schip-modern"], lib.DefaultQuirks(lib.CM_SUPERCHIP))
+		assert.Equal(t, lib.QuirkPresets["schip-modern"], lib.DefaultQuirks(lib.CM_NONE))
+		assert.Equal(t, lib.QuirkPresets["xo-chip"], lib.DefaultQuirks(lib.CM_XOCHIP))
+	})
+
+	t.Run("ParseQuirkOverrides", func(t *testing.T) {
+		overrides, err := lib.ParseQuirkOverrides("shift=vx, jump=bxnn,clip=off,vblank=off")
+		assert.NoError(t, err)
+
+		q := lib.QuirkPresets["chip8"].With(overrides)
+		assert.True(t, q.VFReset)
+		assert.True(t, q.MemoryIncrementI)
+		assert.False(t, q.DisplayWait)
+		assert.False(t, q.ShiftVY)
+		assert.True(t, q.JumpVX)
+		assert.False(t, q.Clip)
+	})
+
+	t.Run("ParseQuirkOverridesPreset", func(t *testing.T) {
+		overrides, err := lib.ParseQuirkOverrides("xo-chip,memory=off")
+		assert.NoError(t, err)
+
+		q := lib.QuirkPresets["chip8"].With(overrides)
+		expected := lib.QuirkPresets["xo-chip"]
+		expected.MemoryIncrementI = false
+		assert.Equal(t, expected, q)
+	})
+
+	t.Run("FormatQuirkOverrides", func(t *testing.T) {
+		for spec, expected := range map[string]string{
+			"":                       "",
+			"clip=off, shift=vy":     "shift=vy,clip=off",
+			"clip=on,clip=0":         "clip=off",
+			"chip8":                  "vfreset=on,shift=vy,memory=on,jump=bnnn,clip=on,vblank=on",
+			"schip-modern,jump=bnnn": "vfreset=off,shift=vx,memory=off,jump=bnnn,clip=on,vblank=off",
+		} {
+			overrides, err := lib.ParseQuirkOverrides(spec)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, lib.FormatQuirkOverrides(overrides), spec)
+
+			// The spec is parsed back to the same quirks
+			parsed, err := lib.ParseQuirkOverrides(expected)
+			assert.NoError(t, err)
+			assert.Equal(t, lib.QuirkPresets["xo-chip"].With(overrides), lib.QuirkPresets["xo-chip"].With(parsed), spec)
+		}
+	})
+
+	t.Run("ParseQuirkOverridesErrors", func(t *testing.T) {
+		for _, spec := range []string{"unknown=on", "clip=maybe", "shift=vz", "jump=b", "cosmac"} {
+			_, err := lib.ParseQuirkOverrides(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+}
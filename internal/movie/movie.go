@@ -0,0 +1,222 @@
+package movie
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+// Header identifies the run a movie was recorded from: replaying it needs
+// the same ROM, random seed and machine settings.
+type Header struct {
+	Seed    uint64
+	ROMHash string
+
+	Mode lib.CompatibilityMode
+	// Quirk overrides applied on top of the mode quirks, formatted by
+	// lib.FormatQuirkOverrides
+	Quirks     string
+	IPF        int
+	LegacyRand bool
+}
+
+// Event is a key state change, applied after Tick instructions were
+// executed.
+type Event struct {
+	Tick    uint64
+	Key     byte
+	Pressed bool
+}
+
+type Movie struct {
+	Header
+	Events []Event
+}
+
+const (
+	HEADER  = "# chip8-go movie"
+	VERSION = 2
+)
+
+var ErrInvalidMovie = errors.New("invalid movie")
+
+func HashROM(rom []byte) string {
+	sum := sha256.Sum256(rom)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func (e Event) String() string {
+	state := "up"
+	if e.Pressed {
+		state = "down"
+	}
+
+	return fmt.Sprintf("tick=%d key=%X %s", e.Tick, e.Key, state)
+}
+
+// Writer appends events to a movie. Flush must be called once done.
+type Writer struct {
+	w *bufio.Writer
+}
+
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	mw := &Writer{w: bufio.NewWriter(w)}
+
+	if _, err := fmt.Fprintf(mw.w, "%s v%d\nseed=%d rom=%s mode=%s quirks=%s ipf=%d legacy-rand=%t\n",
+		HEADER, VERSION, h.Seed, h.ROMHash, h.Mode, h.Quirks, h.IPF, h.LegacyRand); err != nil {
+		return nil, fmt.Errorf("failed to write movie header: %w", err)
+	}
+
+	return mw, nil
+}
+
+func (mw *Writer) Write(e Event) error {
+	_, err := fmt.Fprintln(mw.w, e.String())
+
+	return err
+}
+
+func (mw *Writer) Flush() error {
+	return mw.w.Flush()
+}
+
+// Read parses a whole movie. Events must be in tick order.
+func Read(r io.Reader) (*Movie, error) {
+	s := bufio.NewScanner(r)
+
+	if !s.Scan() || !strings.HasPrefix(s.Text(), HEADER) {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidMovie)
+	}
+
+	if version := strings.TrimPrefix(s.Text(), HEADER+" "); version != fmt.Sprintf("v%d", VERSION) {
+		return nil, fmt.Errorf("%w: unsupported version %q, expected v%d", ErrInvalidMovie, version, VERSION)
+	}
+
+	if !s.Scan() {
+		return nil, fmt.Errorf("%w: missing seed and rom hash", ErrInvalidMovie)
+	}
+
+	m := &Movie{}
+
+	for _, field := range strings.Fields(s.Text()) {
+		key, value, _ := strings.Cut(field, "=")
+
+		switch key {
+		case "seed":
+			seed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: seed: %w", ErrInvalidMovie, err)
+			}
+
+			m.Seed = seed
+		case "rom":
+			m.ROMHash = value
+		case "mode":
+			if value == lib.CM_NONE.String() {
+				m.Mode = lib.CM_NONE
+
+				continue
+			}
+
+			mode, err := lib.ParseCompatibilityMode(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidMovie, err)
+			}
+
+			m.Mode = mode
+		case "quirks":
+			overrides, err := lib.ParseQuirkOverrides(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: quirks: %w", ErrInvalidMovie, err)
+			}
+
+			m.Quirks = lib.FormatQuirkOverrides(overrides)
+		case "ipf":
+			ipf, err := strconv.Atoi(value)
+			if err != nil || ipf < 0 {
+				return nil, fmt.Errorf("%w: invalid ipf %q", ErrInvalidMovie, value)
+			}
+
+			m.IPF = ipf
+		case "legacy-rand":
+			legacyRand, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: legacy-rand: %w", ErrInvalidMovie, err)
+			}
+
+			m.LegacyRand = legacyRand
+		default:
+			return nil, fmt.Errorf("%w: unknown header field %q", ErrInvalidMovie, key)
+		}
+	}
+
+	for line := 3; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		e, err := parseEvent(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidMovie, line, err)
+		}
+
+		if n := len(m.Events); n > 0 && e.Tick < m.Events[n-1].Tick {
+			return nil, fmt.Errorf("%w: line %d: tick %d is before the previous event", ErrInvalidMovie, line, e.Tick)
+		}
+
+		m.Events = append(m.Events, e)
+	}
+
+	return m, s.Err()
+}
+
+func parseEvent(line string) (Event, error) {
+	var e Event
+
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return e, fmt.Errorf("expected tick=<n> key=<k> down|up")
+	}
+
+	tick, ok := strings.CutPrefix(fields[0], "tick=")
+	if !ok {
+		return e, fmt.Errorf("missing tick")
+	}
+
+	key, ok := strings.CutPrefix(fields[1], "key=")
+	if !ok {
+		return e, fmt.Errorf("missing key")
+	}
+
+	var err error
+
+	if e.Tick, err = strconv.ParseUint(tick, 10, 64); err != nil {
+		return e, fmt.Errorf("tick: %w", err)
+	}
+
+	k, err := strconv.ParseUint(key, 16, 4)
+	if err != nil {
+		return e, fmt.Errorf("key: %w", err)
+	}
+
+	e.Key = byte(k)
+
+	switch fields[2] {
+	case "down":
+		e.Pressed = true
+	case "up":
+	default:
+		return e, fmt.Errorf("unknown key state %q", fields[2])
+	}
+
+	return e, nil
+}
@@ -0,0 +1,64 @@
+package movie_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/cterence/chip8-go/internal/movie"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTrip(t *testing.T) {
+	header := movie.Header{
+		Seed:       42,
+		ROMHash:    movie.HashROM([]byte{0x00, 0xE0}),
+		Mode:       lib.CM_SUPERCHIP,
+		Quirks:     "shift=vy,clip=off",
+		IPF:        30,
+		LegacyRand: true,
+	}
+	events := []movie.Event{
+		{Tick: 10, Key: 0x5, Pressed: true},
+		{Tick: 10, Key: 0xA, Pressed: true},
+		{Tick: 250, Key: 0x5},
+	}
+
+	var buf bytes.Buffer
+
+	w, err := movie.NewWriter(&buf, header)
+	assert.NoError(t, err)
+
+	for _, e := range events {
+		assert.NoError(t, w.Write(e))
+	}
+
+	assert.NoError(t, w.Flush())
+	assert.Contains(t, buf.String(), "mode=super quirks=shift=vy,clip=off ipf=30 legacy-rand=true\n")
+	assert.Contains(t, buf.String(), "tick=250 key=5 up\n")
+
+	m, err := movie.Read(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, header, m.Header)
+	assert.Equal(t, events, m.Events)
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"tick=1 key=5 down\n",
+		"# chip8-go movie v1\nseed=1 rom=00\n",
+		"# chip8-go movie v2\nseed=1 rom=00 mode=cosmac\n",
+		"# chip8-go movie v2\nseed=1 rom=00 quirks=shift=vz\n",
+		"# chip8-go movie v2\nseed=1 rom=00 ipf=-1\n",
+		"# chip8-go movie v2\nseed=x rom=00\n",
+		"# chip8-go movie v2\nseed=1 rom=00\ntick=1 key=G down\n",
+		"# chip8-go movie v2\nseed=1 rom=00\ntick=1 key=5 left\n",
+		"# chip8-go movie v2\nseed=1 rom=00\ntick=5 key=5 down\ntick=4 key=5 up\n",
+	} {
+		_, err := movie.Read(strings.NewReader(src))
+		assert.True(t, errors.Is(err, movie.ErrInvalidMovie), src)
+	}
+}
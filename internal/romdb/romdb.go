@@ -0,0 +1,260 @@
+// Package romdb identifies ROMs by their SHA-1 in a database in the format
+// of the community CHIP-8 database (https://github.com/chip-8/chip-8-database),
+// to know which platform, quirks, speed, colors and keys they were written
+// for before they run.
+package romdb
+
+import (
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+//go:generate go run ./update
+
+//go:embed database/programs.json
+var embeddedPrograms []byte
+
+// Program is an entry of programs.json, with the ROM files it was released
+// as keyed by SHA-1.
+type Program struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Authors     []string       `json:"authors,omitempty"`
+	ROMs        map[string]ROM `json:"roms"`
+}
+
+type ROM struct {
+	File string `json:"file,omitempty"`
+	// Platforms the ROM runs on, the preferred one first
+	Platforms []string `json:"platforms"`
+	// Quirks differing from the platform defaults
+	QuirkyPlatforms map[string]PlatformQuirks `json:"quirkyPlatforms,omitempty"`
+	// Instructions per frame
+	Tickrate int             `json:"tickrate,omitempty"`
+	Colors   *Colors         `json:"colors,omitempty"`
+	Keys     map[string]byte `json:"keys,omitempty"`
+}
+
+// PlatformQuirks are the database quirks, nil when unset. They are named
+// after the non-CHIP-8 behavior: Shift set means 8XY6 and 8XYE shift VX in
+// place, Wrap set means sprites wrap around the screen edges.
+type PlatformQuirks struct {
+	Shift                 *bool `json:"shift,omitempty"`
+	MemoryIncrementByX    *bool `json:"memoryIncrementByX,omitempty"`
+	MemoryLeaveIUnchanged *bool `json:"memoryLeaveIUnchanged,omitempty"`
+	Wrap                  *bool `json:"wrap,omitempty"`
+	Jump                  *bool `json:"jump,omitempty"`
+	VBlank                *bool `json:"vblank,omitempty"`
+	Logic                 *bool `json:"logic,omitempty"`
+}
+
+// Colors are "#rrggbb" strings, Pixels being indexed like the palette.
+type Colors struct {
+	Pixels  []string `json:"pixels,omitempty"`
+	Buzzer  string   `json:"buzzer,omitempty"`
+	Silence string   `json:"silence,omitempty"`
+}
+
+// Entry is what the database knows about a ROM, translated to this
+// interpreter's settings. Unknown settings are left to their zero value.
+type Entry struct {
+	Title    string
+	Platform string
+	Mode     lib.CompatibilityMode
+	Quirks   []lib.QuirkOverride
+	IPF      int
+	Palette  color.Palette
+	KeyMap   map[string]byte
+}
+
+type Database struct {
+	roms map[string]programROM
+}
+
+type programROM struct {
+	title string
+	rom   ROM
+}
+
+// Platform identifiers of the database mapped to a compatibility mode and the
+// platform quirks. MEGA-CHIP is not supported.
+var platforms = map[string]struct {
+	mode   lib.CompatibilityMode
+	quirks lib.Quirks
+}{
+	"originalChip8": {lib.CM_CHIP8, lib.QuirkPresets["chip8"]},
+	"hybridVIP":     {lib.CM_CHIP8, lib.QuirkPresets["chip8"]},
+	"modernChip8":   {lib.CM_CHIP8, lib.Quirks{ShiftVY: true, MemoryIncrementI: true, Clip: true}},
+	"chip8x":        {lib.CM_CHIP8, lib.QuirkPresets["chip8"]},
+	"chip48":        {lib.CM_SUPERCHIP, lib.QuirkPresets["schip-legacy"]},
+	"superchip1":    {lib.CM_SUPERCHIP, lib.QuirkPresets["schip-legacy"]},
+	"superchip":     {lib.CM_SUPERCHIP, lib.QuirkPresets["schip-modern"]},
+	"xochip":        {lib.CM_XOCHIP, lib.QuirkPresets["xo-chip"]},
+}
+
+// Buttons a ROM can bind to keypad keys
+var Buttons = []string{"up", "down", "left", "right", "a", "b"}
+
+var embedded = sync.OnceValues(func() (*Database, error) {
+	return Parse(bytes.NewReader(embeddedPrograms))
+})
+
+// Embedded returns the database built into the binary, refreshed from the
+// community database with go generate.
+func Embedded() *Database {
+	db, err := embedded()
+	if err != nil {
+		// Checked by the tests
+		panic(fmt.Sprintf("invalid embedded ROM database: %v", err))
+	}
+
+	return db
+}
+
+// Load reads a programs.json file, from a checkout of the community database
+// for example.
+func Load(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ROM database: %w", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+func Parse(r io.Reader) (*Database, error) {
+	var programs []Program
+
+	if err := json.NewDecoder(r).Decode(&programs); err != nil {
+		return nil, fmt.Errorf("failed to decode ROM database: %w", err)
+	}
+
+	db := &Database{roms: map[string]programROM{}}
+
+	for _, p := range programs {
+		for hash, rom := range p.ROMs {
+			db.roms[strings.ToLower(hash)] = programROM{title: p.Title, rom: rom}
+		}
+	}
+
+	return db, nil
+}
+
+// Len returns the number of ROMs in the database.
+func (db *Database) Len() int {
+	return len(db.roms)
+}
+
+// Lookup finds the ROM in the database by its SHA-1.
+func (db *Database) Lookup(rom []byte) (Entry, bool) {
+	sum := sha1.Sum(rom)
+
+	r, ok := db.roms[hex.EncodeToString(sum[:])]
+	if !ok {
+		return Entry{}, false
+	}
+
+	return newEntry(r.title, r.rom), true
+}
+
+func newEntry(title string, r ROM) Entry {
+	e := Entry{
+		Title: title,
+		IPF:   r.Tickrate,
+	}
+
+	for _, p := range r.Platforms {
+		platform, ok := platforms[p]
+		if !ok {
+			continue
+		}
+
+		e.Platform = p
+		e.Mode = platform.mode
+		e.Quirks = append([]lib.QuirkOverride{func(q *lib.Quirks) { *q = platform.quirks }}, quirkOverrides(r.QuirkyPlatforms[p])...)
+
+		break
+	}
+
+	if e.Platform == "" && len(r.Platforms) > 0 {
+		log.Printf("ROM database: unsupported platforms %s", strings.Join(r.Platforms, ", "))
+	}
+
+	if r.Colors != nil {
+		e.Palette = parsePalette(r.Colors.Pixels)
+	}
+
+	for _, b := range Buttons {
+		if key, ok := r.Keys[b]; ok && key < 16 {
+			if e.KeyMap == nil {
+				e.KeyMap = map[string]byte{}
+			}
+
+			e.KeyMap[b] = key
+		}
+	}
+
+	return e
+}
+
+func quirkOverrides(pq PlatformQuirks) []lib.QuirkOverride {
+	var overrides []lib.QuirkOverride
+
+	set := func(v *bool, o func(*lib.Quirks, bool)) {
+		if v != nil {
+			value := *v
+			overrides = append(overrides, func(q *lib.Quirks) { o(q, value) })
+		}
+	}
+
+	set(pq.Logic, func(q *lib.Quirks, v bool) { q.VFReset = v })
+	set(pq.Shift, func(q *lib.Quirks, v bool) { q.ShiftVY = !v })
+	set(pq.Jump, func(q *lib.Quirks, v bool) { q.JumpVX = v })
+	set(pq.Wrap, func(q *lib.Quirks, v bool) { q.Clip = !v })
+	set(pq.VBlank, func(q *lib.Quirks, v bool) { q.DisplayWait = v })
+	// I is either left unchanged or incremented, by X or X + 1: only X + 1 is
+	// supported and incrementing by X is treated as leaving I unchanged
+	set(pq.MemoryLeaveIUnchanged, func(q *lib.Quirks, v bool) { q.MemoryIncrementI = !v })
+	set(pq.MemoryIncrementByX, func(q *lib.Quirks, v bool) {
+		if v {
+			q.MemoryIncrementI = false
+		}
+	})
+
+	return overrides
+}
+
+// parsePalette parses up to 4 "#rrggbb" colors, stopping at the first invalid
+// one.
+func parsePalette(pixels []string) color.Palette {
+	var palette color.Palette
+
+	for _, p := range pixels[:min(len(pixels), 4)] {
+		digits := strings.TrimPrefix(p, "#")
+
+		rgb, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || len(digits) != 6 {
+			log.Printf("ROM database: invalid color %q", p)
+
+			break
+		}
+
+		palette = append(palette, color.RGBA{R: byte(rgb >> 16), G: byte(rgb >> 8), B: byte(rgb), A: 0xFF})
+	}
+
+	return palette
+}
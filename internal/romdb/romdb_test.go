@@ -0,0 +1,90 @@
+package romdb_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/cterence/chip8-go/internal/romdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbedded(t *testing.T) {
+	var db *romdb.Database
+
+	assert.NotPanics(t, func() { db = romdb.Embedded() })
+
+	// CI checks the submodules out and builds the committed database
+	skip := t.Skipf
+	if os.Getenv("CI") != "" {
+		skip = t.Fatalf
+	}
+
+	if db.Len() == 0 {
+		skip("embedded database is empty, run go generate ./internal/romdb")
+	}
+
+	// The test suite ROMs are listed in the database
+	rom, err := os.ReadFile(filepath.Join("..", "..", "sub", "chip8-test-suite", "bin", "2-ibm-logo.ch8"))
+	if err != nil {
+		skip("test suite not found, run git submodule update --init: %v", err)
+	}
+
+	_, ok := db.Lookup(rom)
+	assert.True(t, ok)
+}
+
+func TestLookup(t *testing.T) {
+	rom := []byte{0x00, 0xE0, 0x12, 0x00}
+	sum := sha1.Sum(rom)
+
+	db, err := romdb.Parse(strings.NewReader(fmt.Sprintf(`[{
+		"title": "Test",
+		"roms": {
+			"%s": {
+				"platforms": ["megachip8", "superchip1", "xochip"],
+				"quirkyPlatforms": {"superchip1": {"shift": false, "wrap": true, "vblank": false}},
+				"tickrate": 30,
+				"colors": {"pixels": ["#000000", "#FF8000"]},
+				"keys": {"up": 5, "a": 6, "player2Up": 1}
+			}
+		}
+	}]`, strings.ToUpper(hex.EncodeToString(sum[:])))))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, db.Len())
+
+	_, ok := db.Lookup([]byte{0x00})
+	assert.False(t, ok)
+
+	e, ok := db.Lookup(rom)
+	assert.True(t, ok)
+
+	assert.Equal(t, "Test", e.Title)
+	assert.Equal(t, "superchip1", e.Platform)
+	assert.Equal(t, lib.CM_SUPERCHIP, e.Mode)
+	assert.Equal(t, 30, e.IPF)
+	assert.Equal(t, color.Palette{color.RGBA{A: 0xFF}, color.RGBA{R: 0xFF, G: 0x80, A: 0xFF}}, e.Palette)
+	assert.Equal(t, map[string]byte{"up": 5, "a": 6}, e.KeyMap)
+
+	// The platform quirks replace the current ones
+	q := lib.QuirkPresets["chip8"].With(e.Quirks)
+	expected := lib.QuirkPresets["schip-legacy"]
+	expected.ShiftVY = true
+	expected.Clip = false
+	expected.DisplayWait = false
+	assert.Equal(t, expected, q)
+}
+
+func TestInvalid(t *testing.T) {
+	_, err := romdb.Parse(strings.NewReader(`{}`))
+	assert.Error(t, err)
+
+	_, err = romdb.Load("missing.json")
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+// Command update downloads the community CHIP-8 database programs.json into
+// the embedded database directory, run with go generate from the romdb
+// package.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const PROGRAMS_URL = "https://raw.githubusercontent.com/chip-8/chip-8-database/master/database/programs.json"
+
+func main() {
+	if err := update(filepath.Join("database", "programs.json")); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func update(path string) error {
+	resp, err := http.Get(PROGRAMS_URL)
+	if err != nil {
+		return fmt.Errorf("failed to download ROM database: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download ROM database: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read ROM database: %w", err)
+	}
+
+	// Keep the embedded file small
+	var compact bytes.Buffer
+
+	if err := json.Compact(&compact, data); err != nil {
+		return fmt.Errorf("invalid ROM database: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(compact.Bytes(), '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write ROM database: %w", err)
+	}
+
+	log.Printf("ROM database written to %s", path)
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Divergence is the first record that differs between two traces. A or B is
+// nil when that trace ended first.
+type Divergence struct {
+	Index  int
+	A, B   *Record
+	Fields []string
+}
+
+// Diff compares two traces record by record and returns their first
+// divergence, or nil if they are identical.
+func Diff(a, b *Reader) (*Divergence, error) {
+	for i := 0; ; i++ {
+		ra, errA := read(a)
+		if errA != nil {
+			return nil, fmt.Errorf("failed to read first trace: %w", errA)
+		}
+
+		rb, errB := read(b)
+		if errB != nil {
+			return nil, fmt.Errorf("failed to read second trace: %w", errB)
+		}
+
+		if ra == nil && rb == nil {
+			return nil, nil
+		}
+
+		if ra == nil || rb == nil {
+			return &Divergence{Index: i, A: ra, B: rb}, nil
+		}
+
+		if fields := diffFields(*ra, *rb); len(fields) > 0 {
+			return &Divergence{Index: i, A: ra, B: rb, Fields: fields}, nil
+		}
+	}
+}
+
+// read returns the next record, or nil at the end of the trace.
+func read(r *Reader) (*Record, error) {
+	rec, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+func diffFields(a, b Record) []string {
+	var fields []string
+
+	if a.Tick != b.Tick {
+		fields = append(fields, "tick")
+	}
+
+	if a.PC != b.PC {
+		fields = append(fields, "PC")
+	}
+
+	if a.Opcode != b.Opcode {
+		fields = append(fields, "opcode")
+	}
+
+	for i := range a.Registers {
+		if a.Registers[i] != b.Registers[i] {
+			fields = append(fields, fmt.Sprintf("V%X", i))
+		}
+	}
+
+	if a.I != b.I {
+		fields = append(fields, "I")
+	}
+
+	if a.SP != b.SP {
+		fields = append(fields, "SP")
+	}
+
+	if a.Delay != b.Delay {
+		fields = append(fields, "DT")
+	}
+
+	if a.Sound != b.Sound {
+		fields = append(fields, "ST")
+	}
+
+	return fields
+}
+
+func (d *Divergence) String() string {
+	if d.A == nil {
+		return fmt.Sprintf("first trace ends after %d records, second continues with:\n  %s", d.Index, d.B)
+	}
+
+	if d.B == nil {
+		return fmt.Sprintf("second trace ends after %d records, first continues with:\n  %s", d.Index, d.A)
+	}
+
+	return fmt.Sprintf("traces diverge at record %d (%s differ):\n  a: %s\n  b: %s", d.Index, strings.Join(d.Fields, ", "), d.A, d.B)
+}
@@ -0,0 +1,428 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Record is the machine state after executing one instruction.
+type Record struct {
+	Tick      uint64
+	PC        uint16
+	Opcode    uint16
+	Registers [16]byte
+	I         uint16
+	SP        uint8
+	Delay     byte
+	Sound     byte
+}
+
+type Format uint8
+
+const (
+	TF_TEXT Format = iota
+	TF_BINARY
+)
+
+const (
+	TEXT_HEADER  = "# chip8-go trace"
+	BINARY_MAGIC = "C8TR"
+	VERSION      = 1
+)
+
+// Fields present in a binary record, the others are predicted from the
+// previous record: the tick is incremented, PC advances to the next
+// instruction, the opcode is the last one seen at PC and the rest is
+// unchanged.
+const (
+	RF_TICK uint64 = 1 << iota
+	RF_PC
+	RF_OPCODE
+	RF_REGISTERS
+	RF_I
+	RF_SP
+	RF_DELAY
+	RF_SOUND
+)
+
+var ErrInvalidTrace = errors.New("invalid trace")
+
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "text":
+		return TF_TEXT, nil
+	case "binary":
+		return TF_BINARY, nil
+	default:
+		return 0, fmt.Errorf("unknown trace format: %s", s)
+	}
+}
+
+func (r Record) String() string {
+	return fmt.Sprintf("tick=%d pc=%04X op=%04X v=%X i=%04X sp=%02X dt=%02X st=%02X",
+		r.Tick, r.PC, r.Opcode, r.Registers[:], r.I, r.SP, r.Delay, r.Sound)
+}
+
+// Writer encodes records. Flush must be called once done.
+type Writer struct {
+	w       *bufio.Writer
+	format  Format
+	prev    Record
+	opcodes map[uint16]uint16
+	buf     []byte
+}
+
+func NewWriter(w io.Writer, format Format) (*Writer, error) {
+	tw := &Writer{
+		w:       bufio.NewWriter(w),
+		format:  format,
+		opcodes: make(map[uint16]uint16),
+	}
+
+	var err error
+
+	switch format {
+	case TF_TEXT:
+		_, err = fmt.Fprintf(tw.w, "%s v%d\n", TEXT_HEADER, VERSION)
+	case TF_BINARY:
+		_, err = tw.w.Write([]byte{BINARY_MAGIC[0], BINARY_MAGIC[1], BINARY_MAGIC[2], BINARY_MAGIC[3], VERSION})
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to write trace header: %w", err)
+	}
+
+	return tw, nil
+}
+
+func (tw *Writer) Write(r Record) error {
+	if tw.format == TF_TEXT {
+		_, err := fmt.Fprintln(tw.w, r.String())
+
+		return err
+	}
+
+	var flags uint64
+
+	if r.Tick != tw.prev.Tick+1 {
+		flags |= RF_TICK
+	}
+
+	if r.PC != tw.prev.PC+2 {
+		flags |= RF_PC
+	}
+
+	if op, ok := tw.opcodes[r.PC]; !ok || op != r.Opcode {
+		flags |= RF_OPCODE
+	}
+
+	var changedRegs uint64
+
+	for i := range r.Registers {
+		if r.Registers[i] != tw.prev.Registers[i] {
+			changedRegs |= 1 << i
+		}
+	}
+
+	if changedRegs != 0 {
+		flags |= RF_REGISTERS
+	}
+
+	if r.I != tw.prev.I {
+		flags |= RF_I
+	}
+
+	if r.SP != tw.prev.SP {
+		flags |= RF_SP
+	}
+
+	if r.Delay != tw.prev.Delay {
+		flags |= RF_DELAY
+	}
+
+	if r.Sound != tw.prev.Sound {
+		flags |= RF_SOUND
+	}
+
+	b := binary.AppendUvarint(tw.buf[:0], flags)
+
+	if flags&RF_TICK != 0 {
+		b = binary.AppendUvarint(b, r.Tick-tw.prev.Tick)
+	}
+
+	if flags&RF_PC != 0 {
+		b = binary.AppendVarint(b, int64(r.PC)-int64(tw.prev.PC))
+	}
+
+	if flags&RF_OPCODE != 0 {
+		b = binary.BigEndian.AppendUint16(b, r.Opcode)
+	}
+
+	if flags&RF_REGISTERS != 0 {
+		b = binary.AppendUvarint(b, changedRegs)
+
+		for i := range r.Registers {
+			if changedRegs&(1<<i) != 0 {
+				b = append(b, r.Registers[i])
+			}
+		}
+	}
+
+	if flags&RF_I != 0 {
+		b = binary.AppendVarint(b, int64(r.I)-int64(tw.prev.I))
+	}
+
+	if flags&RF_SP != 0 {
+		b = append(b, r.SP)
+	}
+
+	if flags&RF_DELAY != 0 {
+		b = append(b, r.Delay)
+	}
+
+	if flags&RF_SOUND != 0 {
+		b = append(b, r.Sound)
+	}
+
+	tw.buf = b
+	tw.prev = r
+	tw.opcodes[r.PC] = r.Opcode
+
+	_, err := tw.w.Write(b)
+
+	return err
+}
+
+func (tw *Writer) Flush() error {
+	return tw.w.Flush()
+}
+
+// Reader decodes records of either format.
+type Reader struct {
+	r       *bufio.Reader
+	format  Format
+	prev    Record
+	opcodes map[uint16]uint16
+	line    int
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	tr := &Reader{
+		r:       bufio.NewReader(r),
+		opcodes: make(map[uint16]uint16),
+	}
+
+	magic, err := tr.r.Peek(len(BINARY_MAGIC) + 1)
+	if err == nil && bytes.HasPrefix(magic, []byte(BINARY_MAGIC)) {
+		if magic[len(BINARY_MAGIC)] != VERSION {
+			return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidTrace, magic[len(BINARY_MAGIC)])
+		}
+
+		tr.format = TF_BINARY
+		_, _ = tr.r.Discard(len(magic))
+
+		return tr, nil
+	}
+
+	header, err := tr.r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(header, TEXT_HEADER) {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidTrace)
+	}
+
+	tr.format = TF_TEXT
+	tr.line = 1
+
+	return tr, nil
+}
+
+// Read returns the next record, or io.EOF at the end of the trace.
+func (tr *Reader) Read() (Record, error) {
+	if tr.format == TF_TEXT {
+		return tr.readText()
+	}
+
+	return tr.readBinary()
+}
+
+func (tr *Reader) readText() (Record, error) {
+	for {
+		line, err := tr.r.ReadString('\n')
+		if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+			return Record{}, err
+		}
+
+		tr.line++
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		r, err := parseRecord(line)
+		if err != nil {
+			return Record{}, fmt.Errorf("%w: line %d: %w", ErrInvalidTrace, tr.line, err)
+		}
+
+		return r, nil
+	}
+}
+
+func parseRecord(line string) (Record, error) {
+	var r Record
+
+	for _, field := range strings.Fields(line) {
+		key, value, _ := strings.Cut(field, "=")
+
+		var (
+			v   uint64
+			err error
+		)
+
+		switch key {
+		case "tick":
+			r.Tick, err = strconv.ParseUint(value, 10, 64)
+		case "v":
+			var regs []byte
+
+			regs, err = hex.DecodeString(value)
+			if err == nil && copy(r.Registers[:], regs) != len(r.Registers) {
+				err = fmt.Errorf("expected %d registers", len(r.Registers))
+			}
+		case "pc", "op", "i":
+			v, err = strconv.ParseUint(value, 16, 16)
+
+			switch key {
+			case "pc":
+				r.PC = uint16(v)
+			case "op":
+				r.Opcode = uint16(v)
+			default:
+				r.I = uint16(v)
+			}
+		case "sp", "dt", "st":
+			v, err = strconv.ParseUint(value, 16, 8)
+
+			switch key {
+			case "sp":
+				r.SP = uint8(v)
+			case "dt":
+				r.Delay = byte(v)
+			default:
+				r.Sound = byte(v)
+			}
+		default:
+			err = fmt.Errorf("unknown field %q", key)
+		}
+
+		if err != nil {
+			return r, fmt.Errorf("field %s: %w", key, err)
+		}
+	}
+
+	return r, nil
+}
+
+func (tr *Reader) readBinary() (Record, error) {
+	flags, err := binary.ReadUvarint(tr.r)
+	if err != nil {
+		return Record{}, err
+	}
+
+	r := tr.prev
+	r.Tick++
+	r.PC += 2
+
+	fail := func(err error) (Record, error) {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return Record{}, fmt.Errorf("%w: %w", ErrInvalidTrace, err)
+	}
+
+	if flags&RF_TICK != 0 {
+		delta, err := binary.ReadUvarint(tr.r)
+		if err != nil {
+			return fail(err)
+		}
+
+		r.Tick = tr.prev.Tick + delta
+	}
+
+	if flags&RF_PC != 0 {
+		delta, err := binary.ReadVarint(tr.r)
+		if err != nil {
+			return fail(err)
+		}
+
+		r.PC = uint16(int64(tr.prev.PC) + delta)
+	}
+
+	if flags&RF_OPCODE != 0 {
+		var op [2]byte
+
+		if _, err := io.ReadFull(tr.r, op[:]); err != nil {
+			return fail(err)
+		}
+
+		r.Opcode = binary.BigEndian.Uint16(op[:])
+	} else {
+		op, ok := tr.opcodes[r.PC]
+		if !ok {
+			return fail(fmt.Errorf("missing opcode at %04X", r.PC))
+		}
+
+		r.Opcode = op
+	}
+
+	if flags&RF_REGISTERS != 0 {
+		changed, err := binary.ReadUvarint(tr.r)
+		if err != nil {
+			return fail(err)
+		}
+
+		for i := range r.Registers {
+			if changed&(1<<i) == 0 {
+				continue
+			}
+
+			if r.Registers[i], err = tr.r.ReadByte(); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	if flags&RF_I != 0 {
+		delta, err := binary.ReadVarint(tr.r)
+		if err != nil {
+			return fail(err)
+		}
+
+		r.I = uint16(int64(tr.prev.I) + delta)
+	}
+
+	for _, f := range []struct {
+		flag uint64
+		dst  *byte
+	}{{RF_SP, &r.SP}, {RF_DELAY, &r.Delay}, {RF_SOUND, &r.Sound}} {
+		if flags&f.flag == 0 {
+			continue
+		}
+
+		if *f.dst, err = tr.r.ReadByte(); err != nil {
+			return fail(err)
+		}
+	}
+
+	tr.prev = r
+	tr.opcodes[r.PC] = r.Opcode
+
+	return r, nil
+}
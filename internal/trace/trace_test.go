@@ -0,0 +1,131 @@
+package trace_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func records() []trace.Record {
+	var rs []trace.Record
+
+	r := trace.Record{PC: 0x200}
+
+	for i := range 50 {
+		r.Tick++
+		r.Opcode = 0x7000 | uint16(i%4)
+		r.Registers[i%16] += byte(i)
+
+		if i%10 == 0 {
+			r.PC = 0x200
+			r.I = 0x300 - uint16(i)
+			r.Delay = byte(60 - i)
+		} else {
+			r.PC += 2
+		}
+
+		if i%7 == 0 {
+			r.Tick += 3
+			r.SP ^= 1
+			r.Sound = byte(i)
+		}
+
+		rs = append(rs, r)
+	}
+
+	return rs
+}
+
+func encode(t *testing.T, format trace.Format, rs []trace.Record) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	w, err := trace.NewWriter(&buf, format)
+	assert.NoError(t, err)
+
+	for _, r := range rs {
+		assert.NoError(t, w.Write(r))
+	}
+
+	assert.NoError(t, w.Flush())
+
+	return &buf
+}
+
+func decode(t *testing.T, buf io.Reader) []trace.Record {
+	r, err := trace.NewReader(buf)
+	assert.NoError(t, err)
+
+	var rs []trace.Record
+
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rs
+		}
+
+		assert.NoError(t, err)
+
+		rs = append(rs, rec)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		rs := records()
+
+		text := encode(t, trace.TF_TEXT, rs)
+		bin := encode(t, trace.TF_BINARY, rs)
+
+		assert.Less(t, bin.Len(), text.Len()/10)
+		assert.Equal(t, rs, decode(t, text))
+		assert.Equal(t, rs, decode(t, bin))
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		rs := records()
+
+		other := records()
+		other[20].Registers[3]++
+		other[20].I++
+
+		d, err := trace.Diff(mustReader(t, encode(t, trace.TF_TEXT, rs)), mustReader(t, encode(t, trace.TF_BINARY, rs)))
+		assert.NoError(t, err)
+		assert.Nil(t, d)
+
+		d, err = trace.Diff(mustReader(t, encode(t, trace.TF_TEXT, rs)), mustReader(t, encode(t, trace.TF_BINARY, other)))
+		assert.NoError(t, err)
+		assert.Equal(t, 20, d.Index)
+		assert.Equal(t, []string{"V3", "I"}, d.Fields)
+
+		d, err = trace.Diff(mustReader(t, encode(t, trace.TF_BINARY, rs)), mustReader(t, encode(t, trace.TF_BINARY, rs[:30])))
+		assert.NoError(t, err)
+		assert.Equal(t, 30, d.Index)
+		assert.Nil(t, d.B)
+	})
+
+	t.Run("InvalidTrace", func(t *testing.T) {
+		_, err := trace.NewReader(bytes.NewBufferString("garbage"))
+		assert.ErrorIs(t, err, trace.ErrInvalidTrace)
+
+		bin := encode(t, trace.TF_BINARY, records())
+		truncated, err := trace.NewReader(bytes.NewReader(bin.Bytes()[:bin.Len()-1]))
+		assert.NoError(t, err)
+
+		for err == nil {
+			_, err = truncated.Read()
+		}
+
+		assert.ErrorIs(t, err, trace.ErrInvalidTrace)
+	})
+}
+
+func mustReader(t *testing.T, buf io.Reader) *trace.Reader {
+	r, err := trace.NewReader(buf)
+	assert.NoError(t, err)
+
+	return r
+}
@@ -0,0 +1,86 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Writer encodes PCM samples to a WAV file. The sizes in the header are only
+// known once done, Close must be called to write them.
+type Writer struct {
+	w    io.WriteSeeker
+	size uint32
+}
+
+const (
+	HEADER_SIZE = 44
+	// Offsets of the sizes patched on Close
+	RIFF_SIZE_OFFSET = 4
+	DATA_SIZE_OFFSET = 40
+)
+
+var ErrTooLarge = errors.New("wav data exceeds 4 GiB")
+
+// NewWriter writes the header of a mono file of 8 bit unsigned samples.
+func NewWriter(w io.WriteSeeker, sampleRate uint32) (*Writer, error) {
+	const (
+		channels      = 1
+		bitsPerSample = 8
+		blockAlign    = channels * bitsPerSample / 8
+	)
+
+	h := make([]byte, 0, HEADER_SIZE)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, HEADER_SIZE-8)
+	h = append(h, "WAVEfmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 16)
+	// PCM
+	h = binary.LittleEndian.AppendUint16(h, 1)
+	h = binary.LittleEndian.AppendUint16(h, channels)
+	h = binary.LittleEndian.AppendUint32(h, sampleRate)
+	h = binary.LittleEndian.AppendUint32(h, sampleRate*blockAlign)
+	h = binary.LittleEndian.AppendUint16(h, blockAlign)
+	h = binary.LittleEndian.AppendUint16(h, bitsPerSample)
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, 0)
+
+	if _, err := w.Write(h); err != nil {
+		return nil, fmt.Errorf("failed to write wav header: %w", err)
+	}
+
+	return &Writer{w: w}, nil
+}
+
+func (ww *Writer) Write(samples []byte) (int, error) {
+	if uint64(ww.size)+uint64(len(samples)) > uint64(^uint32(0))-HEADER_SIZE {
+		return 0, ErrTooLarge
+	}
+
+	n, err := ww.w.Write(samples)
+	ww.size += uint32(n)
+
+	return n, err
+}
+
+// Close writes the final sizes in the header. It does not close the
+// underlying file.
+func (ww *Writer) Close() error {
+	for _, f := range []struct {
+		offset int64
+		value  uint32
+	}{{RIFF_SIZE_OFFSET, HEADER_SIZE - 8 + ww.size}, {DATA_SIZE_OFFSET, ww.size}} {
+		if _, err := ww.w.Seek(f.offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek wav header: %w", err)
+		}
+
+		if err := binary.Write(ww.w, binary.LittleEndian, f.value); err != nil {
+			return fmt.Errorf("failed to write wav header: %w", err)
+		}
+	}
+
+	_, err := ww.w.Seek(0, io.SeekEnd)
+
+	return err
+}
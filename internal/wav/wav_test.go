@@ -0,0 +1,42 @@
+package wav_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/wav"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+
+	w, err := wav.NewWriter(f, 44100)
+	assert.NoError(t, err)
+
+	_, err = w.Write([]byte{64, 192, 128})
+	assert.NoError(t, err)
+
+	_, err = w.Write([]byte{64})
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, b, wav.HEADER_SIZE+4)
+	assert.Equal(t, "RIFF", string(b[0:4]))
+	assert.Equal(t, uint32(wav.HEADER_SIZE-8+4), binary.LittleEndian.Uint32(b[4:8]))
+	assert.Equal(t, "WAVEfmt ", string(b[8:16]))
+	assert.Equal(t, uint32(44100), binary.LittleEndian.Uint32(b[24:28]))
+	assert.Equal(t, uint16(8), binary.LittleEndian.Uint16(b[34:36]))
+	assert.Equal(t, "data", string(b[36:40]))
+	assert.Equal(t, uint32(4), binary.LittleEndian.Uint32(b[40:44]))
+	assert.Equal(t, []byte{64, 192, 128, 64}, b[44:])
+}
@@ -0,0 +1,225 @@
+// Package machine is a CHIP-8, SUPER-CHIP and XO-CHIP interpreter without any
+// input or output: a frontend loads a ROM, runs it one 60 Hz frame at a time
+// and feeds it keys, then reads back the framebuffer and the audio samples.
+// It has no SDL dependency.
+package machine
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/apu"
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/hardware"
+	"github.com/cterence/chip8-go/internal/lib"
+)
+
+type Machine struct {
+	m *hardware.Machine
+}
+
+type CompatibilityMode = lib.CompatibilityMode
+
+// QuirkOverride changes one quirk of the compatibility mode, see
+// ParseQuirkOverrides.
+type QuirkOverride = lib.QuirkOverride
+
+// Fault is the error returned when the instruction at PC cannot be executed,
+// it wraps one of the Err* errors below.
+type Fault = cpu.Fault
+
+// Registers is a copy of the CPU registers and timers.
+type Registers = hardware.Registers
+
+var (
+	ErrUnknownOpcode      = cpu.ErrUnknownOpcode
+	ErrStackOverflow      = cpu.ErrStackOverflow
+	ErrStackUnderflow     = cpu.ErrStackUnderflow
+	ErrPCOutOfBounds      = cpu.ErrPCOutOfBounds
+	ErrInvalidFrameBuffer = display.ErrInvalidFrameBuffer
+)
+
+type Option = hardware.Option
+
+const (
+	CM_NONE      = lib.CM_NONE
+	CM_CHIP8     = lib.CM_CHIP8
+	CM_SUPERCHIP = lib.CM_SUPERCHIP
+	CM_XOCHIP    = lib.CM_XOCHIP
+
+	// Size of the framebuffer, low resolution pixels are doubled
+	WIDTH  = display.WIDTH
+	HEIGHT = display.HEIGHT
+
+	KEY_COUNT = hardware.KEY_COUNT
+
+	// Frames per second, the timers tick once per frame
+	FPS = apu.TPS
+
+	// Audio samples are unsigned 8-bit mono, SAMPLES_PER_FRAME per frame
+	SAMPLE_RATE       = apu.SAMPLE_RATE
+	SAMPLE_SILENCE    = apu.SAMPLE_SILENCE
+	SAMPLES_PER_FRAME = SAMPLE_RATE / FPS
+
+	// Samples kept when AudioSamples is not called, older ones are dropped
+	MAX_BUFFERED_SAMPLES = hardware.MAX_BUFFERED_SAMPLES
+)
+
+// New returns a machine with an empty program memory, ready for LoadROM.
+func New(options ...Option) *Machine {
+	return &Machine{m: hardware.New(options...)}
+}
+
+// WithCompatibilityMode forces a compatibility mode. With CM_NONE, the mode is
+// detected from the instructions executed.
+func WithCompatibilityMode(mode CompatibilityMode) Option {
+	return hardware.WithCompatibilityMode(mode)
+}
+
+func WithQuirkOverrides(overrides []QuirkOverride) Option {
+	return hardware.WithQuirkOverrides(overrides)
+}
+
+// WithSeed seeds the random number generator used by CXNN, making runs
+// reproducible.
+func WithSeed(seed uint64) Option {
+	return hardware.WithSeed(seed)
+}
+
+func WithLegacyRand(legacyRand bool) Option {
+	return hardware.WithLegacyRand(legacyRand)
+}
+
+// WithRomFileName names the file the SUPER-CHIP flag registers are persisted
+// to.
+func WithRomFileName(romFileName string) Option {
+	return hardware.WithRomFileName(romFileName)
+}
+
+// WithIPF sets the number of instructions executed per frame, overriding the
+// compatibility mode default when greater than 0.
+func WithIPF(ipf int) Option {
+	return hardware.WithIPF(ipf)
+}
+
+// WithPalette sets the colors of the framebuffer, indexed like its pixels.
+// Missing colors keep their default.
+func WithPalette(palette color.Palette) Option {
+	return hardware.WithPalette(palette)
+}
+
+// WithTestFlag writes testFlag at 0x1FF on reset, which the Timendus test
+// suite reads to select a test without a menu.
+func WithTestFlag(testFlag byte) Option {
+	return hardware.WithTestFlag(testFlag)
+}
+
+// WithExitHandler calls onExit when the ROM executes 00FD instead of toggling
+// the pause.
+func WithExitHandler(onExit func()) Option {
+	return hardware.WithExitHandler(onExit)
+}
+
+func ParseCompatibilityMode(mode string) (CompatibilityMode, error) {
+	return lib.ParseCompatibilityMode(mode)
+}
+
+// ParseQuirkOverrides parses a comma separated list of quirk overrides, for
+// example "shift=vy,jump=bxnn,clip=on", or a preset name.
+func ParseQuirkOverrides(spec string) ([]QuirkOverride, error) {
+	return lib.ParseQuirkOverrides(spec)
+}
+
+// LoadROM copies rom to the program memory and resets the machine.
+func (m *Machine) LoadROM(rom []byte) error {
+	return m.m.LoadROM(rom)
+}
+
+// Reset restarts the loaded ROM from a cleared machine.
+func (m *Machine) Reset() {
+	m.m.Reset()
+}
+
+// Step executes one instruction, whether or not the machine is paused. Invalid
+// instructions are not executed and return a *Fault.
+func (m *Machine) Step() error {
+	return m.m.Step()
+}
+
+// Skip moves past the instruction at PC without executing it, to carry on
+// after a fault.
+func (m *Machine) Skip() {
+	m.m.Skip()
+}
+
+// RunFrame runs one 60 Hz frame: up to IPF instructions, fewer when the CPU
+// waits for the vertical blank or faults, then EndFrame. Paused machines do
+// not run.
+func (m *Machine) RunFrame() error {
+	return m.m.RunFrame()
+}
+
+// EndFrame ticks the timers, which generates the frame audio samples, and
+// signals the vertical blank. Frontends stepping through a frame themselves
+// call it once per frame.
+func (m *Machine) EndFrame() {
+	m.m.EndFrame()
+}
+
+// IPF returns the number of instructions executed per frame.
+func (m *Machine) IPF() int {
+	return m.m.IPF()
+}
+
+// WaitingForVBlank reports whether the CPU is stalled after a draw until the
+// end of the frame (display wait quirk).
+func (m *Machine) WaitingForVBlank() bool {
+	return m.m.WaitingForVBlank()
+}
+
+// Ticks returns the number of instructions executed since the last reset.
+func (m *Machine) Ticks() int {
+	return m.m.Ticks()
+}
+
+func (m *Machine) Paused() bool {
+	return m.m.Paused()
+}
+
+func (m *Machine) SetPaused(paused bool) {
+	m.m.SetPaused(paused)
+}
+
+// SetKey presses or releases key 0x0 to 0xF of the keypad.
+func (m *Machine) SetKey(key byte, down bool) {
+	m.m.SetKey(key, down)
+}
+
+// Framebuffer returns a WIDTH x HEIGHT snapshot of the display, low resolution
+// pixels being doubled. The palette index of each pixel has bit 0 set by the
+// first plane and bit 1 by the second.
+func (m *Machine) Framebuffer() *image.Paletted {
+	return m.m.Framebuffer()
+}
+
+// HiRes reports whether the display is in high resolution.
+func (m *Machine) HiRes() bool {
+	return m.m.HiRes()
+}
+
+func (m *Machine) Registers() Registers {
+	return m.m.Registers()
+}
+
+// Memory returns a copy of the whole address space.
+func (m *Machine) Memory() []byte {
+	return m.m.Memory()
+}
+
+// AudioSamples returns the samples generated since the previous call,
+// SAMPLES_PER_FRAME per frame with silence included, and at most
+// MAX_BUFFERED_SAMPLES.
+func (m *Machine) AudioSamples() []byte {
+	return m.m.AudioSamples()
+}
@@ -0,0 +1,196 @@
+package machine_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"testing"
+
+	"github.com/cterence/chip8-go/internal/chip8/components/cpu"
+	"github.com/cterence/chip8-go/internal/chip8/components/display"
+	"github.com/cterence/chip8-go/internal/chip8/components/memory"
+	"github.com/cterence/chip8-go/internal/chip8/components/timer"
+	"github.com/cterence/chip8-go/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+var beepROM = []byte{
+	0x60, 0x00, // V0 = 0
+	0xF0, 0x29, // I = font sprite of V0
+	0xD0, 0x05, // draw at (V0, V0)
+	0x61, 0x05, // V1 = 5
+	0xF1, 0x18, // sound timer = V1
+	0x12, 0x0A, // loop
+}
+
+func TestRunFrame(t *testing.T) {
+	m := machine.New(machine.WithCompatibilityMode(machine.CM_CHIP8))
+	assert.NoError(t, m.LoadROM(beepROM))
+
+	// DXYN waits for the vertical blank
+	assert.NoError(t, m.RunFrame())
+
+	silence := bytes.Repeat([]byte{machine.SAMPLE_SILENCE}, machine.SAMPLES_PER_FRAME)
+
+	assert.Equal(t, silence, m.AudioSamples())
+	assert.Equal(t, uint16(0x206), m.Registers().PC)
+
+	assert.NoError(t, m.RunFrame())
+
+	samples := m.AudioSamples()
+	assert.Len(t, samples, machine.SAMPLES_PER_FRAME)
+	assert.NotEqual(t, silence, samples)
+	assert.Empty(t, m.AudioSamples())
+
+	regs := m.Registers()
+	assert.Equal(t, byte(5), regs.V[1])
+	assert.Equal(t, byte(4), regs.Sound)
+
+	// The top row of 0 is 4 low resolution pixels wide, doubled
+	fb := m.Framebuffer()
+	assert.Equal(t, uint8(1), fb.ColorIndexAt(0, 0))
+	assert.Equal(t, uint8(1), fb.ColorIndexAt(7, 1))
+	assert.Equal(t, uint8(0), fb.ColorIndexAt(8, 0))
+	assert.False(t, m.HiRes())
+
+	assert.Equal(t, beepROM, m.Memory()[0x200:0x200+len(beepROM)])
+}
+
+func TestPalette(t *testing.T) {
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+
+	palette := machine.New(machine.WithPalette(color.Palette{red})).Framebuffer().Palette
+	assert.Len(t, palette, 4)
+	assert.Equal(t, red, palette[0])
+	assert.Equal(t, machine.New().Framebuffer().Palette[1], palette[1])
+}
+
+func TestSetKey(t *testing.T) {
+	rom := []byte{
+		0xF0, 0x0A, // V0 = next key
+		0x12, 0x02, // loop
+	}
+
+	m := machine.New()
+	assert.NoError(t, m.LoadROM(rom))
+
+	assert.NoError(t, m.RunFrame())
+	m.SetKey(0xB, true)
+	assert.NoError(t, m.RunFrame())
+	m.SetKey(0xB, false)
+	assert.NoError(t, m.RunFrame())
+
+	assert.Equal(t, byte(0xB), m.Registers().V[0])
+	assert.Equal(t, uint16(0x202), m.Registers().PC)
+}
+
+func TestState(t *testing.T) {
+	m := machine.New(machine.WithSeed(1))
+	assert.NoError(t, m.LoadROM(beepROM))
+	assert.NoError(t, m.RunFrame())
+
+	var snapshot bytes.Buffer
+
+	assert.NoError(t, m.SaveState(&snapshot))
+
+	assert.NoError(t, m.RunFrame())
+	want := m.Registers()
+
+	assert.NoError(t, m.LoadState(bytes.NewReader(snapshot.Bytes())))
+	assert.NoError(t, m.RunFrame())
+	assert.Equal(t, want, m.Registers())
+
+	assert.ErrorIs(t, m.LoadState(bytes.NewReader([]byte("C8GX\x03\x00"))), machine.ErrInvalidState)
+}
+
+func TestInvalidState(t *testing.T) {
+	m := machine.New(machine.WithSeed(1))
+	assert.NoError(t, m.LoadROM(beepROM))
+	assert.NoError(t, m.RunFrame())
+
+	var snapshot bytes.Buffer
+
+	assert.NoError(t, m.SaveState(&snapshot))
+
+	const headerSize = 6
+
+	cpuSize := binary.Size(cpu.State{})
+	displayOffset := headerSize + cpuSize + binary.Size(memory.State{}) + binary.Size(timer.State{})
+
+	// corrupted returns the snapshot with the state at offset rewritten
+	corrupted := func(offset int, s any, corrupt func()) []byte {
+		b := bytes.Clone(snapshot.Bytes())
+
+		assert.NoError(t, binary.Read(bytes.NewReader(b[offset:]), binary.LittleEndian, s))
+		corrupt()
+
+		var w bytes.Buffer
+
+		assert.NoError(t, binary.Write(&w, binary.LittleEndian, s))
+		copy(b[offset:], w.Bytes())
+
+		return b
+	}
+
+	var (
+		c cpu.State
+		d display.State
+	)
+
+	for name, b := range map[string][]byte{
+		"stack pointer": corrupted(headerSize, &c, func() { c.SP = 0xFF }),
+		"compatibility": corrupted(headerSize, &c, func() { c.CompatibilityMode = 9 }),
+		"resolution":    corrupted(displayOffset, &d, func() { d.Res = 3 }),
+		"framebuffer":   corrupted(displayOffset, &d, func() { d.SelectedFrameBuffer = 7 }),
+	} {
+		want := m.Registers()
+
+		assert.ErrorIs(t, m.LoadState(bytes.NewReader(b)), machine.ErrInvalidState, name)
+		assert.Equal(t, want, m.Registers(), name)
+		assert.NotPanics(t, func() { assert.NoError(t, m.RunFrame()) }, name)
+	}
+}
+
+func TestExit(t *testing.T) {
+	rom := []byte{
+		0x00, 0xFD, // exit
+		0x12, 0x02, // loop
+	}
+
+	m := machine.New()
+	assert.NoError(t, m.LoadROM(rom))
+
+	assert.NoError(t, m.RunFrame())
+	assert.True(t, m.Paused())
+	assert.Equal(t, 1, m.Ticks())
+
+	m.Reset()
+	assert.False(t, m.Paused())
+}
+
+func TestROMTooBig(t *testing.T) {
+	assert.Error(t, machine.New().LoadROM(make([]byte, 0x10000)))
+}
+
+func TestFault(t *testing.T) {
+	rom := []byte{
+		0x60, 0x01, // V0 = 1
+		0x00, 0xEE, // return without call
+	}
+
+	m := machine.New()
+	assert.NoError(t, m.LoadROM(rom))
+
+	err := m.RunFrame()
+
+	var fault *machine.Fault
+
+	assert.ErrorIs(t, err, machine.ErrStackUnderflow)
+	assert.ErrorAs(t, err, &fault)
+	assert.Equal(t, uint16(0x202), fault.PC)
+	assert.Equal(t, uint16(0x00EE), fault.Opcode)
+	assert.Equal(t, 1, m.Ticks())
+
+	// The frame still ends
+	assert.Len(t, m.AudioSamples(), machine.SAMPLES_PER_FRAME)
+}
@@ -0,0 +1,25 @@
+package machine
+
+import (
+	"io"
+
+	"github.com/cterence/chip8-go/internal/hardware"
+)
+
+const (
+	STATE_MAGIC   = hardware.STATE_MAGIC
+	STATE_VERSION = hardware.STATE_VERSION
+)
+
+var ErrInvalidState = hardware.ErrInvalidState
+
+// SaveState writes a versioned snapshot of the whole machine to w.
+func (m *Machine) SaveState(w io.Writer) error {
+	return m.m.SaveState(w)
+}
+
+// LoadState restores a snapshot written by SaveState. The machine is left
+// untouched if the snapshot cannot be read or holds an invalid state.
+func (m *Machine) LoadState(r io.Reader) error {
+	return m.m.LoadState(r)
+}
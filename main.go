@@ -2,30 +2,52 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/cterence/chip8-go/internal/asm"
 	"github.com/cterence/chip8-go/internal/chip8"
 	"github.com/cterence/chip8-go/internal/lib"
+	"github.com/cterence/chip8-go/internal/movie"
+	"github.com/cterence/chip8-go/internal/romdb"
+	"github.com/cterence/chip8-go/internal/trace"
 	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
 )
 
 func main() {
 	var (
 		compatibilityMode lib.CompatibilityMode
+		quirkOverrides    []lib.QuirkOverride
 		debug             bool
 		rom               string
 		pauseAfter        int
 		exitAfter         int
 		scale             int
 		headless          bool
+		frontendType      chip8.FrontendType
+		keyTimeout        time.Duration
+		faultPolicy       chip8.FaultPolicy
 		screenshot        bool
+		screenshotNative  bool
 		testFlag          byte
 		speed             float32
+		ipf               int
 		disableAudio      bool
+		stateFile         string
+		rewindSeconds     int
+		seed              uint64
+		legacyRand        bool
+		traceFile         string
+		traceFormat       trace.Format
+		recordFile        string
+		audioOutFile      string
+		recordInputFile   string
+		playInputFile     string
+		romDBFile         string
 	)
 
 	cmd := &cli.Command{
@@ -52,26 +74,52 @@ func main() {
 					},
 				},
 			},
-			{
-				Flags: [][]cli.Flag{
-					{
-						&cli.BoolFlag{
-							Name:        "headless",
-							Usage:       "disable ui",
-							Destination: &headless,
-						},
-					},
-					{
-						&cli.BoolFlag{
-							Name:        "screenshot",
-							Usage:       "save screenshot on exit",
-							Destination: &screenshot,
-						},
-					},
-				},
-			},
 		},
 		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "headless",
+				Usage:       "disable ui and run unthrottled",
+				Destination: &headless,
+			},
+			&cli.StringFlag{
+				Name:  "frontend",
+				Usage: "display and keyboard frontend (sdl, terminal)",
+				Value: "sdl",
+				Action: func(_ context.Context, _ *cli.Command, name string) error {
+					var err error
+
+					frontendType, err = chip8.ParseFrontendType(name)
+
+					return err
+				},
+			},
+			&cli.DurationFlag{
+				Name:        "key-timeout",
+				Usage:       "release keys not repeated by the terminal within this delay (terminal frontend)",
+				Value:       600 * time.Millisecond,
+				Destination: &keyTimeout,
+			},
+			&cli.StringFlag{
+				Name:  "on-fault",
+				Usage: "on an invalid instruction: pause in the debugger, exit with an error or skip it (pause, exit, ignore), exit by default when headless or without a terminal",
+				Action: func(_ context.Context, _ *cli.Command, name string) error {
+					var err error
+
+					faultPolicy, err = chip8.ParseFaultPolicy(name)
+
+					return err
+				},
+			},
+			&cli.BoolFlag{
+				Name:        "screenshot",
+				Usage:       "save screenshot on exit (png without the sdl frontend)",
+				Destination: &screenshot,
+			},
+			&cli.BoolFlag{
+				Name:        "screenshot-native",
+				Usage:       "save png screenshots at the display resolution (64x32 or 128x64) instead of the window size",
+				Destination: &screenshotNative,
+			},
 			&cli.BoolFlag{
 				Name:        "debug",
 				Aliases:     []string{"d"},
@@ -86,10 +134,15 @@ func main() {
 			&cli.Float32Flag{
 				Name:        "speed",
 				Aliases:     []string{"s"},
-				Usage:       "interpreter speed",
+				Usage:       "interpreter speed multiplier",
 				Value:       1.0,
 				Destination: &speed,
 			},
+			&cli.IntFlag{
+				Name:        "ipf",
+				Usage:       "instructions executed per 60 Hz frame (default depends on the compatibility mode)",
+				Destination: &ipf,
+			},
 			&cli.IntFlag{
 				Name:        "scale",
 				Usage:       "pixel and window scale factor",
@@ -102,30 +155,104 @@ func main() {
 				Usage:       "populate 0x1FF address before run (used by timendus tests)",
 				Destination: &testFlag,
 			},
+			&cli.Uint64Flag{
+				Name:        "seed",
+				Usage:       "seed the random number generator for reproducible runs",
+				Destination: &seed,
+			},
+			&cli.BoolFlag{
+				Name:        "legacy-rand",
+				Usage:       "reproduce the old CXNN random distribution (never yields 0xFF)",
+				Destination: &legacyRand,
+			},
+			&cli.IntFlag{
+				Name:        "rewind-seconds",
+				Usage:       "seconds of gameplay kept in the rewind buffer (hold backspace to rewind, 0 to disable)",
+				Value:       10,
+				Destination: &rewindSeconds,
+			},
+			&cli.StringFlag{
+				Name:        "audio-out",
+				Usage:       "write the audio output to a wav file, also when headless or with audio disabled",
+				Destination: &audioOutFile,
+			},
+			&cli.StringFlag{
+				Name:        "record",
+				Usage:       "record the display to an animated gif (toggle with G)",
+				Destination: &recordFile,
+			},
+			&cli.StringFlag{
+				Name:        "record-input",
+				Usage:       "record key presses to an input movie, with the seed, rom hash and machine settings",
+				Destination: &recordInputFile,
+			},
+			&cli.StringFlag{
+				Name:        "play-input",
+				Usage:       "replay the key presses of an input movie with its settings (press tab to take over)",
+				Destination: &playInputFile,
+			},
+			&cli.StringFlag{
+				Name:        "load-state",
+				Usage:       "load a save state file before run",
+				Destination: &stateFile,
+			},
+			&cli.StringFlag{
+				Name:        "trace",
+				Usage:       "record the machine state after every instruction to a file",
+				Destination: &traceFile,
+			},
+			&cli.StringFlag{
+				Name:  "trace-format",
+				Usage: "trace file format (text, binary)",
+				Value: "text",
+				Action: func(_ context.Context, _ *cli.Command, format string) error {
+					var err error
+
+					traceFormat, err = trace.ParseFormat(format)
+
+					return err
+				},
+			},
 			&cli.StringFlag{
 				Name:    "compatibility-mode",
 				Aliases: []string{"m"},
-				Usage:   "force compatibility mode (chip8, super, xo)",
+				Usage:   "force compatibility mode (chip8, super, xo), found in the rom database or guessed from the instructions by default",
 				Action: func(_ context.Context, _ *cli.Command, mode string) error {
-					switch mode {
-					case "chip8":
-						compatibilityMode = lib.CM_CHIP8
-					case "super":
-						compatibilityMode = lib.CM_SUPERCHIP
-					case "xo":
-						compatibilityMode = lib.CM_XOCHIP
-					default:
-						return fmt.Errorf("unknown compatibility mode: %s", mode)
-					}
-
-					return nil
+					var err error
+
+					compatibilityMode, err = lib.ParseCompatibilityMode(mode)
+
+					return err
+				},
+			},
+			&cli.StringFlag{
+				Name:        "rom-db",
+				Usage:       "look the rom up in this programs.json of the CHIP-8 database instead of the embedded one, none to disable",
+				Destination: &romDBFile,
+			},
+			&cli.StringFlag{
+				Name:  "quirks",
+				Usage: "override quirks of the compatibility mode (e.g. shift=vy,jump=bxnn,clip=on)",
+				Action: func(_ context.Context, _ *cli.Command, spec string) error {
+					var err error
+
+					quirkOverrides, err = lib.ParseQuirkOverrides(spec)
+
+					return err
 				},
 			},
 		},
+		Commands: []*cli.Command{
+			asmCommand(),
+			disasmCommand(),
+			traceDiffCommand(),
+			testCommand(),
+			infoCommand(),
+		},
 		Arguments: []cli.Argument{
 			&cli.StringArg{
 				Name:        "rom",
-				UsageText:   "rom path",
+				UsageText:   "rom path (.8o sources are assembled before running)",
 				Destination: &rom,
 			},
 		},
@@ -139,28 +266,97 @@ func main() {
 				return fmt.Errorf("failed to read rom file: %w", err)
 			}
 
-			c8 := chip8.New(
-				romBytes,
+			var sourceMap *asm.Program
+
+			if strings.HasSuffix(rom, ".8o") {
+				sourceMap, err = asm.Assemble(string(romBytes), rom)
+				if err != nil {
+					return err
+				}
+
+				romBytes = sourceMap.ROM
+			}
+
+			// Nobody can answer the debugger prompt
+			if !c.IsSet("on-fault") {
+				faultPolicy = chip8.FP_PAUSE
+
+				if headless || !term.IsTerminal(int(os.Stdin.Fd())) {
+					faultPolicy = chip8.FP_EXIT
+				}
+			}
+
+			options := []chip8.Option{
 				chip8.WithCompatibilityMode(compatibilityMode),
+				chip8.WithQuirkOverrides(quirkOverrides),
 				chip8.WithDebug(debug),
 				chip8.WithPauseAfter(pauseAfter),
 				chip8.WithExitAfter(exitAfter),
 				chip8.WithRomFileName(rom),
 				chip8.WithScreenshot(screenshot),
+				chip8.WithNativeScreenshot(screenshotNative),
 				chip8.WithScale(scale),
 				chip8.WithSpeed(speed),
+				chip8.WithIPF(ipf),
 				chip8.WithHeadless(headless),
+				chip8.WithFrontend(frontendType),
+				chip8.WithKeyTimeout(keyTimeout),
+				chip8.WithFaultPolicy(faultPolicy),
 				chip8.WithTestFlag(testFlag),
 				chip8.WithAudioDisabled(disableAudio),
-			)
+				chip8.WithLoadState(stateFile),
+				chip8.WithRewindSeconds(rewindSeconds),
+				chip8.WithLegacyRand(legacyRand),
+				chip8.WithTrace(traceFile, traceFormat),
+				chip8.WithRecord(recordFile),
+				chip8.WithAudioOut(audioOutFile),
+				chip8.WithRecordInput(recordInputFile),
+			}
+
+			if playInputFile != "" {
+				f, err := os.Open(playInputFile)
+				if err != nil {
+					return fmt.Errorf("failed to open input movie: %w", err)
+				}
+
+				inputMovie, err := movie.Read(f)
+				f.Close()
+
+				if err != nil {
+					return fmt.Errorf("failed to read input movie: %w", err)
+				}
+
+				options = append(options, chip8.WithPlayInput(inputMovie))
+			}
+
+			if sourceMap != nil {
+				options = append(options, chip8.WithSourceMap(sourceMap))
+			}
+
+			switch romDBFile {
+			case "":
+			case "none":
+				options = append(options, chip8.WithROMDatabase(nil))
+			default:
+				db, err := romdb.Load(romDBFile)
+				if err != nil {
+					return err
+				}
+
+				options = append(options, chip8.WithROMDatabase(db))
+			}
+
+			if c.IsSet("seed") {
+				options = append(options, chip8.WithSeed(seed))
+			}
+
+			c8 := chip8.New(romBytes, options...)
 
 			return c8.Run(ctx)
 		},
 	}
 
 	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		if !errors.Is(err, sdl.EndLoop) {
-			log.Fatalf("runtime error: %v", err)
-		}
+		log.Fatalf("runtime error: %v", err)
 	}
 }
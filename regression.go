@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cterence/chip8-go/internal/chip8"
+	"github.com/urfave/cli/v3"
+)
+
+func testCommand() *cli.Command {
+	var (
+		suiteDir  string
+		goldenDir string
+		update    bool
+	)
+
+	return &cli.Command{
+		Name:  "test",
+		Usage: "run the Timendus test suite headless and compare the displays to golden files",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "suite",
+				Usage:       "test suite rom directory",
+				Value:       chip8.TEST_SUITE_DIR,
+				Destination: &suiteDir,
+			},
+			&cli.StringFlag{
+				Name:        "golden",
+				Usage:       "golden file directory",
+				Value:       chip8.GOLDEN_DIR,
+				Destination: &goldenDir,
+			},
+			&cli.BoolFlag{
+				Name:        "update",
+				Usage:       "regenerate the golden files instead of comparing",
+				Destination: &update,
+			},
+		},
+		Action: func(ctx context.Context, _ *cli.Command) error {
+			failed := 0
+
+			for _, rt := range chip8.TimendusTests {
+				if err := rt.Check(ctx, suiteDir, goldenDir, update); err != nil {
+					failed++
+
+					fmt.Printf("FAIL %s: %v\n", rt.Name, err)
+
+					continue
+				}
+
+				if update {
+					fmt.Printf("UPDATED %s\n", rt.Name)
+				} else {
+					fmt.Printf("ok %s\n", rt.Name)
+				}
+			}
+
+			if failed > 0 {
+				return cli.Exit(fmt.Sprintf("%d/%d tests failed", failed, len(chip8.TimendusTests)), 1)
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cterence/chip8-go/internal/trace"
+	"github.com/urfave/cli/v3"
+)
+
+func traceDiffCommand() *cli.Command {
+	var a, b string
+
+	return &cli.Command{
+		Name:  "trace-diff",
+		Usage: "report the first divergence between two traces recorded with --trace",
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:        "a",
+				UsageText:   "first trace path",
+				Destination: &a,
+			},
+			&cli.StringArg{
+				Name:        "b",
+				UsageText:   "second trace path",
+				Destination: &b,
+			},
+		},
+		Action: func(_ context.Context, c *cli.Command) error {
+			if a == "" || b == "" {
+				return cli.ShowSubcommandHelp(c)
+			}
+
+			readerA, closeA, err := openTrace(a)
+			if err != nil {
+				return err
+			}
+			defer closeA()
+
+			readerB, closeB, err := openTrace(b)
+			if err != nil {
+				return err
+			}
+			defer closeB()
+
+			divergence, err := trace.Diff(readerA, readerB)
+			if err != nil {
+				return err
+			}
+
+			if divergence == nil {
+				fmt.Println("traces are identical")
+
+				return nil
+			}
+
+			return cli.Exit(divergence.String(), 1)
+		},
+	}
+}
+
+func openTrace(path string) (*trace.Reader, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+
+	r, err := trace.NewReader(f)
+	if err != nil {
+		f.Close()
+
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return r, func() { f.Close() }, nil
+}